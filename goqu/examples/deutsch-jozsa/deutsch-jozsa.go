@@ -17,26 +17,17 @@
 package main
 
 import (
+	"algorithms"
 	"fmt"
 	"os"
-	"quantum"
 )
 
 func main() {
-	qreg := quantum.NewQReg(3, 1)
-	quantum.HadamardReg(qreg)
-	quantum.NewRealArrayGate([]float64{
-		0, 1, 0, 0, 0, 0, 0, 0,
-		1, 0, 0, 0, 0, 0, 0, 0,
-		0, 0, 0, 1, 0, 0, 0, 0,
-		0, 0, 1, 0, 0, 0, 0, 0,
-		0, 0, 0, 0, 1, 0, 0, 0,
-		0, 0, 0, 0, 0, 1, 0, 0,
-		0, 0, 0, 0, 0, 0, 1, 0,
-		0, 0, 0, 0, 0, 0, 0, 1,
-	}).ApplyReg(qreg)
-	quantum.HadamardRange(qreg, 1, 3)
-	if qreg.Measure()>>1 == 0 {
+	// f(x) is the negation of the high bit of x, which is balanced.
+	f := func(x int) int {
+		return 1 ^ x>>1
+	}
+	if algorithms.DeutschJozsa(2, f) {
 		fmt.Println("constant")
 	} else {
 		fmt.Println("balanced")
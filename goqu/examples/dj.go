@@ -27,12 +27,8 @@ func main() {
 	fmt.Println(qreg)
 
 	quantum.HadamardReg(qreg)
-	quantum.NewRealArrayGate([]float64{
-		0, 1, 0, 0,
-		1, 0, 0, 0,
-		0, 0, 1, 0,
-		0, 0, 0, 1,
-	}).ApplyReg(qreg)
+	// f(x) = NOT x: flip qubit 0 when qubit 1 is 0.
+	quantum.ControlledOn(quantum.PauliX(), 0).Apply(qreg, []int{1, 0})
 	quantum.Hadamard(qreg, 1)
 	if qreg.BMeasure(1) == 0 {
 		fmt.Println("constant")
@@ -17,33 +17,52 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math"
 	"os"
 	"quantum"
 )
 
+var bits = flag.Int("n", 3, "number of bits of the values searched")
+var target = flag.Int("target", 5, "the marked value to search for")
+var noise = flag.Float64("noise", 0,
+	"probability of a depolarizing error on each qubit after each gate")
+var trials = flag.Int("trials", 1, "number of times to run the search")
+
+// Search for the marked value among the values of n qubits.
+func search(n int, model *quantum.NoiseModel) int {
+	qreg := quantum.NewQReg(n)
+	qreg.SetNoiseModel(model)
+	quantum.AmplitudeAmplification(qreg, quantum.NewHadamardGate(n),
+		func(x int) bool { return x == *target })
+	return qreg.Measure()
+}
+
 func main() {
-	n := 3
-	qreg := quantum.NewQReg(n+1, 0)
-	quantum.HadamardRange(qreg, 1, n+1)
-	h := quantum.NewHadamardGate(1)
-	u_f := quantum.NewClassicalGate(func(x int) int {
-		if x>>1 == 5 {
-			return x ^ 1
+	flag.Parse()
+	if *bits < 1 || *bits > 30 {
+		fmt.Printf("Cannot search values of %d bits\n", *bits)
+		os.Exit(1)
+	}
+	if *target < 0 || *target >= 1<<uint(*bits) {
+		fmt.Printf("%d is not a value of %d bits\n", *target, *bits)
+		os.Exit(1)
+	}
+	var model *quantum.NoiseModel
+	if *noise > 0 {
+		model = quantum.NewNoiseModel().AddAllQubitQuantumError(
+			quantum.NewDepolarizingChannel(1, *noise))
+	}
+	if *trials == 1 {
+		fmt.Printf("Found %d\n", search(*bits, model))
+		os.Exit(0)
+	}
+	found := 0
+	for i := 0; i < *trials; i++ {
+		if search(*bits, model) == *target {
+			found++
 		}
-		return x
-	},
-		n+1)
-	states := 1 << uint(n)
-	d := quantum.NewDiffusionGate(n)
-	iterations := int((math.Pi * math.Sqrt(float64(states))) / 4.0)
-	for i := 0; i < iterations; i++ {
-		qreg.BSet(0, 1)
-		h.ApplyRange(qreg, 0)
-		u_f.ApplyReg(qreg)
-		d.ApplyRange(qreg, 1)
 	}
-	fmt.Printf("Found %d\n", qreg.Measure()>>1)
+	fmt.Printf("Found %d in %d of %d trials\n", *target, found, *trials)
 	os.Exit(0)
 }
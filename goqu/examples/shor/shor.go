@@ -17,13 +17,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	/*"math"*/
-	"os"
-	/*"quantum"*/
+	"math"
 	"math/rand"
+	"os"
+	"quantum"
+	"time"
 )
 
+var number = flag.Int("n", 15, "the number to factor")
+
 func gcd(a int, b int) int {
 	for a != 0 {
 		a, b = b%a, a
@@ -31,81 +35,197 @@ func gcd(a int, b int) int {
 	return b
 }
 
-/*func isPrime(n int) bool {*/
-/*if n % 2 == 0 {*/
-/*return false*/
-/*}*/
-/*for r := 3; r < n; r++ {*/
-/*if gcd(n, r) != 1 {*/
-/*return false*/
-/*}*/
-/*if isPrime(r) {*/
-/*let q be the largest factor of r-1*/
-/*if (q > 4sqrt(r)log n) and (n(r-1)/q is not 1 (mod r)) then break*/
-/*}*/
-/*r := r+1*/
-/*}*/
-
-/*for a = 1 to 2sqrt(r)log n {*/
-/*if ( (x-a)n is not (xn-a) (mod xr-1,n) ) then output COMPOSITE*/
-/*}*/
-
-/*output PRIME;*/
-/*}*/
-
-// TODO: Replace with a polynomial time algorithm
-func isPowerOfPrime(n int) bool {
-	for i := 2; i < n; i++ {
+// Trial division is fast enough for numbers small enough to simulate.
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
 		if n%i == 0 {
-			return true
+			return false
 		}
 	}
-	return false
+	return true
 }
 
-func newa(bign int) int {
-	return rand.Intn(bign-1) + 1
+// If n = p^k for a prime p and k >= 1, return p. Otherwise return 0. Only the
+// k-th roots for k up to log2(n) need to be tried, so this takes polynomial
+// time apart from the primality test.
+func primeRoot(n int) int {
+	for k := int(math.Log2(float64(n))); k >= 1; k-- {
+		root := int(math.Round(math.Pow(float64(n), 1/float64(k))))
+		// Allow for rounding error in the floating point root.
+		for p := root - 1; p <= root+1; p++ {
+			if p >= 2 && intPow(p, k) == n && isPrime(p) {
+				return p
+			}
+		}
+	}
+	return 0
 }
 
-// TODO: Make a real period function
-func period(bign int, a int) int {
-	return 0
+func isPowerOfPrime(n int) bool {
+	return primeRoot(n) != 0
 }
 
-func powermod(x int, y int, n int) int {
+// Compute x^y, or -1 if it is larger than the largest int.
+func intPow(x int, y int) int {
 	ret := 1
 	for i := 0; i < y; i++ {
+		if ret > math.MaxInt64/x {
+			return -1
+		}
 		ret *= x
-		ret %= n
 	}
 	return ret
 }
 
+func newa(bign int, rng *rand.Rand) int {
+	return rng.Intn(bign-1) + 1
+}
+
+// Compute x^y mod n by repeated squaring.
+func powermod(x int, y int, n int) int {
+	ret := 1
+	x %= n
+	for ; y > 0; y >>= 1 {
+		if y&1 == 1 {
+			ret = ret * x % n
+		}
+		x = x * x % n
+	}
+	return ret
+}
+
+// The number of bits needed to hold the values 0 to n-1.
+func bitsFor(n int) int {
+	bits := 0
+	for 1<<uint(bits) < n {
+		bits++
+	}
+	return bits
+}
+
+// A gate on n qubits which maps |y> to |a*y mod bign> for y < bign, and leaves
+// the remaining states alone. Since a is coprime to bign this is a
+// permutation, and hence reversible.
+func newModMulGate(a int, bign int, n int) *quantum.Gate {
+	return quantum.NewClassicalGate(func(y int) int {
+		if y >= bign {
+			return y
+		}
+		return a * y % bign
+	},
+		n)
+}
+
+// A circuit which maps |x>|y> to |x>|a^x * y mod bign>, where x is held in the
+// t qubits from 0 and y in the n qubits from t. Bit j of x multiplies y by
+// a^(2^j), so the exponentiation is a sequence of controlled multiplications.
+func newModExpCircuit(a int, bign int, t int, n int) *quantum.Circuit {
+	circuit := quantum.NewCircuit(t + n)
+	targets := make([]int, n+1)
+	for i := 0; i < n; i++ {
+		targets[i+1] = t + i
+	}
+	for j := 0; j < t; j++ {
+		targets[0] = j
+		multiplier := powermod(a, 1<<uint(j), bign)
+		circuit.AppendLabelled("modexp", quantum.Controlled(
+			newModMulGate(multiplier, bign, n), 1), targets...)
+	}
+	return circuit
+}
+
+// Find the denominators of the convergents of the continued fraction of
+// y / m which are less than bign, in increasing order.
+func convergentDenominators(y int, m int, bign int) []int {
+	var denominators []int
+	// The convergents are h/k, built from the partial quotients.
+	prevK, k := 0, 1
+	for y != 0 {
+		quotient := m / y
+		m, y = y, m%y
+		prevK, k = k, quotient*k+prevK
+		if k >= bign {
+			break
+		}
+		denominators = append(denominators, k)
+	}
+	return denominators
+}
+
+// Find the period of a^x mod bign with the quantum period finding algorithm,
+// or return 0 if this run of it failed. The measurement draws from rng.
+func period(bign int, a int, rng *rand.Rand) int {
+	n := bitsFor(bign)
+	// 2n counting qubits are enough to pick out a fraction s/r with
+	// r < bign from its continued fraction.
+	t := 2 * n
+	qreg := quantum.NewQRegWithRand(rand.NewSource(rng.Int63()), t+n,
+		1<<uint(t))
+	quantum.HadamardRange(qreg, 0, t)
+	newModExpCircuit(a, bign, t, n).Run(qreg)
+	quantum.InverseQFTRange(qreg, 0, t)
+	y := qreg.Measure() & (1<<uint(t) - 1)
+
+	// y / 2^t is close to s/r for some s, so r is a multiple of the
+	// denominator of one of its convergents.
+	for _, k := range convergentDenominators(y, 1<<uint(t), bign) {
+		for r := k; r < bign; r += k {
+			if powermod(a, r, bign) == 1 {
+				return reduceOrder(bign, a, r)
+			}
+		}
+	}
+	return 0
+}
+
+// Given a multiple r of the order of a modulo bign, find the order itself by
+// dividing out the factors of r for as long as a^r is still 1.
+func reduceOrder(bign int, a int, r int) int {
+	for p := 2; p <= r; p++ {
+		for r%p == 0 && powermod(a, r/p, bign) == 1 {
+			r /= p
+		}
+	}
+	return r
+}
+
+// Find a non-trivial factorization of bign, which must be composite, drawing
+// the guesses and measurements from rng.
+func factor(bign int, rng *rand.Rand) (int, int) {
+	if bign%2 == 0 {
+		return 2, bign / 2
+	}
+	if p := primeRoot(bign); p != 0 {
+		return p, bign / p
+	}
+	for {
+		a := newa(bign, rng)
+		if common := gcd(a, bign); common != 1 {
+			// A lucky guess.
+			return common, bign / common
+		}
+		r := period(bign, a, rng)
+		if r != 0 && r%2 == 0 && powermod(a, r/2, bign) != bign-1 {
+			// (a^(r/2) - 1)(a^(r/2) + 1) is a multiple of bign,
+			// but neither factor is.
+			common := gcd(powermod(a, r/2, bign)+1, bign)
+			return common, bign / common
+		}
+	}
+}
+
 func main() {
-	/*bign := 6 // 2 * 3*/
-	/*n := int(math.Ceil(math.Log2(float64(bign))))*/
-	/*isPowerOfPrime(n)*/
-	/*qreg := quantum.NewQReg(3*n, 0)*/
-	/*quantum.HadamardRange(qreg, n, qreg.Size())*/
-	/*qreg.Print()*/
-	/*fmt.Println()*/
-
-	/*var factor int*/
-	/*for a := newa(bign); ; a = newa(bign) {*/
-		/*factor = gcd(a, N)*/
-		/*if factor != 1 {*/
-			/*break*/
-		/*}*/
-
-		/*r := period(bign, a)*/
-		/*if r%2 == 0 && powermod(a, r, bign) != bign-1 {*/
-			/*pow := math.Pow(a, r/2)*/
-			/*factor = gcd(pow+1, bign)*/
-			/*break*/
-		/*}*/
-	/*}*/
-
-	/*fmt.Printf("%d %d\n", common, N/common)*/
-	fmt.Println("This doesn't work yet.  Be patient.")
+	flag.Parse()
+	bign := *number
+	if bign < 4 || isPrime(bign) {
+		fmt.Printf("%d is not composite\n", bign)
+		os.Exit(1)
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	p, q := factor(bign, rng)
+	fmt.Printf("%d = %d * %d\n", bign, p, q)
 	os.Exit(0)
 }
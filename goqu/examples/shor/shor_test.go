@@ -0,0 +1,94 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Author: conleyo@google.com (Conley Owens)
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPrimeRoot(t *testing.T) {
+	tests := []struct{ n, p int }{
+		{2, 2}, {9, 3}, {15, 0}, {27, 3}, {49, 7}, {1024, 2}, {3125, 5},
+		{36, 0}, {97, 97}, {1 << 30, 2}, {2 * 3 * 5 * 7, 0},
+	}
+	for _, test := range tests {
+		if actual := primeRoot(test.n); actual != test.p {
+			t.Errorf("primeRoot(%d) = %d, expected %d.", test.n,
+				actual, test.p)
+		}
+	}
+}
+
+func TestConvergentDenominators(t *testing.T) {
+	// 85/256 = 1/(3 + 1/(85 + 1/1)), which has convergents 1/3 and
+	// 85/256.
+	actual := convergentDenominators(85, 256, 15)
+	if len(actual) != 1 || actual[0] != 3 {
+		t.Errorf("Bad denominators %v, expected [3].", actual)
+	}
+}
+
+func TestReduceOrder(t *testing.T) {
+	// 2 has order 12 modulo 35.
+	for _, r := range []int{12, 24, 36, 60} {
+		if actual := reduceOrder(35, 2, r); actual != 12 {
+			t.Errorf("reduceOrder(35, 2, %d) = %d, expected 12.", r,
+				actual)
+		}
+	}
+}
+
+// The quantum period finding must find the order of a modulo bign on most
+// runs.
+func TestPeriod(t *testing.T) {
+	tests := []struct{ bign, a, r int }{
+		{15, 7, 4}, {15, 4, 2}, {21, 2, 6}, {35, 2, 12},
+	}
+	const runs = 10
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		found := 0
+		for i := 0; i < runs; i++ {
+			r := period(test.bign, test.a, rng)
+			if r != 0 && r != test.r {
+				t.Errorf("period(%d, %d) = %d, expected %d.",
+					test.bign, test.a, r, test.r)
+			}
+			if r == test.r {
+				found++
+			}
+		}
+		if found < runs/4 {
+			t.Errorf("period(%d, %d) found in only %d of %d runs.",
+				test.bign, test.a, found, runs)
+		}
+	}
+}
+
+func TestFactor(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, bign := range []int{15, 21, 35} {
+		for i := 0; i < 5; i++ {
+			p, q := factor(bign, rng)
+			if p <= 1 || q <= 1 || p*q != bign {
+				t.Errorf("Bad factorization %d = %d * %d.", bign,
+					p, q)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+// Decide whether a function f from n bits to one bit is constant or balanced,
+// i.e. 0 for exactly half of its inputs, with a single query by the
+// Deutsch-Jozsa algorithm. It returns true for a constant function. Only the
+// lowest bit of f(x) is used, and f must be one or the other.
+func DeutschJozsa(n int, f func(x int) int) bool {
+	// The amplitude of |0...0> after the final Hadamard gates is the
+	// average of (-1)^f(x), which is 1 or -1 if f is constant and 0 if
+	// it is balanced.
+	return measureInput(queryPhase(f, n), n) == 0
+}
+
+// Find the secret s of a function f(x) = DotGF2(s, x) XOR b from n bits to one
+// bit with a single query by the Bernstein-Vazirani algorithm. Only the
+// lowest bit of f(x) is used.
+func BernsteinVazirani(n int, f func(x int) int) int {
+	// The phase (-1)^(s.x) on each |x> is the image of |s> under the
+	// Hadamard gates, so they send it back to |s>.
+	return measureInput(queryPhase(f, n), n)
+}
@@ -0,0 +1,59 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDeutschJozsa(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for n := 1; n <= 6; n++ {
+		for b := 0; b < 2; b++ {
+			constant := func(x int) int { return b }
+			if !DeutschJozsa(n, constant) {
+				t.Errorf("Constant %d of %d bits found balanced.",
+					b, n)
+			}
+		}
+		// A balanced function is 1 on a random half of its inputs.
+		values := make([]int, 1<<uint(n))
+		for _, x := range rng.Perm(len(values))[:len(values)/2] {
+			values[x] = 1
+		}
+		balanced := func(x int) int { return values[x] }
+		if DeutschJozsa(n, balanced) {
+			t.Errorf("Balanced function %v found constant.", values)
+		}
+	}
+}
+
+func TestBernsteinVazirani(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for trial := 0; trial < 20; trial++ {
+		n := 1 + rng.Intn(8)
+		secret := rng.Intn(1 << uint(n))
+		b := rng.Intn(2)
+		f := func(x int) int { return DotGF2(secret, x) ^ b }
+		if actual := BernsteinVazirani(n, f); actual != secret {
+			t.Errorf("Found secret %b of %d bits, expected %b.",
+				actual, n, secret)
+		}
+	}
+}
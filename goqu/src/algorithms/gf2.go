@@ -0,0 +1,128 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"math/bits"
+)
+
+// The functions in this file work with matrices over GF(2), the field of the
+// bits 0 and 1 under XOR and AND. Each row of a matrix is an int whose bit j
+// is the element in column j, and vectors are packed into ints likewise.
+
+// The parity of the number of bits set in x, i.e. the sum of its bits over
+// GF(2).
+func parity(x int) int {
+	return bits.OnesCount(uint(x)) & 1
+}
+
+// The dot product of two vectors over GF(2).
+func DotGF2(x, y int) int {
+	return parity(x & y)
+}
+
+// Reduce the rows of an n-column matrix to reduced row echelon form by
+// Gaussian elimination. The nonzero rows of the result are returned, along
+// with the column of the leading bit of each. The rows given are not changed.
+func RowReduceGF2(rows []int, n int) ([]int, []int) {
+	reduced := append([]int(nil), rows...)
+	var pivots []int
+	rank := 0
+	for col := 0; col < n && rank < len(reduced); col++ {
+		mask := 1 << uint(col)
+		// Find a row with this column set, and move it up to the
+		// current rank.
+		pivot := -1
+		for i := rank; i < len(reduced); i++ {
+			if reduced[i]&mask != 0 {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		reduced[rank], reduced[pivot] = reduced[pivot], reduced[rank]
+		// Clear the column from every other row, above and below.
+		for i := range reduced {
+			if i != rank && reduced[i]&mask != 0 {
+				reduced[i] ^= reduced[rank]
+			}
+		}
+		pivots = append(pivots, col)
+		rank++
+	}
+	return reduced[:rank], pivots
+}
+
+// The rank of an n-column matrix over GF(2).
+func RankGF2(rows []int, n int) int {
+	_, pivots := RowReduceGF2(rows, n)
+	return len(pivots)
+}
+
+// Solve the system of equations DotGF2(rows[i], x) = values[i] for x, where
+// the matrix has n columns. If there are many solutions, the one with the free
+// variables set to 0 is returned. The second result is false if there is no
+// solution.
+func SolveGF2(rows []int, values []int, n int) (int, bool) {
+	if len(values) != len(rows) {
+		panic("SolveGF2 needs one value for each row")
+	}
+	// Augment each row with its value in column n.
+	augmented := make([]int, len(rows))
+	for i, row := range rows {
+		augmented[i] = row&(1<<uint(n)-1) | (values[i]&1)<<uint(n)
+	}
+	reduced, pivots := RowReduceGF2(augmented, n+1)
+	x := 0
+	for i, col := range pivots {
+		if col == n {
+			// The row reads 0 = 1.
+			return 0, false
+		}
+		x |= (reduced[i] >> uint(n) & 1) << uint(col)
+	}
+	return x, true
+}
+
+// Find a basis of the null space of an n-column matrix over GF(2), i.e. of the
+// vectors x with DotGF2(row, x) = 0 for every row. There is one basis vector
+// for each column without a pivot, so the basis is empty exactly when the
+// matrix has rank n.
+func NullSpaceGF2(rows []int, n int) []int {
+	reduced, pivots := RowReduceGF2(rows, n)
+	isPivot := make([]bool, n)
+	for _, col := range pivots {
+		isPivot[col] = true
+	}
+	var basis []int
+	for free := 0; free < n; free++ {
+		if isPivot[free] {
+			continue
+		}
+		// Set the free variable, and each pivot variable to cancel
+		// it in the pivot's row.
+		x := 1 << uint(free)
+		for i, col := range pivots {
+			x |= (reduced[i] >> uint(free) & 1) << uint(col)
+		}
+		basis = append(basis, x)
+	}
+	return basis
+}
@@ -0,0 +1,107 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRowReduceGF2(t *testing.T) {
+	// The third row is the XOR of the first two.
+	rows := []int{0x3, 0x6, 0x5, 0x8}
+	reduced, pivots := RowReduceGF2(rows, 4)
+	expectedRows := []int{0x5, 0x6, 0x8}
+	expectedPivots := []int{0, 1, 3}
+	if len(reduced) != 3 || len(pivots) != 3 {
+		t.Fatalf("Bad reduction %x with pivots %v.", reduced, pivots)
+	}
+	for i := range reduced {
+		if reduced[i] != expectedRows[i] || pivots[i] != expectedPivots[i] {
+			t.Errorf("Bad reduction %x with pivots %v, expected %x "+
+				"with pivots %v.", reduced, pivots, expectedRows,
+				expectedPivots)
+			break
+		}
+	}
+	if rows[1] != 0x6 {
+		t.Error("Rows were changed.")
+	}
+	if rank := RankGF2(rows, 4); rank != 3 {
+		t.Errorf("Bad rank %d, expected 3.", rank)
+	}
+}
+
+func TestSolveGF2(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 100; trial++ {
+		n := 1 + rng.Intn(10)
+		secret := rng.Intn(1 << uint(n))
+		rows := make([]int, rng.Intn(2*n))
+		values := make([]int, len(rows))
+		for i := range rows {
+			rows[i] = rng.Intn(1 << uint(n))
+			values[i] = DotGF2(rows[i], secret)
+		}
+		x, ok := SolveGF2(rows, values, n)
+		if !ok {
+			t.Fatalf("No solution found for %x = %v.", rows, values)
+		}
+		for i, row := range rows {
+			if DotGF2(row, x) != values[i] {
+				t.Fatalf("Bad solution %x for %x = %v.", x, rows,
+					values)
+			}
+		}
+	}
+	// x0 + x1 = 1 and x0 + x1 = 0 are inconsistent.
+	if _, ok := SolveGF2([]int{3, 3}, []int{1, 0}, 2); ok {
+		t.Error("Expected no solution.")
+	}
+}
+
+func TestNullSpaceGF2(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for trial := 0; trial < 100; trial++ {
+		n := 1 + rng.Intn(10)
+		rows := make([]int, rng.Intn(n+2))
+		for i := range rows {
+			rows[i] = rng.Intn(1 << uint(n))
+		}
+		basis := NullSpaceGF2(rows, n)
+		if len(basis)+RankGF2(rows, n) != n {
+			t.Fatalf("Null space of %x has dimension %d.", rows,
+				len(basis))
+		}
+		if RankGF2(basis, n) != len(basis) {
+			t.Fatalf("Basis %x of null space is not independent.",
+				basis)
+		}
+		for _, x := range basis {
+			for _, row := range rows {
+				if DotGF2(row, x) != 0 {
+					t.Fatalf("%x is not in the null space "+
+						"of %x.", x, rows)
+				}
+			}
+		}
+	}
+	if basis := NullSpaceGF2([]int{1, 2, 4}, 3); len(basis) != 0 {
+		t.Errorf("Expected an empty basis, got %x.", basis)
+	}
+}
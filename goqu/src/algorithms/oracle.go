@@ -0,0 +1,79 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"quantum"
+)
+
+// The oracles in this file give quantum access to a classical function f from
+// n-bit inputs to m-bit outputs. The input x is held in qubits 0 to n-1 and
+// the output register in qubits n to n+m-1, each with qubit 0 of the register
+// as its least significant bit, as in the default little-endian bit order.
+
+// Build the oracle which maps |x>|y> to |x>|y XOR f(x)>. Only the lowest m
+// bits of f(x) are used. The oracle is a permutation of the basis states, so
+// it is applied by moving amplitudes.
+func NewOracle(f func(x int) int, n, m int) *quantum.Gate {
+	inputMask := 1<<uint(n) - 1
+	outputMask := 1<<uint(m) - 1
+	outputs := make([]int, 1<<uint(n))
+	for x := range outputs {
+		outputs[x] = f(x) & outputMask
+	}
+	return quantum.NewClassicalGate(func(state int) int {
+		x := state & inputMask
+		return state ^ outputs[x]<<uint(n)
+	},
+		n+m)
+}
+
+// Build a register of n+m qubits with the input register in an equal
+// superposition of all x and the output register in |y>.
+func newOracleInput(n, m, y int) *quantum.QReg {
+	qreg := quantum.NewQReg(n+m, y<<uint(n))
+	quantum.HadamardRange(qreg, 0, n)
+	return qreg
+}
+
+// Query a one-bit oracle on a superposition of all inputs with the output
+// qubit in |->, so that the phase (-1)^f(x) is kicked back onto each |x>,
+// then apply Hadamard gates to the input again. The result is the register of
+// n+1 qubits.
+func queryPhase(f func(x int) int, n int) *quantum.QReg {
+	qreg := newOracleInput(n, 1, 1)
+	quantum.Hadamard(qreg, n)
+	NewOracle(f, n, 1).ApplyReg(qreg)
+	quantum.HadamardRange(qreg, 0, n)
+	return qreg
+}
+
+// Measure the register and return the value of its input register of n
+// qubits.
+func measureInput(qreg *quantum.QReg, n int) int {
+	return qreg.Measure() & (1<<uint(n) - 1)
+}
+
+// The qubits of the input register of n qubits.
+func inputQubits(n int) []int {
+	qubits := make([]int, n)
+	for i := range qubits {
+		qubits[i] = i
+	}
+	return qubits
+}
@@ -0,0 +1,42 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"quantum"
+	"testing"
+)
+
+func TestNewOracle(t *testing.T) {
+	f := func(x int) int { return 3*x + 1 }
+	oracle := NewOracle(f, 3, 2)
+	if oracle.Width() != 5 {
+		t.Fatalf("Bad width %d, expected 5.", oracle.Width())
+	}
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 4; y++ {
+			qreg := quantum.NewQReg(5, y<<3|x)
+			oracle.ApplyReg(qreg)
+			expected := (y^f(x)&3)<<3 | x
+			if prob := qreg.StateProb(expected); prob != 1 {
+				t.Errorf("|%d>|%d> not sent to |%d>.", x, y,
+					expected>>3)
+			}
+		}
+	}
+}
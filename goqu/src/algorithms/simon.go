@@ -0,0 +1,77 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"quantum"
+)
+
+// The number of times Simon's algorithm samples n equations before deciding
+// that f cannot satisfy the promise, for functions of n bits. When it does,
+// each sample gives a new independent equation with probability at least 1/2
+// until enough are found, so the chance of giving up wrongly is negligible.
+func simonRounds(n int) int {
+	return 2*n + 16
+}
+
+// Find the secret s of a function f from n bits to n bits with Simon's
+// algorithm, where f(x) = f(y) exactly when y = x or y = x XOR s. It returns
+// 0 if f is one-to-one, which is the case s = 0. The second result is false if
+// f was found not to satisfy this promise, in which case no secret is
+// returned.
+//
+// Each run of the quantum circuit yields a random y with DotGF2(y, s) = 0,
+// so s is found from the null space of enough such equations. This takes
+// O(n) runs on average.
+func Simon(n int, f func(x int) int) (int, bool) {
+	circuit := quantum.NewCircuit(2*n).
+		AppendRange(quantum.NewHadamardGate(n), 0).
+		AppendRange(NewOracle(f, n, n), 0).
+		AppendRange(quantum.NewHadamardGate(n), 0)
+	qreg := quantum.NewQReg(2 * n)
+	circuit.Run(qreg)
+	inputs := inputQubits(n)
+	f0 := f(0) & (1<<uint(n) - 1)
+
+	var equations []int
+	for round := 0; round < simonRounds(n); round++ {
+		// Measuring the input register of the same state again is
+		// like running the circuit again, so the samples are
+		// independent.
+		for y := range qreg.SampleQubits(n, nil, inputs) {
+			if RankGF2(append(equations, y), n) > len(equations) {
+				equations = append(equations, y)
+			}
+		}
+		switch len(equations) {
+		case n:
+			// Only 0 satisfies all the equations.
+			return 0, true
+		case n - 1:
+			// Either s is the one nonzero solution, or f is
+			// one-to-one and there is one more equation to find.
+			s := NullSpaceGF2(equations, n)[0]
+			if f(s)&(1<<uint(n)-1) == f0 {
+				return s, true
+			}
+		}
+	}
+	// Too few independent equations were found, so f is constant on a
+	// larger subspace than {0, s}, or not constant on one at all.
+	return 0, false
+}
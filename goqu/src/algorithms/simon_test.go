@@ -0,0 +1,79 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package algorithms
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Build a random function from n bits to n bits with the secret s, which is
+// one-to-one if s = 0 and two-to-one otherwise.
+func newSimonFunction(rng *rand.Rand, n, s int) func(x int) int {
+	// Label each pair {x, x XOR s} by its smaller member, and give the
+	// labels distinct random values.
+	perm := rng.Perm(1 << uint(n))
+	return func(x int) int {
+		if x^s < x {
+			x ^= s
+		}
+		return perm[x]
+	}
+}
+
+func TestSimon(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for trial := 0; trial < 20; trial++ {
+		n := 1 + rng.Intn(5)
+		secret := rng.Intn(1 << uint(n))
+		f := newSimonFunction(rng, n, secret)
+		if actual, ok := Simon(n, f); !ok || actual != secret {
+			t.Errorf("Found secret %b of %d bits (%t), expected %b.",
+				actual, n, ok, secret)
+		}
+	}
+}
+
+func TestSimon_OneToOne(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	for n := 1; n <= 4; n++ {
+		if actual, ok := Simon(n, newSimonFunction(rng, n, 0)); !ok || actual != 0 {
+			t.Errorf("Found secret %b (%t) for a one-to-one function.",
+				actual, ok)
+		}
+	}
+}
+
+// Functions which break the promise must be reported rather than searched
+// forever.
+func TestSimon_BrokenPromise(t *testing.T) {
+	tests := []struct {
+		n int
+		f func(x int) int
+	}{
+		{2, func(x int) int { return 0 }},
+		{3, func(x int) int { return x & 1 }},
+		{4, func(x int) int { return x & 3 }},
+	}
+	for _, test := range tests {
+		if s, ok := Simon(test.n, test.f); ok {
+			t.Errorf("Found secret %b for a function of %d bits "+
+				"which breaks the promise.", s, test.n)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"quantum"
+)
+
+// The error returned when a circuit contains an operation which cannot be
+// written in OpenQASM, such as a gate without a name in OpenQASM 3. The
+// returned errors wrap it with the details of the operation.
+var ErrCannotExport = errors.New("qasm: cannot export operation")
+
+// Definitions in terms of the standard gates of the named gates which are
+// not in the standard headers, for programs which use them.
+var gateDefs = map[string]string{
+	"rxx": "gate rxx(theta) a, b { h a; h b; cx a, b; rz(theta) b; " +
+		"cx a, b; h a; h b; }",
+	"ryy": "gate ryy(theta) a, b { rx(pi / 2) a; rx(pi / 2) b; cx a, b; " +
+		"rz(theta) b; cx a, b; rx(-pi / 2) a; rx(-pi / 2) b; }",
+	"rzz":   "gate rzz(theta) a, b { cx a, b; rz(theta) b; cx a, b; }",
+	"iswap": "gate iswap a, b { s a; s b; h a; cx a, b; cx b, a; h b; }",
+}
+
+// The qelib1.inc names of controlled gates whose names in the simulator are
+// different, e.g. a global phase controlled on one qubit is a phase gate.
+var qelib1Aliases = map[string]string{
+	"cccx":     "c3x",
+	"ccccx":    "c4x",
+	"cgphase":  "u1",
+	"ccgphase": "cu1",
+}
+
+// The widest gate whose matrix is written in a comment when it is exported
+// as an opaque gate.
+const maxCommentedWidth = 4
+
+// The state of the formatter for one program.
+type formatter struct {
+	version  int
+	builtins map[string]builtinGate
+	circuit  *quantum.Circuit
+	body     strings.Builder
+
+	// The names of the gates which need to be defined by the program.
+	defs map[string]bool
+
+	// In OpenQASM 2.0, the gates which are declared as opaque because they
+	// cannot be written in terms of other gates, and their declarations.
+	opaques     []*quantum.Gate
+	opaqueDecls strings.Builder
+}
+
+// Format a circuit as an OpenQASM 2.0 program, with its qubits in a register
+// named q and its classical bits in a register named c. Each gate which
+// cannot be written in terms of the gates of qelib1.inc is declared as an
+// opaque gate, preceded by a comment giving its matrix, so the program can
+// be read but not run by other tools. A conditional operation can only be
+// written if its condition is on the whole of c.
+func FormatQASM2(circuit *quantum.Circuit) (string, error) {
+	return format(circuit, 2, qelib1Gates)
+}
+
+// Format a circuit as an OpenQASM 3 program, with its qubits in a register
+// named q and its classical bits in a register named c. Controlled gates
+// without a standard name are written with the ctrl and negctrl modifiers.
+// Parsing the program gives back a circuit with the same effect, in which
+// the gates of the standard library have the same names and parameters.
+func FormatQASM3(circuit *quantum.Circuit) (string, error) {
+	return format(circuit, 3, stdGates)
+}
+
+func format(circuit *quantum.Circuit, version int,
+	builtins map[string]builtinGate) (string, error) {
+	f := &formatter{
+		version:  version,
+		builtins: builtins,
+		circuit:  circuit,
+		defs:     make(map[string]bool)}
+	for _, op := range circuit.Operations() {
+		if err := f.formatOperation(op); err != nil {
+			return "", err
+		}
+	}
+
+	var program strings.Builder
+	if version == 3 {
+		program.WriteString("OPENQASM 3.0;\ninclude \"stdgates.inc\";\n")
+	} else {
+		program.WriteString("OPENQASM 2.0;\ninclude \"qelib1.inc\";\n")
+	}
+	var names []string
+	for name := range f.defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		program.WriteString(gateDefs[name] + "\n")
+	}
+	program.WriteString(f.opaqueDecls.String())
+	if circuit.Width() > 0 {
+		if version == 3 {
+			fmt.Fprintf(&program, "qubit[%d] q;\n", circuit.Width())
+		} else {
+			fmt.Fprintf(&program, "qreg q[%d];\n", circuit.Width())
+		}
+	}
+	if circuit.NumClbits() > 0 {
+		if version == 3 {
+			fmt.Fprintf(&program, "bit[%d] c;\n", circuit.NumClbits())
+		} else {
+			fmt.Fprintf(&program, "creg c[%d];\n", circuit.NumClbits())
+		}
+	}
+	program.WriteString(f.body.String())
+	return program.String(), nil
+}
+
+func (f *formatter) formatOperation(op quantum.Operation) error {
+	condition, err := f.formatCondition(op.Condition)
+	if err != nil {
+		return err
+	}
+	qubits := make([]string, len(op.Targets))
+	for i, target := range op.Targets {
+		qubits[i] = fmt.Sprintf("q[%d]", target)
+	}
+
+	switch op.Kind {
+	case quantum.GateOp:
+		gate := op.Gate
+		if gate.Name() == "h" && gate.Base() == nil {
+			// A Hadamard gate on several qubits is the tensor
+			// product of Hadamard gates on each of them.
+			for _, qubit := range qubits {
+				f.statement(condition, "h", qubit)
+			}
+			return nil
+		}
+		if f.version == 3 {
+			call, err := f.gateCall3(gate)
+			if err != nil {
+				return err
+			}
+			f.statement(condition, call, qubits...)
+		} else {
+			f.formatGate2(condition, gate, qubits)
+		}
+	case quantum.MeasureOp:
+		if f.version == 3 {
+			f.statement(condition, fmt.Sprintf("c[%d] = measure",
+				op.Clbit), qubits[0])
+		} else {
+			f.statement(condition, "measure", qubits[0],
+				fmt.Sprintf("-> c[%d]", op.Clbit))
+		}
+	case quantum.ResetOp:
+		f.statement(condition, "reset", qubits[0])
+	case quantum.BarrierOp:
+		if len(qubits) == 0 {
+			qubits = []string{"q"}
+		}
+		f.statement(condition, "barrier", qubits...)
+	}
+	return nil
+}
+
+// Write a statement, such as a gate call followed by its qubits. The last
+// argument is separated by a space rather than a comma if it is the result
+// of a measurement in OpenQASM 2.0.
+func (f *formatter) statement(condition, call string, args ...string) {
+	f.body.WriteString(condition + call)
+	for i, arg := range args {
+		if i == 0 || strings.HasPrefix(arg, "->") {
+			f.body.WriteString(" ")
+		} else {
+			f.body.WriteString(", ")
+		}
+		f.body.WriteString(arg)
+	}
+	f.body.WriteString(";\n")
+}
+
+// Return whether a gate is the builtin gate of the given name, or one of the
+// gates which the program may define, and if so note any definition needed.
+func (f *formatter) isNamed(name string, gate *quantum.Gate) bool {
+	if builtin, ok := f.builtins[name]; ok {
+		return builtin.numQubits == gate.Width() &&
+			builtin.numParams == len(gate.Params())
+	}
+	if gateDefs[name] != "" {
+		f.defs[name] = true
+		return true
+	}
+	return false
+}
+
+// Format the call of a gate in OpenQASM 3, without its qubits, e.g.
+// "rx(0.5)" or "negctrl @ h".
+func (f *formatter) gateCall3(gate *quantum.Gate) (string, error) {
+	name := gate.Name()
+	switch {
+	case name == "sxdg":
+		return "inv @ sx", nil
+	case name == "gphase" || f.isNamed(name, gate):
+		return name + formatParams(gate.Params()), nil
+	case gate.Base() != nil:
+		call, err := f.gateCall3(gate.Base())
+		if err != nil {
+			return "", err
+		}
+		return formatControls(gate.ControlValues()) + call, nil
+	}
+	return "", fmt.Errorf("%w: gate %q of width %d", ErrCannotExport, name,
+		gate.Width())
+}
+
+// Write the application of a gate in OpenQASM 2.0. A controlled gate with
+// negative controls is written as the gate with ordinary controls, between
+// X gates on the negative controls.
+func (f *formatter) formatGate2(condition string, gate *quantum.Gate,
+	qubits []string) {
+	name := gate.Name()
+	if name == "gphase" {
+		fmt.Fprintf(&f.body, "// gphase%s;\n", formatParams(gate.Params()))
+		return
+	}
+	var negative []string
+	if base := gate.Base(); base != nil {
+		values := gate.ControlValues()
+		name = strings.Repeat("c", len(values)) + base.Name()
+		for i, value := range values {
+			if value == 0 {
+				negative = append(negative, qubits[i])
+			}
+		}
+	}
+	if alias, ok := qelib1Aliases[name]; ok {
+		name = alias
+	}
+	if !f.isNamed(name, gate) {
+		f.statement(condition, f.declareOpaque(gate), qubits...)
+		return
+	}
+	for _, qubit := range negative {
+		f.statement(condition, "x", qubit)
+	}
+	f.statement(condition, name+formatParams(gate.Params()), qubits...)
+	for _, qubit := range negative {
+		f.statement(condition, "x", qubit)
+	}
+}
+
+// Return the name of an opaque gate standing for the given gate, declaring it
+// if it has not been declared already.
+func (f *formatter) declareOpaque(gate *quantum.Gate) string {
+	for i, opaque := range f.opaques {
+		if sameMatrix(opaque, gate) {
+			return fmt.Sprintf("unitary%d", i)
+		}
+	}
+	name := fmt.Sprintf("unitary%d", len(f.opaques))
+	f.opaques = append(f.opaques, gate)
+
+	d := &f.opaqueDecls
+	fmt.Fprintf(d, "// %s", name)
+	if gate.Name() != "" {
+		fmt.Fprintf(d, " is %s%s, which", gate.Name(),
+			formatParams(gate.Params()))
+	}
+	dim := 1 << uint(gate.Width())
+	if gate.Width() > maxCommentedWidth {
+		fmt.Fprintf(d, " has a %d by %d matrix.\n", dim, dim)
+	} else {
+		fmt.Fprintf(d, " has the matrix:\n")
+		for row := 0; row < dim; row++ {
+			d.WriteString("//  ")
+			for col := 0; col < dim; col++ {
+				d.WriteString(" " + strconv.FormatComplex(
+					gate.Get(row, col), 'g', -1, 128))
+			}
+			d.WriteString("\n")
+		}
+	}
+	args := make([]string, gate.Width())
+	for i := range args {
+		args[i] = fmt.Sprintf("a%d", i)
+	}
+	fmt.Fprintf(d, "opaque %s %s;\n", name, strings.Join(args, ", "))
+	return name
+}
+
+// Return whether two gates are known to have the same matrix: either they
+// are the same gate, or they are narrow enough to compare element by element.
+func sameMatrix(a, b *quantum.Gate) bool {
+	if a == b {
+		return true
+	}
+	if a.Width() != b.Width() || a.Width() > maxCommentedWidth {
+		return false
+	}
+	dim := 1 << uint(a.Width())
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			if a.Get(row, col) != b.Get(row, col) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Format the modifiers for the given control values, e.g. "ctrl(2) @ negctrl
+// @ " for the values 1, 1, 0.
+func formatControls(values []int) string {
+	var modifiers strings.Builder
+	for i := 0; i < len(values); {
+		n := 1
+		for i+n < len(values) && values[i+n] == values[i] {
+			n++
+		}
+		if values[i] == 0 {
+			modifiers.WriteString("neg")
+		}
+		modifiers.WriteString("ctrl")
+		if n > 1 {
+			fmt.Fprintf(&modifiers, "(%d)", n)
+		}
+		modifiers.WriteString(" @ ")
+		i += n
+	}
+	return modifiers.String()
+}
+
+// Format the parameters of a gate, if any, in parentheses. They are written
+// with as many digits as are needed to read them back exactly.
+func formatParams(params []float64) string {
+	if len(params) == 0 {
+		return ""
+	}
+	values := make([]string, len(params))
+	for i, param := range params {
+		values[i] = strconv.FormatFloat(param, 'g', -1, 64)
+	}
+	return "(" + strings.Join(values, ", ") + ")"
+}
+
+// Format the if statement which makes an operation conditional, or return
+// the empty string for an operation without a condition.
+func (f *formatter) formatCondition(condition *quantum.Condition) (string, error) {
+	if condition == nil {
+		return "", nil
+	}
+	n := len(condition.Clbits)
+	if condition.Value < 0 || n < 63 && condition.Value >= 1<<uint(n) {
+		return "", fmt.Errorf("%w: condition value %d on %d bits",
+			ErrCannotExport, condition.Value, n)
+	}
+	if n == 0 {
+		return "", nil
+	}
+	whole := n == f.circuit.NumClbits()
+	for i, clbit := range condition.Clbits {
+		whole = whole && clbit == i
+	}
+	if whole {
+		if f.version == 2 {
+			return fmt.Sprintf("if(c==%d) ", condition.Value), nil
+		}
+		return fmt.Sprintf("if (c == %d) ", condition.Value), nil
+	}
+	if f.version == 2 {
+		return "", fmt.Errorf("%w: condition on classical bits %v, "+
+			"which are not the whole register", ErrCannotExport,
+			condition.Clbits)
+	}
+	terms := make([]string, n)
+	for i, clbit := range condition.Clbits {
+		terms[i] = fmt.Sprintf("c[%d] == %d", clbit,
+			condition.Value>>uint(i)&1)
+	}
+	return "if (" + strings.Join(terms, " && ") + ") ", nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strings"
+	"testing"
+
+	"quantum"
+)
+
+// A circuit using each kind of operation that can be exported, whose
+// gates are all in the standard library.
+func newStandardCircuit() *quantum.Circuit {
+	return quantum.NewCircuit(3).
+		Append(quantum.NewHadamardGate(1), 0).
+		Append(quantum.CX(), 0, 1).
+		Append(quantum.ControlledOn(quantum.RotationX(0.5), 1, 0), 2, 0, 1).
+		Append(quantum.Controlled(quantum.U3(1, 2, 3), 1), 1, 2).
+		Append(quantum.Controlled(quantum.Phase(-0.25), 1), 2, 0).
+		Append(quantum.SqrtXDagger(), 1).
+		Append(quantum.GlobalPhase(math.Pi)).
+		Barrier(0, 1, 2).
+		Measure(0, 0).
+		Reset(1).
+		AppendOperation(quantum.Operation{
+			Kind:      quantum.GateOp,
+			Gate:      quantum.PauliX(),
+			Targets:   []int{2},
+			Condition: &quantum.Condition{Clbits: []int{0}, Value: 1}}).
+		Measure(2, 2).
+		AppendOperation(quantum.Operation{
+			Kind:      quantum.GateOp,
+			Gate:      quantum.PauliZ(),
+			Targets:   []int{1},
+			Condition: &quantum.Condition{Clbits: []int{2, 0}, Value: 2}}).
+		AppendOperation(quantum.Operation{
+			Kind:    quantum.GateOp,
+			Gate:    quantum.PauliY(),
+			Targets: []int{0},
+			Condition: &quantum.Condition{
+				Clbits: []int{0, 1, 2},
+				Value:  5}})
+}
+
+func TestFormatQASM3(t *testing.T) {
+	circuit := newStandardCircuit().
+		Append(quantum.NewHadamardGate(2), 2, 0).
+		Append(quantum.IsingZZ(-0.25), 0, 1)
+	expected := `OPENQASM 3.0;
+include "stdgates.inc";
+gate rzz(theta) a, b { cx a, b; rz(theta) b; cx a, b; }
+qubit[3] q;
+bit[3] c;
+h q[0];
+cx q[0], q[1];
+ctrl @ negctrl @ rx(0.5) q[2], q[0], q[1];
+ctrl @ u3(1, 2, 3) q[1], q[2];
+cp(-0.25) q[2], q[0];
+inv @ sx q[1];
+gphase(3.141592653589793);
+barrier q[0], q[1], q[2];
+c[0] = measure q[0];
+reset q[1];
+if (c[0] == 1) x q[2];
+c[2] = measure q[2];
+if (c[2] == 0 && c[0] == 1) z q[1];
+if (c == 5) y q[0];
+h q[2];
+h q[0];
+rzz(-0.25) q[0], q[1];
+`
+	actual, err := FormatQASM3(circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if actual != expected {
+		t.Errorf("Bad program:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+// Verify that two lists of operations are the same, with the same gates.
+func verifySameOperations(t *testing.T, expected, actual []quantum.Operation) {
+	if len(actual) != len(expected) {
+		t.Fatalf("Got %d operations, expected %d.", len(actual),
+			len(expected))
+	}
+	for i, op := range actual {
+		want := expected[i]
+		same := op.Kind == want.Kind && op.Clbit == want.Clbit &&
+			len(op.Targets) == len(want.Targets) &&
+			(op.Condition == nil) == (want.Condition == nil)
+		for j := 0; same && j < len(op.Targets); j++ {
+			same = op.Targets[j] == want.Targets[j]
+		}
+		if same && op.Condition != nil {
+			same = op.Condition.Value == want.Condition.Value &&
+				len(op.Condition.Clbits) == len(want.Condition.Clbits)
+			for j := 0; same && j < len(op.Condition.Clbits); j++ {
+				same = op.Condition.Clbits[j] == want.Condition.Clbits[j]
+			}
+		}
+		if same && op.Kind == quantum.GateOp {
+			params, wantParams := op.Gate.Params(), want.Gate.Params()
+			same = op.Gate.Name() == want.Gate.Name() &&
+				op.Gate.Width() == want.Gate.Width() &&
+				len(params) == len(wantParams)
+			for j := 0; same && j < len(params); j++ {
+				same = params[j] == wantParams[j]
+			}
+		}
+		if !same {
+			t.Errorf("Bad operation %d = %+v, expected %+v.", i, op,
+				want)
+		}
+	}
+}
+
+func TestFormatQASM3_RoundTrip(t *testing.T) {
+	circuit := newStandardCircuit()
+	src, err := FormatQASM3(circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	program := mustParse(t, src)
+	verifySameOperations(t, circuit.Operations(), program.Circuit.Operations())
+
+	// Formatting the parsed program should give back the same text.
+	again, err := FormatQASM3(program.Circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again != src {
+		t.Errorf("Bad program after round trip:\n%s\nexpected:\n%s",
+			again, src)
+	}
+}
+
+// Gates which are not in the standard library are exported in terms of other
+// gates, so check that they have the same effect: running the parsed program
+// and then undoing the original circuit should give back |00>.
+func TestFormatQASM3_RoundTripEffect(t *testing.T) {
+	gates := []*quantum.Gate{
+		quantum.IsingXX(0.3),
+		quantum.IsingYY(0.4),
+		quantum.IsingZZ(0.5),
+		quantum.ISwap(),
+		quantum.Controlled(quantum.SqrtXDagger(), 1),
+		quantum.ControlledOn(quantum.U3(0.1, 0.2, 0.3), 0),
+		quantum.Controlled(quantum.GlobalPhase(0.6), 2),
+	}
+	for _, gate := range gates {
+		circuit := quantum.NewCircuit(2).
+			Append(quantum.NewHadamardGate(1), 0).
+			Append(quantum.RotationY(0.3), 1).
+			Append(quantum.CX(), 0, 1).
+			Append(quantum.RotationZ(0.7), 0).
+			AppendRange(gate, 0)
+		src, err := FormatQASM3(circuit)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", gate.Name(), err)
+			continue
+		}
+		program := mustParse(t, src)
+		qreg := program.NewQReg()
+		program.Run(qreg)
+		ops := circuit.Operations()
+		for i := len(ops) - 1; i >= 0; i-- {
+			quantum.Adjoint(ops[i].Gate).Apply(qreg, ops[i].Targets)
+		}
+		if prob := qreg.StateProb(0); math.Abs(prob-1) > 1e-9 {
+			t.Errorf("Program for %q does not match the gate:\n%s",
+				gate.Name(), src)
+		}
+	}
+}
+
+// Every gate of the standard library must survive being parsed, exported and
+// parsed again, including its phase relative to the rest of the circuit.
+func TestFormatQASM3_RoundTripStdGates(t *testing.T) {
+	for name, builtin := range stdGates {
+		params := make([]string, builtin.numParams)
+		for i := range params {
+			params[i] = fmt.Sprintf("%g", 0.3+0.4*float64(i))
+		}
+		call := name
+		if len(params) > 0 {
+			call += "(" + strings.Join(params, ", ") + ")"
+		}
+		// Put each qubit into a different superposition first, so that
+		// every element of the gate's matrix affects the result.
+		var body, qubits []string
+		for i := 0; i < builtin.numQubits; i++ {
+			body = append(body, fmt.Sprintf("h q[%d]; ry(%g) q[%d];",
+				i, 0.2+0.5*float64(i), i))
+			qubits = append(qubits, fmt.Sprintf("q[%d]", i))
+		}
+		src := fmt.Sprintf("OPENQASM 3.0;\ninclude \"stdgates.inc\";\n"+
+			"qubit[%d] q;\n%s\n%s %s;\n", builtin.numQubits,
+			strings.Join(body, "\n"), call, strings.Join(qubits, ", "))
+		program := mustParse(t, src)
+		exported, err := FormatQASM3(program.Circuit)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		reparsed := mustParse(t, exported)
+
+		expected := program.NewQReg()
+		program.Run(expected)
+		actual := reparsed.NewQReg()
+		reparsed.Run(actual)
+		if overlap := quantum.InnerProduct(expected, actual); cmplx.Abs(overlap-1) > 1e-9 {
+			t.Errorf("%s: round trip has overlap %v with the "+
+				"original:\n%s", name, overlap, exported)
+		}
+	}
+}
+
+func TestFormatQASM3_Errors(t *testing.T) {
+	gates := []*quantum.Gate{
+		quantum.NewRealArrayGate([]float64{0, 1, 1, 0}),
+		quantum.FSim(0.1, 0.2),
+		quantum.Controlled(quantum.NewHadamardGate(2), 1),
+	}
+	for _, gate := range gates {
+		circuit := quantum.NewCircuit(3).AppendRange(gate, 0)
+		if _, err := FormatQASM3(circuit); !errors.Is(err, ErrCannotExport) {
+			t.Errorf("Expected ErrCannotExport for %q, got %v.",
+				gate.Name(), err)
+		}
+	}
+}
+
+func TestFormatQASM2(t *testing.T) {
+	// The two diffusion gates are declared once, since they have the same
+	// matrix.
+	circuit := quantum.NewCircuit(4).
+		Append(quantum.NewHadamardGate(2), 0, 1).
+		Append(quantum.ControlledOn(quantum.PauliX(), 1, 0, 1), 0, 1, 2, 3).
+		Append(quantum.Controlled(quantum.GlobalPhase(0.5), 2), 3, 0).
+		Append(quantum.IsingYY(0.25), 2, 3).
+		Append(quantum.NewDiffusionGate(2), 1, 2).
+		Append(quantum.RotationX(0.5), 0).
+		Append(quantum.NewDiffusionGate(2), 0, 3).
+		Append(quantum.GlobalPhase(math.Pi)).
+		Measure(0, 0).
+		Measure(1, 1).
+		AppendOperation(quantum.Operation{
+			Kind:      quantum.GateOp,
+			Gate:      quantum.Controlled(quantum.T(), 1),
+			Targets:   []int{0, 1},
+			Condition: &quantum.Condition{Clbits: []int{0, 1}, Value: 2}})
+	expected := `OPENQASM 2.0;
+include "qelib1.inc";
+gate ryy(theta) a, b { rx(pi / 2) a; rx(pi / 2) b; cx a, b; rz(theta) b; cx a, b; rx(-pi / 2) a; rx(-pi / 2) b; }
+// unitary0 has the matrix:
+//   (-0.5+0i) (0.5+0i) (0.5+0i) (0.5+0i)
+//   (0.5+0i) (-0.5+0i) (0.5+0i) (0.5+0i)
+//   (0.5+0i) (0.5+0i) (-0.5+0i) (0.5+0i)
+//   (0.5+0i) (0.5+0i) (0.5+0i) (-0.5+0i)
+opaque unitary0 a0, a1;
+// unitary1 is ct, which has the matrix:
+//   (1+0i) (0+0i) (0+0i) (0+0i)
+//   (0+0i) (1+0i) (0+0i) (0+0i)
+//   (0+0i) (0+0i) (1+0i) (0+0i)
+//   (0+0i) (0+0i) (0+0i) (0.7071067811865476+0.7071067811865475i)
+opaque unitary1 a0, a1;
+qreg q[4];
+creg c[2];
+h q[0];
+h q[1];
+x q[1];
+c3x q[0], q[1], q[2], q[3];
+x q[1];
+cu1(0.5) q[3], q[0];
+ryy(0.25) q[2], q[3];
+unitary0 q[1], q[2];
+rx(0.5) q[0];
+unitary0 q[0], q[3];
+// gphase(3.141592653589793);
+measure q[0] -> c[0];
+measure q[1] -> c[1];
+if(c==2) unitary1 q[0], q[1];
+`
+	actual, err := FormatQASM2(circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if actual != expected {
+		t.Errorf("Bad program:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+// Operations applied to a register can be recorded and exported, and the
+// program read back runs the same operations.
+func TestFormatQASM2_Recording(t *testing.T) {
+	qreg := quantum.NewQReg(3)
+	qreg.StartRecording()
+	quantum.Hadamard(qreg, 0)
+	quantum.Hadamard(qreg, 1)
+	quantum.CX().Apply(qreg, []int{1, 2})
+	quantum.Controlled(quantum.RotationZ(0.5), 1).Apply(qreg, []int{2, 0})
+	quantum.IsingZZ(0.75).Apply(qreg, []int{0, 1})
+	qreg.BMeasure(2)
+	qreg.BMeasure(0)
+	circuit := qreg.StopRecording()
+
+	src, err := FormatQASM2(circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	program := mustParse(t, src)
+	verifySameOperations(t, circuit.Operations(), program.Circuit.Operations())
+	again, err := FormatQASM2(program.Circuit)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if again != src {
+		t.Errorf("Bad program after round trip:\n%s\nexpected:\n%s",
+			again, src)
+	}
+}
+
+func TestFormatQASM2_Errors(t *testing.T) {
+	circuit := quantum.NewCircuit(1).
+		Measure(0, 0).
+		Measure(0, 1).
+		AppendOperation(quantum.Operation{
+			Kind:      quantum.GateOp,
+			Gate:      quantum.PauliX(),
+			Targets:   []int{0},
+			Condition: &quantum.Condition{Clbits: []int{1}, Value: 1}})
+	if _, err := FormatQASM2(circuit); !errors.Is(err, ErrCannotExport) {
+		t.Errorf("Expected ErrCannotExport, got %v.", err)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"fmt"
+	"math"
+)
+
+// A parameter expression, such as pi/2 or 2*theta. Expressions in a gate
+// definition refer to the gate's parameters, so they are kept as a tree and
+// evaluated each time the gate is used.
+type expr interface {
+	eval(env map[string]float64) float64
+}
+
+type numberExpr float64
+
+type identExpr string
+
+type unaryExpr struct {
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+
+	// The operator, for reporting division by zero or a value which is not
+	// a finite number.
+	tok token
+}
+
+type callExpr struct {
+	fn  func(float64) float64
+	arg expr
+
+	// The name of the function, for reporting a value which is not a finite
+	// number.
+	tok token
+}
+
+// The functions which may be applied to an expression.
+var exprFuncs = map[string]func(float64) float64{
+	"sin":  math.Sin,
+	"cos":  math.Cos,
+	"tan":  math.Tan,
+	"exp":  math.Exp,
+	"ln":   math.Log,
+	"sqrt": math.Sqrt,
+
+	// These are only in OpenQASM 3.
+	"arcsin": math.Asin,
+	"arccos": math.Acos,
+	"arctan": math.Atan,
+}
+
+func (e numberExpr) eval(env map[string]float64) float64 {
+	return float64(e)
+}
+
+func (e identExpr) eval(env map[string]float64) float64 {
+	return env[string(e)]
+}
+
+func (e *unaryExpr) eval(env map[string]float64) float64 {
+	return -e.operand.eval(env)
+}
+
+func (e *binaryExpr) eval(env map[string]float64) float64 {
+	left, right := e.left.eval(env), e.right.eval(env)
+	var value float64
+	switch e.op {
+	case "+":
+		value = left + right
+	case "-":
+		value = left - right
+	case "*":
+		value = left * right
+	case "/", "%":
+		if right == 0 {
+			panic(&Error{e.tok.line, e.tok.col, "division by zero"})
+		}
+		if e.op == "/" {
+			value = left / right
+		} else {
+			value = math.Mod(left, right)
+		}
+	default:
+		value = math.Pow(left, right)
+	}
+	return finite(value, e.tok)
+}
+
+func (e *callExpr) eval(env map[string]float64) float64 {
+	return finite(e.fn(e.arg.eval(env)), e.tok)
+}
+
+// Return a value computed at a token, which must be a finite number, since
+// gates with NaN or infinite angles are meaningless.
+func finite(value float64, tok token) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		panic(&Error{tok.line, tok.col,
+			fmt.Sprintf("%s gives %v, which is not a finite number",
+				tok.text, value)})
+	}
+	return value
+}
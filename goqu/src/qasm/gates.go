@@ -0,0 +1,160 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"math"
+
+	"quantum"
+)
+
+// A gate which is built into the simulator rather than defined by the
+// program, along with the number of parameters and qubits it takes.
+type builtinGate struct {
+	numParams int
+	numQubits int
+	new       func(params []float64) *quantum.Gate
+}
+
+// Helpers for defining builtin gates without parameters, and controlled
+// versions of other builtin gates.
+func fixed(numQubits int, gate func() *quantum.Gate) builtinGate {
+	return builtinGate{0, numQubits, func([]float64) *quantum.Gate {
+		return gate()
+	}}
+}
+
+func controlled(numControls int, base builtinGate) builtinGate {
+	return builtinGate{base.numParams, numControls + base.numQubits,
+		func(params []float64) *quantum.Gate {
+			return quantum.Controlled(base.new(params), numControls)
+		}}
+}
+
+// The gates which are always available, U and CX.
+var primitiveGates = map[string]builtinGate{
+	"U": {3, 1, func(p []float64) *quantum.Gate {
+		return quantum.U3(p[0], p[1], p[2])
+	}},
+	"CX": fixed(2, quantum.CX),
+}
+
+// The gates which are always available in OpenQASM 3, U and gphase.
+var primitiveGates3 = map[string]builtinGate{
+	"U": primitiveGates["U"],
+	"gphase": {1, 0, func(p []float64) *quantum.Gate {
+		return quantum.GlobalPhase(p[0])
+	}},
+}
+
+// The gates of the standard header qelib1.inc. Gates which are defined there
+// up to a global phase, such as rz, are mapped to their usual matrices.
+var qelib1Gates = map[string]builtinGate{
+	"u3": primitiveGates["U"],
+	"u":  primitiveGates["U"],
+	"u2": {2, 1, func(p []float64) *quantum.Gate {
+		return quantum.U3(math.Pi/2, p[0], p[1])
+	}},
+	"u1": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.Phase(p[0])
+	}},
+	"p": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.Phase(p[0])
+	}},
+	"u0": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.Identity()
+	}},
+	"id":   fixed(1, quantum.Identity),
+	"x":    fixed(1, quantum.PauliX),
+	"y":    fixed(1, quantum.PauliY),
+	"z":    fixed(1, quantum.PauliZ),
+	"h":    fixed(1, hadamard),
+	"s":    fixed(1, quantum.S),
+	"sdg":  fixed(1, quantum.SDagger),
+	"t":    fixed(1, quantum.T),
+	"tdg":  fixed(1, quantum.TDagger),
+	"sx":   fixed(1, quantum.SqrtX),
+	"sxdg": fixed(1, quantum.SqrtXDagger),
+	"rx": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.RotationX(p[0])
+	}},
+	"ry": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.RotationY(p[0])
+	}},
+	"rz": {1, 1, func(p []float64) *quantum.Gate {
+		return quantum.RotationZ(p[0])
+	}},
+	"cx":    fixed(2, quantum.CX),
+	"cy":    controlled(1, fixed(1, quantum.PauliY)),
+	"cz":    fixed(2, quantum.CZ),
+	"ch":    controlled(1, fixed(1, hadamard)),
+	"csx":   controlled(1, fixed(1, quantum.SqrtX)),
+	"swap":  fixed(2, quantum.Swap),
+	"ccx":   fixed(3, quantum.CCX),
+	"c3x":   controlled(3, fixed(1, quantum.PauliX)),
+	"c4x":   controlled(4, fixed(1, quantum.PauliX)),
+	"cswap": fixed(3, quantum.CSwap),
+	"crx": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.RotationX(p[0]), 1)
+	}},
+	"cry": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.RotationY(p[0]), 1)
+	}},
+	"crz": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.RotationZ(p[0]), 1)
+	}},
+	"cu1": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.Phase(p[0]), 1)
+	}},
+	"cp": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.Phase(p[0]), 1)
+	}},
+	"cu3": {3, 2, func(p []float64) *quantum.Gate {
+		return quantum.Controlled(quantum.U3(p[0], p[1], p[2]), 1)
+	}},
+	"cu": {4, 2, func(p []float64) *quantum.Gate {
+		return quantum.CU(p[0], p[1], p[2], p[3])
+	}},
+	"rxx": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.IsingXX(p[0])
+	}},
+	"rzz": {1, 2, func(p []float64) *quantum.Gate {
+		return quantum.IsingZZ(p[0])
+	}},
+}
+
+func hadamard() *quantum.Gate {
+	return quantum.NewHadamardGate(1)
+}
+
+// The gates of the OpenQASM 3 standard library stdgates.inc, which are those
+// of qelib1.inc under the same names, along with a few aliases.
+var stdGates = func() map[string]builtinGate {
+	gates := map[string]builtinGate{
+		"CX":     primitiveGates["CX"],
+		"phase":  qelib1Gates["p"],
+		"cphase": qelib1Gates["cp"],
+	}
+	for _, name := range []string{"p", "x", "y", "z", "h", "s", "sdg",
+		"t", "tdg", "sx", "rx", "ry", "rz", "cx", "cy", "cz", "cp",
+		"crx", "cry", "crz", "ch", "swap", "ccx", "cswap", "cu", "id",
+		"u1", "u2", "u3"} {
+		gates[name] = qelib1Gates[name]
+	}
+	return gates
+}()
@@ -0,0 +1,188 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The kinds of token produced by the lexer.
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	intToken
+	realToken
+	stringToken
+	symbolToken
+)
+
+// A token of an OpenQASM program, with the position at which it starts.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	col  int
+}
+
+// Describe a token for use in an error message.
+func (tok token) String() string {
+	if tok.kind == eofToken {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", tok.text)
+}
+
+// An Error describes a problem with an OpenQASM program, and where in the
+// source it was found. Lines and columns are numbered from 1.
+type Error struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("qasm: line %d, column %d: %s", err.Line, err.Col,
+		err.Msg)
+}
+
+// Symbols of more than one character, longest first so that e.g. "==" is
+// not read as two "=" symbols.
+var longSymbols = []string{"->", "==", "!=", "<=", ">=", "&&", "||", "**",
+	"++", "+=", "-=", "*=", "/="}
+
+// Split the source of a program into tokens, dropping whitespace and
+// comments. The final token is always an eofToken.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line, col := 1, 1
+	i := 0
+	// Move past n bytes of the source, keeping track of the position.
+	advance := func(n int) {
+		for ; n > 0; n-- {
+			if src[i] == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+			i++
+		}
+	}
+	for i < len(src) {
+		c := src[i]
+		start := token{line: line, col: col}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			advance(1)
+			continue
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				advance(1)
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, &Error{start.line, start.col,
+					"unterminated comment"}
+			}
+			advance(end + 4)
+			continue
+		case isLetter(c):
+			n := 1
+			for i+n < len(src) && (isLetter(src[i+n]) || isDigit(src[i+n])) {
+				n++
+			}
+			start.kind = identToken
+			start.text = src[i : i+n]
+		case isDigit(c) || c == '.' && i+1 < len(src) && isDigit(src[i+1]):
+			n := scanNumber(src[i:])
+			start.kind = intToken
+			start.text = src[i : i+n]
+			if strings.ContainsAny(start.text, ".eE") {
+				start.kind = realToken
+			}
+		case c == '"':
+			end := strings.IndexAny(src[i+1:], "\"\n")
+			if end < 0 || src[i+1+end] != '"' {
+				return nil, &Error{start.line, start.col,
+					"unterminated string"}
+			}
+			start.kind = stringToken
+			start.text = src[i+1 : i+1+end]
+			advance(end + 2)
+			tokens = append(tokens, start)
+			continue
+		default:
+			start.kind = symbolToken
+			start.text = src[i : i+1]
+			for _, symbol := range longSymbols {
+				if strings.HasPrefix(src[i:], symbol) {
+					start.text = symbol
+					break
+				}
+			}
+			if !strings.Contains("->=!<>+;,()[]{}*/%^@:&|", start.text[:1]) {
+				return nil, &Error{start.line, start.col,
+					fmt.Sprintf("unexpected character %q", c)}
+			}
+		}
+		advance(len(start.text))
+		tokens = append(tokens, start)
+	}
+	return append(tokens, token{kind: eofToken, line: line, col: col}), nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Return the length of the number at the start of s, which is either an
+// integer or a real such as 1.5, .5, 2. or 1e-3.
+func scanNumber(s string) int {
+	n := 0
+	for n < len(s) && isDigit(s[n]) {
+		n++
+	}
+	if n < len(s) && s[n] == '.' {
+		n++
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+	}
+	if n < len(s) && (s[n] == 'e' || s[n] == 'E') {
+		m := n + 1
+		if m < len(s) && (s[m] == '+' || s[m] == '-') {
+			m++
+		}
+		if m < len(s) && isDigit(s[m]) {
+			for m < len(s) && isDigit(s[m]) {
+				m++
+			}
+			n = m
+		}
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	src := "qreg q[2]; // comment\n/* block\ncomment */ U(-1.5e2, .5) q[0] -> c;"
+	expected := []token{
+		{identToken, "qreg", 1, 1},
+		{identToken, "q", 1, 6},
+		{symbolToken, "[", 1, 7},
+		{intToken, "2", 1, 8},
+		{symbolToken, "]", 1, 9},
+		{symbolToken, ";", 1, 10},
+		{identToken, "U", 3, 12},
+		{symbolToken, "(", 3, 13},
+		{symbolToken, "-", 3, 14},
+		{realToken, "1.5e2", 3, 15},
+		{symbolToken, ",", 3, 20},
+		{realToken, ".5", 3, 22},
+		{symbolToken, ")", 3, 24},
+		{identToken, "q", 3, 26},
+		{symbolToken, "[", 3, 27},
+		{intToken, "0", 3, 28},
+		{symbolToken, "]", 3, 29},
+		{symbolToken, "->", 3, 31},
+		{identToken, "c", 3, 34},
+		{symbolToken, ";", 3, 35},
+		{eofToken, "", 3, 36},
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("Got %d tokens, expected %d: %v", len(tokens),
+			len(expected), tokens)
+	}
+	for i, tok := range tokens {
+		if tok != expected[i] {
+			t.Errorf("Token %d = %+v, expected %+v.", i, tok,
+				expected[i])
+		}
+	}
+}
+
+func TestTokenize_Errors(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+	}{
+		{"qreg q[1];\n  $", 2, 3},
+		{"include \"qelib1.inc;\n", 1, 9},
+		{"/* never closed", 1, 1},
+	}
+	for _, test := range tests {
+		_, err := tokenize(test.src)
+		qasmErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Expected an *Error for %q, got %v.", test.src, err)
+			continue
+		}
+		if qasmErr.Line != test.line || qasmErr.Col != test.col {
+			t.Errorf("Error for %q at %d:%d, expected %d:%d.", test.src,
+				qasmErr.Line, qasmErr.Col, test.line, test.col)
+		}
+	}
+}
@@ -0,0 +1,979 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"quantum"
+)
+
+// A reference to a single qubit or bit, or to a whole register, as an
+// argument of a statement. The index is -1 for a whole register.
+type argument struct {
+	tok   token
+	reg   *Register
+	index int
+}
+
+// A gate defined by the program, with a gate or opaque statement.
+type gateDef struct {
+	params []string
+	qargs  []string
+	body   []gateStmt
+	opaque bool
+}
+
+// A statement in the body of a gate definition. Its qubits are indices into
+// the qargs of the definition.
+type gateStmt struct {
+	tok       token
+	modifiers []modifier
+	params    []expr
+	qubits    []int
+}
+
+// A modifier of a gate in OpenQASM 3, such as "ctrl(2) @" or "inv @". The
+// argument is nil if none is given.
+type modifier struct {
+	tok token
+	arg expr
+}
+
+// A modifier with its argument evaluated: the number of controls for ctrl
+// and negctrl, or the exponent for pow.
+type gateModifier struct {
+	kind string
+	n    int
+}
+
+// The state of the parser for one program.
+type parser struct {
+	tokens  []token
+	pos     int
+	version int
+
+	// The registers, indexed by name, and the numbers of qubits and bits
+	// they hold in total.
+	qregs     map[string]*Register
+	cregs     map[string]*Register
+	program   *Program
+	numQubits int
+	numClbits int
+
+	builtins map[string]builtinGate
+	gates    map[string]*gateDef
+
+	// The classical variables of an OpenQASM 3 program, with their types
+	// and which of them are constants.
+	vars   map[string]float64
+	types  map[string]string
+	consts map[string]bool
+
+	// Whether a gate definition is being parsed, in which only constants
+	// may be used.
+	inGateDef bool
+
+	// The condition on the classical bits under which the statements being
+	// parsed are run, and whether they are skipped entirely because they
+	// are in a branch which is never taken.
+	condition *quantum.Condition
+	skip      bool
+
+	// How deeply the statement being parsed is nested in blocks.
+	depth int
+
+	// How deeply the expression being parsed is nested.
+	exprDepth int
+
+	// The number of operations that gates have been expanded into so far,
+	// which is limited to maxOperations.
+	numExpanded int
+
+	// The operations of the program, with their targets numbered across
+	// all the quantum registers.
+	ops []quantum.Operation
+}
+
+// Parse the source of an OpenQASM 2.0 or 3 program, according to the version
+// given in its header. Gates defined by the program are expanded into the
+// builtin gates they are made of, and loops are unrolled, so the resulting
+// circuit contains only gates which the simulator can apply. The error, if
+// any, is an *Error giving the position of the problem.
+func Parse(src string) (program *Program, err error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		tokens:   tokens,
+		qregs:    make(map[string]*Register),
+		cregs:    make(map[string]*Register),
+		program:  &Program{},
+		builtins: make(map[string]builtinGate),
+		gates:    make(map[string]*gateDef),
+		vars:     make(map[string]float64),
+		types:    make(map[string]string),
+		consts:   make(map[string]bool),
+	}
+
+	// Errors are raised by panicking with an *Error, which saves checking
+	// for an error after every token.
+	defer func() {
+		if r := recover(); r != nil {
+			qasmErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			program, err = nil, qasmErr
+		}
+	}()
+	p.parseHeader()
+	primitives := primitiveGates
+	if p.version == 3 {
+		primitives = primitiveGates3
+	}
+	for name, gate := range primitives {
+		p.builtins[name] = gate
+	}
+	for p.peek().kind != eofToken {
+		if p.version == 3 {
+			p.parseStatement3()
+		} else {
+			p.parseStatement()
+		}
+	}
+
+	circuit := quantum.NewCircuit(p.numQubits).ReserveClbits(p.numClbits)
+	for _, op := range p.ops {
+		circuit.AppendOperation(op)
+	}
+	p.program.Circuit = circuit
+	return p.program, nil
+}
+
+// Raise an error at the position of a token.
+func (p *parser) errorf(tok token, format string, args ...interface{}) {
+	panic(&Error{tok.line, tok.col, fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// Look at the token after the next one.
+func (p *parser) peek2() token {
+	if p.tokens[p.pos].kind == eofToken {
+		return p.tokens[p.pos]
+	}
+	return p.tokens[p.pos+1]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != eofToken {
+		p.pos++
+	}
+	return tok
+}
+
+// Consume the next token if it is the given symbol or keyword.
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == symbolToken || tok.kind == identToken) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// Consume the next token, which must be the given symbol or keyword.
+func (p *parser) expect(text string) token {
+	tok := p.peek()
+	if !p.accept(text) {
+		p.errorf(tok, "expected %q, found %v", text, tok)
+	}
+	return tok
+}
+
+// Consume the next token, which must be of the given kind.
+func (p *parser) expectKind(kind tokenKind, what string) token {
+	tok := p.next()
+	if tok.kind != kind {
+		p.errorf(tok, "expected %s, found %v", what, tok)
+	}
+	return tok
+}
+
+func (p *parser) expectIdent() token {
+	return p.expectKind(identToken, "identifier")
+}
+
+// Consume a non-negative integer literal, and return its value.
+func (p *parser) expectInt() (int, token) {
+	tok := p.expectKind(intToken, "integer")
+	n, err := strconv.Atoi(tok.text)
+	if err != nil {
+		p.errorf(tok, "integer %s is out of range", tok.text)
+	}
+	return n, tok
+}
+
+// Parse an integer, which in OpenQASM 3 may be given by an expression.
+func (p *parser) parseInt() (int, token) {
+	if p.version != 3 {
+		return p.expectInt()
+	}
+	tok := p.peek()
+	value := p.parseExpr(nil).eval(nil)
+	if value != math.Trunc(value) || math.Abs(value) > math.MaxInt32 {
+		p.errorf(tok, "expected integer, found %v", value)
+	}
+	return int(value), tok
+}
+
+func (p *parser) parseHeader() {
+	p.expect("OPENQASM")
+	tok := p.next()
+	switch tok.text {
+	case "2.0", "2":
+		p.version = 2
+	case "3.0", "3":
+		p.version = 3
+	default:
+		p.errorf(tok, "unsupported OpenQASM version %v", tok)
+	}
+	p.expect(";")
+}
+
+// Append an operation to the program, subject to the current condition.
+func (p *parser) emit(op quantum.Operation) {
+	if p.skip {
+		return
+	}
+	op.Condition = p.condition
+	p.ops = append(p.ops, op)
+}
+
+// Parse a statement of an OpenQASM 2.0 program.
+func (p *parser) parseStatement() {
+	tok := p.peek()
+	switch {
+	case p.accept("include"):
+		p.parseInclude("qelib1.inc", qelib1Gates)
+	case p.accept("qreg"):
+		p.parseRegister(true, false)
+	case p.accept("creg"):
+		p.parseRegister(false, false)
+	case p.accept("gate"):
+		p.parseGateDef(false)
+	case p.accept("opaque"):
+		p.parseGateDef(true)
+	case p.accept("if"):
+		p.expect("(")
+		creg := p.parseArgument(p.cregs, "classical")
+		if creg.index >= 0 {
+			p.errorf(creg.tok, "expected classical register, "+
+				"found bit of %q", creg.reg.Name)
+		}
+		p.expect("==")
+		value, _ := p.expectInt()
+		p.expect(")")
+		p.condition = creg.condition(value)
+		p.parseOperation()
+		p.condition = nil
+	default:
+		if tok.kind != identToken {
+			p.errorf(tok, "expected statement, found %v", tok)
+		}
+		p.parseOperation()
+	}
+}
+
+// Parse an include statement, which may only include the standard header
+// with the given name.
+func (p *parser) parseInclude(header string, gates map[string]builtinGate) {
+	file := p.expectKind(stringToken, "file name")
+	if file.text != header {
+		p.errorf(file, "cannot include %q, only %s is supported",
+			file.text, header)
+	}
+	p.expect(";")
+	for name, gate := range gates {
+		p.builtins[name] = gate
+	}
+}
+
+// The most qubits a program may declare in total, since the simulator needs
+// 2^n amplitudes for n qubits, the most bits of a classical register, whose
+// value must fit in an int, and the most operations that the gates of a
+// program may expand into, since each level of nested gate definitions can
+// multiply their number. Expressions are parsed recursively, so their nesting
+// is limited to maxExprDepth levels to keep the parser from overflowing its
+// stack.
+const (
+	maxQubits       = 30
+	maxRegisterBits = 63
+	maxOperations   = 1 << 20
+	maxExprDepth    = 1000
+)
+
+// Parse the declaration of a register, and allocate its bits after those of
+// the registers of the same kind which were declared before it. The size
+// comes before the name in the OpenQASM 3 declarations "qubit[n] q" and
+// "bit[n] c", where it may be omitted for a single bit, and after the name in
+// "qreg q[n]" and "creg c[n]".
+func (p *parser) parseRegister(isQuantum, sizeFirst bool) {
+	size := 1
+	if sizeFirst && p.peek().text == "[" {
+		size = p.parseRegisterSize()
+	}
+	name := p.expectIdent()
+	if !sizeFirst {
+		size = p.parseRegisterSize()
+	}
+	p.expect(";")
+	p.declare(name)
+	if p.depth > 0 {
+		p.errorf(name, "register %q must be declared at the top level",
+			name.text)
+	}
+	if isQuantum {
+		if size > maxQubits-p.numQubits {
+			p.errorf(name, "register %q takes the program past "+
+				"the limit of %d qubits", name.text, maxQubits)
+		}
+		reg := &Register{name.text, p.numQubits, size}
+		p.qregs[name.text] = reg
+		p.program.QRegs = append(p.program.QRegs, *reg)
+		p.numQubits += size
+	} else {
+		if size > maxRegisterBits {
+			p.errorf(name, "classical register %q has more "+
+				"than %d bits", name.text, maxRegisterBits)
+		}
+		reg := &Register{name.text, p.numClbits, size}
+		p.cregs[name.text] = reg
+		p.program.CRegs = append(p.program.CRegs, *reg)
+		p.numClbits += size
+	}
+}
+
+// Parse the "[size]" of a register.
+func (p *parser) parseRegisterSize() int {
+	p.expect("[")
+	size, sizeTok := p.parseInt()
+	p.expect("]")
+	if size <= 0 {
+		p.errorf(sizeTok, "register must have at least one bit")
+	}
+	return size
+}
+
+// Verify that a name about to be declared is not already in use.
+func (p *parser) declare(name token) {
+	_, isVar := p.vars[name.text]
+	if p.qregs[name.text] != nil || p.cregs[name.text] != nil || isVar {
+		p.errorf(name, "%q is already declared", name.text)
+	}
+}
+
+// Parse a quantum operation: a measurement, reset, barrier or gate.
+func (p *parser) parseOperation() {
+	tok := p.peek()
+	switch {
+	case p.accept("measure"):
+		qubit := p.parseArgument(p.qregs, "quantum")
+		p.expect("->")
+		clbit := p.parseArgument(p.cregs, "classical")
+		p.expect(";")
+		p.measure(tok, qubit, clbit)
+	case p.accept("reset"):
+		qubit := p.parseArgument(p.qregs, "quantum")
+		p.expect(";")
+		for i := 0; i < p.broadcastSize(tok, qubit); i++ {
+			p.emit(quantum.Operation{
+				Kind:    quantum.ResetOp,
+				Targets: []int{qubit.bit(i)}})
+		}
+	case p.accept("barrier"):
+		var args []argument
+		if p.version != 3 || p.peek().text != ";" {
+			args = p.parseArguments()
+		}
+		p.expect(";")
+		p.barrier(tok, args)
+	default:
+		p.parseGateCall()
+	}
+}
+
+// Append the measurements of qubits into classical bits.
+func (p *parser) measure(tok token, qubit, clbit argument) {
+	for i := 0; i < p.broadcastSize(tok, qubit, clbit); i++ {
+		p.emit(quantum.Operation{
+			Kind:    quantum.MeasureOp,
+			Targets: []int{qubit.bit(i)},
+			Clbit:   clbit.bit(i)})
+	}
+}
+
+// Append a barrier across the given qubits, or all the qubits if there are
+// no arguments.
+func (p *parser) barrier(tok token, args []argument) {
+	var targets []int
+	for _, arg := range args {
+		for i := 0; i < p.broadcastSize(tok, arg); i++ {
+			targets = append(targets, arg.bit(i))
+		}
+	}
+	if args == nil {
+		for qubit := 0; qubit < p.numQubits; qubit++ {
+			targets = append(targets, qubit)
+		}
+	}
+	p.emit(quantum.Operation{Kind: quantum.BarrierOp, Targets: targets})
+}
+
+// Parse the application of a gate to qubits or whole registers. A gate
+// applied to registers is applied to each of their qubits in turn.
+func (p *parser) parseGateCall() {
+	var modifiers []gateModifier
+	var modifierToks []token
+	if p.version == 3 {
+		for _, m := range p.parseModifiers(nil) {
+			modifiers = append(modifiers, p.evalModifier(m, nil))
+			modifierToks = append(modifierToks, m.tok)
+		}
+	}
+	name := p.expectIdent()
+	numParams, numQubits := p.gateSignature(name)
+	var params []float64
+	for _, param := range p.parseParams(nil) {
+		params = append(params, param.eval(nil))
+	}
+	var args []argument
+	if numQubits > 0 || p.peek().text != ";" {
+		args = p.parseArguments()
+	}
+	p.expect(";")
+	if len(params) != numParams {
+		p.errorf(name, "gate %q takes %d parameters, given %d",
+			name.text, numParams, len(params))
+	}
+	if expected := numQubits + numControls(modifiers); len(args) != expected {
+		p.errorf(name, "gate %q acts on %d qubits, given %d",
+			name.text, expected, len(args))
+	}
+
+	n := p.broadcastSize(name, args...)
+	for i := 0; i < n; i++ {
+		qubits := make([]int, len(args))
+		for j, arg := range args {
+			qubits[j] = arg.bit(i)
+			for _, previous := range qubits[:j] {
+				if previous == qubits[j] {
+					p.errorf(arg.tok, "qubit %d of %q is "+
+						"used more than once", qubits[j]-
+						arg.reg.Offset, arg.reg.Name)
+				}
+			}
+		}
+		for _, op := range p.expandGate(name, params, modifiers, qubits) {
+			p.emit(op)
+		}
+	}
+}
+
+// Parse the parameters of a gate, if any, in parentheses.
+func (p *parser) parseParams(params []string) []expr {
+	var exprs []expr
+	if p.accept("(") && !p.accept(")") {
+		for {
+			exprs = append(exprs, p.parseExpr(params))
+			if p.accept(")") {
+				break
+			}
+			p.expect(",")
+		}
+	}
+	return exprs
+}
+
+// Look up a gate by name, and return the number of parameters and qubits
+// that it takes.
+func (p *parser) gateSignature(name token) (numParams, numQubits int) {
+	if gate, ok := p.builtins[name.text]; ok {
+		return gate.numParams, gate.numQubits
+	}
+	if def, ok := p.gates[name.text]; ok {
+		return len(def.params), len(def.qargs)
+	}
+	p.errorf(name, "undefined gate %q", name.text)
+	return
+}
+
+// The number of control qubits added to a gate by its modifiers.
+func numControls(modifiers []gateModifier) int {
+	n := 0
+	for _, m := range modifiers {
+		if m.kind == "ctrl" || m.kind == "negctrl" {
+			n += m.n
+		}
+	}
+	return n
+}
+
+// Return the operations for applying a gate with the given modifiers. The
+// qubits of the controls added by the modifiers come first, in the order of
+// the modifiers.
+func (p *parser) expandGate(name token, params []float64,
+	modifiers []gateModifier, qubits []int) []quantum.Operation {
+	end := numControls(modifiers)
+	ops := p.expandBase(name, params, qubits[end:])
+
+	// Apply the innermost modifier first.
+	for i := len(modifiers) - 1; i >= 0; i-- {
+		m := modifiers[i]
+		switch m.kind {
+		case "inv":
+			ops = invert(ops)
+		case "pow":
+			once := ops
+			times := m.n
+			if m.n < 0 {
+				once, times = invert(ops), -m.n
+			}
+			// The operations of once have already been counted.
+			if len(once) > 0 {
+				if times > maxOperations/len(once) {
+					p.errorf(name, "program expands to more "+
+						"than %d operations", maxOperations)
+				}
+				if times > 0 {
+					p.countOps(name, (times-1)*len(once))
+				}
+			}
+			ops = nil
+			for j := 0; j < times; j++ {
+				ops = append(ops, once...)
+			}
+		default:
+			values := make([]int, m.n)
+			if m.kind == "ctrl" {
+				for j := range values {
+					values[j] = 1
+				}
+			}
+			controls := qubits[end-m.n : end]
+			end -= m.n
+			for j, op := range ops {
+				if op.Kind != quantum.GateOp {
+					continue
+				}
+				op.Gate = quantum.ControlledOn(op.Gate, values...)
+				op.Targets = append(append([]int(nil), controls...),
+					op.Targets...)
+				ops[j] = op
+			}
+		}
+	}
+	return ops
+}
+
+// Reverse a sequence of operations, replacing each gate by its adjoint, so
+// that the sequence is undone.
+func invert(ops []quantum.Operation) []quantum.Operation {
+	inverse := make([]quantum.Operation, len(ops))
+	for i, op := range ops {
+		if op.Kind == quantum.GateOp {
+			op.Gate = quantum.Adjoint(op.Gate)
+		}
+		inverse[len(ops)-1-i] = op
+	}
+	return inverse
+}
+
+// Return the operations for applying a gate without modifiers. A gate defined
+// by the program is expanded, and each of the resulting operations is
+// labelled with its name, so that an operation is labelled with the
+// outermost defined gate that it is part of.
+func (p *parser) expandBase(name token, params []float64,
+	qubits []int) []quantum.Operation {
+	if gate, ok := p.builtins[name.text]; ok {
+		p.countOps(name, 1)
+		return []quantum.Operation{{
+			Kind:    quantum.GateOp,
+			Gate:    gate.new(params),
+			Targets: qubits}}
+	}
+
+	def := p.gates[name.text]
+	if def.opaque {
+		p.errorf(name, "opaque gate %q cannot be simulated", name.text)
+	}
+	env := make(map[string]float64, len(params))
+	for i, param := range def.params {
+		env[param] = params[i]
+	}
+	var ops []quantum.Operation
+	for _, stmt := range def.body {
+		targets := make([]int, len(stmt.qubits))
+		for i, qubit := range stmt.qubits {
+			targets[i] = qubits[qubit]
+		}
+		if stmt.tok.text == "barrier" {
+			p.countOps(stmt.tok, 1)
+			ops = append(ops, quantum.Operation{
+				Kind:    quantum.BarrierOp,
+				Targets: targets})
+			continue
+		}
+		values := make([]float64, len(stmt.params))
+		for i, param := range stmt.params {
+			values[i] = param.eval(env)
+		}
+		var modifiers []gateModifier
+		for _, m := range stmt.modifiers {
+			modifiers = append(modifiers, p.evalModifier(m, env))
+		}
+		_, numQubits := p.gateSignature(stmt.tok)
+		if expected := numQubits + numControls(modifiers); len(targets) != expected {
+			p.errorf(stmt.tok, "gate %q acts on %d qubits, given %d",
+				stmt.tok.text, expected, len(targets))
+		}
+		ops = append(ops, p.expandGate(stmt.tok, values, modifiers,
+			targets)...)
+	}
+	for i := range ops {
+		ops[i].Label = name.text
+	}
+	return ops
+}
+
+// Count operations that a gate has been expanded into, and raise an error as
+// soon as the program expands to more than maxOperations of them, before
+// they can use up the memory.
+func (p *parser) countOps(tok token, n int) {
+	if n > maxOperations-p.numExpanded {
+		p.errorf(tok, "program expands to more than %d operations",
+			maxOperations)
+	}
+	p.numExpanded += n
+}
+
+// Parse a gate or opaque definition.
+func (p *parser) parseGateDef(opaque bool) {
+	name := p.expectIdent()
+	if _, ok := p.builtins[name.text]; ok || p.gates[name.text] != nil {
+		p.errorf(name, "gate %q is already defined", name.text)
+	}
+	if p.depth > 0 {
+		p.errorf(name, "gate %q must be defined at the top level",
+			name.text)
+	}
+	def := &gateDef{opaque: opaque}
+	if p.accept("(") && !p.accept(")") {
+		def.params = p.parseIdentList()
+		p.expect(")")
+	}
+	def.qargs = p.parseIdentList()
+
+	if opaque {
+		p.expect(";")
+	} else {
+		p.expect("{")
+		p.inGateDef = true
+		for !p.accept("}") {
+			def.body = append(def.body, p.parseGateStmt(def))
+		}
+		p.inGateDef = false
+	}
+	p.gates[name.text] = def
+}
+
+// Parse a list of distinct identifiers, such as the parameters of a gate.
+func (p *parser) parseIdentList() []string {
+	var names []string
+	for {
+		name := p.expectIdent()
+		for _, previous := range names {
+			if previous == name.text {
+				p.errorf(name, "%q is declared more than once",
+					name.text)
+			}
+		}
+		names = append(names, name.text)
+		if !p.accept(",") {
+			return names
+		}
+	}
+}
+
+// Parse a statement in the body of a gate definition.
+func (p *parser) parseGateStmt(def *gateDef) gateStmt {
+	var stmt gateStmt
+	if p.version == 3 {
+		stmt.modifiers = p.parseModifiers(def.params)
+	}
+	stmt.tok = p.expectIdent()
+	numQubits := -1
+	if stmt.tok.text != "barrier" || stmt.modifiers != nil {
+		var numParams int
+		numParams, numQubits = p.gateSignature(stmt.tok)
+		stmt.params = p.parseParams(def.params)
+		if len(stmt.params) != numParams {
+			p.errorf(stmt.tok, "gate %q takes %d parameters, "+
+				"given %d", stmt.tok.text, numParams,
+				len(stmt.params))
+		}
+	}
+	for numQubits != 0 || p.peek().text != ";" {
+		arg := p.expectIdent()
+		qubit := indexOf(def.qargs, arg.text)
+		if qubit < 0 {
+			p.errorf(arg, "%q is not an argument of the gate",
+				arg.text)
+		}
+		if indexOfInt(stmt.qubits, qubit) >= 0 {
+			p.errorf(arg, "%q is used more than once", arg.text)
+		}
+		stmt.qubits = append(stmt.qubits, qubit)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(";")
+	// The number of controls depends on the gate's parameters, so it can
+	// only be checked when the gate is applied.
+	if numQubits >= 0 && stmt.modifiers == nil && len(stmt.qubits) != numQubits {
+		p.errorf(stmt.tok, "gate %q acts on %d qubits, given %d",
+			stmt.tok.text, numQubits, len(stmt.qubits))
+	}
+	return stmt
+}
+
+// Parse a comma-separated list of qubits or quantum registers.
+func (p *parser) parseArguments() []argument {
+	args := []argument{p.parseArgument(p.qregs, "quantum")}
+	for p.accept(",") {
+		args = append(args, p.parseArgument(p.qregs, "quantum"))
+	}
+	return args
+}
+
+// Parse a reference to a register, or to a single bit of it. In OpenQASM 3, a
+// negative index counts back from the end of the register.
+func (p *parser) parseArgument(regs map[string]*Register, kind string) argument {
+	name := p.expectIdent()
+	arg := argument{tok: name, reg: regs[name.text], index: -1}
+	if arg.reg == nil {
+		p.errorf(name, "%q is not a %s register", name.text, kind)
+	}
+	if p.accept("[") {
+		index, indexTok := p.parseInt()
+		if index < 0 {
+			index += arg.reg.Size
+		}
+		if index < 0 || index >= arg.reg.Size {
+			p.errorf(indexTok, "index %s is out of range for "+
+				"register %q of size %d", indexTok.text,
+				name.text, arg.reg.Size)
+		}
+		arg.index = index
+		p.expect("]")
+	}
+	return arg
+}
+
+// Return the number of times an operation on the given arguments is
+// repeated: the size of the whole registers among them, which must all be
+// the same, or 1 if there are none.
+func (p *parser) broadcastSize(tok token, args ...argument) int {
+	size := 1
+	var first *argument
+	for i := range args {
+		if args[i].index >= 0 {
+			continue
+		}
+		if first != nil && args[i].reg.Size != size {
+			p.errorf(args[i].tok, "register %q of size %d does not "+
+				"match register %q of size %d", args[i].reg.Name,
+				args[i].reg.Size, first.reg.Name, size)
+		}
+		first, size = &args[i], args[i].reg.Size
+	}
+	return size
+}
+
+// The number of the bit referred to by an argument in the i-th repetition
+// of an operation.
+func (arg argument) bit(i int) int {
+	if arg.index >= 0 {
+		return arg.reg.Offset + arg.index
+	}
+	return arg.reg.Offset + i
+}
+
+// The condition that a classical argument has the given value. A whole
+// register is read with its bit 0 as the least significant bit.
+func (arg argument) condition(value int) *quantum.Condition {
+	condition := &quantum.Condition{Value: value}
+	if arg.index >= 0 {
+		condition.Clbits = []int{arg.bit(0)}
+		return condition
+	}
+	for i := 0; i < arg.reg.Size; i++ {
+		condition.Clbits = append(condition.Clbits, arg.bit(i))
+	}
+	return condition
+}
+
+// Parse an expression. Identifiers other than constants such as pi must be
+// among params, which are kept symbolic, or be classical variables, which
+// are replaced by their current values.
+func (p *parser) parseExpr(params []string) expr {
+	left := p.parseTerm(params)
+	for {
+		tok := p.peek()
+		if !p.accept("+") && !p.accept("-") {
+			return left
+		}
+		left = &binaryExpr{tok.text, left, p.parseTerm(params), tok}
+	}
+}
+
+func (p *parser) parseTerm(params []string) expr {
+	left := p.parseUnary(params)
+	for {
+		tok := p.peek()
+		if !p.accept("*") && !p.accept("/") &&
+			!(p.version == 3 && p.accept("%")) {
+			return left
+		}
+		left = &binaryExpr{tok.text, left, p.parseUnary(params), tok}
+	}
+}
+
+// Every level of nesting of an expression, whether in parentheses, a function
+// call, a power or a negation, passes through here, so this is where its
+// depth is counted.
+func (p *parser) parseUnary(params []string) expr {
+	tok := p.peek()
+	p.exprDepth++
+	if p.exprDepth > maxExprDepth {
+		p.errorf(tok, "expression is nested more than %d levels deep",
+			maxExprDepth)
+	}
+	var e expr
+	switch {
+	case p.accept("-"):
+		e = &unaryExpr{"-", p.parseUnary(params)}
+	case p.accept("+"):
+		e = p.parseUnary(params)
+	default:
+		e = p.parsePower(params)
+	}
+	p.exprDepth--
+	return e
+}
+
+// Exponentiation, written ^ in OpenQASM 2.0 and ** in OpenQASM 3, binds more
+// tightly than negation, and is right associative, so -2^2^3 is -(2^(2^3)).
+func (p *parser) parsePower(params []string) expr {
+	base := p.parsePrimary(params)
+	power := "^"
+	if p.version == 3 {
+		power = "**"
+	}
+	if tok := p.peek(); p.accept(power) {
+		return &binaryExpr{"^", base, p.parseUnary(params), tok}
+	}
+	return base
+}
+
+// The constants which may appear in an expression.
+var exprConsts = map[string]float64{
+	"pi": math.Pi,
+
+	// These are only in OpenQASM 3.
+	"tau":   2 * math.Pi,
+	"euler": math.E,
+	"true":  1,
+	"false": 0,
+}
+
+func (p *parser) parsePrimary(params []string) expr {
+	tok := p.next()
+	switch tok.kind {
+	case intToken, realToken:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			p.errorf(tok, "invalid number %s", tok.text)
+		}
+		return numberExpr(value)
+	case identToken:
+		if value, ok := exprConsts[tok.text]; ok &&
+			(p.version == 3 || tok.text == "pi") {
+			return numberExpr(value)
+		}
+		if fn, ok := exprFuncs[tok.text]; ok {
+			p.expect("(")
+			arg := p.parseExpr(params)
+			p.expect(")")
+			return &callExpr{fn, arg, tok}
+		}
+		if indexOf(params, tok.text) >= 0 {
+			return identExpr(tok.text)
+		}
+		// Within a gate definition only constants may be used.
+		if value, ok := p.vars[tok.text]; ok &&
+			(!p.inGateDef || p.consts[tok.text]) {
+			return numberExpr(value)
+		}
+		p.errorf(tok, "undefined parameter %q", tok.text)
+	case symbolToken:
+		if tok.text == "(" {
+			e := p.parseExpr(params)
+			p.expect(")")
+			return e
+		}
+	}
+	p.errorf(tok, "expected expression, found %v", tok)
+	return nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfInt(list []int, n int) int {
+	for i, item := range list {
+		if item == n {
+			return i
+		}
+	}
+	return -1
+}
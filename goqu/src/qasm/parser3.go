@@ -0,0 +1,448 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"math"
+
+	"quantum"
+)
+
+// The types of classical variables in OpenQASM 3. The values of all of them
+// are held as float64, truncated to integers for the integer types.
+var classicalTypes = map[string]bool{
+	"int": true, "uint": true, "float": true, "angle": true, "bool": true,
+}
+
+// Parse a statement of an OpenQASM 3 program. Classical variables must have
+// values which are known when the program is parsed, so that loops can be
+// unrolled; only the conditions of if statements may depend on the results
+// of measurements.
+func (p *parser) parseStatement3() {
+	tok := p.peek()
+	switch {
+	case p.accept("include"):
+		p.parseInclude("stdgates.inc", stdGates)
+	case p.accept("qubit"):
+		p.parseRegister(true, true)
+	case p.accept("bit"):
+		p.parseRegister(false, true)
+	case p.accept("qreg"):
+		p.parseRegister(true, false)
+	case p.accept("creg"):
+		p.parseRegister(false, false)
+	case p.accept("gate"):
+		p.parseGateDef(false)
+	case p.accept("const"):
+		p.parseDeclaration(true)
+	case classicalTypes[tok.text]:
+		p.parseDeclaration(false)
+	case p.accept("if"):
+		p.parseIf()
+	case p.accept("for"):
+		p.parseFor()
+	case tok.kind == identToken && p.cregs[tok.text] != nil:
+		// A measurement such as "c[0] = measure q[0];".
+		clbit := p.parseArgument(p.cregs, "classical")
+		p.expect("=")
+		measure := p.expect("measure")
+		qubit := p.parseArgument(p.qregs, "quantum")
+		p.expect(";")
+		p.measure(measure, qubit, clbit)
+	case tok.kind == identToken && p.types[tok.text] != "":
+		p.parseAssignment()
+	default:
+		if tok.kind != identToken {
+			p.errorf(tok, "expected statement, found %v", tok)
+		}
+		p.parseOperation()
+	}
+}
+
+// Verify that the value of a classical variable may be changed here.
+func (p *parser) checkAssignable(name token) {
+	if p.condition != nil {
+		p.errorf(name, "cannot assign to %q under a condition on "+
+			"measurement results", name.text)
+	}
+}
+
+// Parse the declaration of a classical variable, such as "const float
+// theta = pi / 2;". A variable which is not initialised is 0.
+func (p *parser) parseDeclaration(isConst bool) {
+	typeTok := p.expectIdent()
+	if !classicalTypes[typeTok.text] {
+		p.errorf(typeTok, "unsupported type %v", typeTok)
+	}
+	if p.accept("[") {
+		p.parseInt()
+		p.expect("]")
+	}
+	name := p.expectIdent()
+	p.declare(name)
+	if p.depth > 0 {
+		p.errorf(name, "variable %q must be declared at the top level",
+			name.text)
+	}
+	p.checkAssignable(name)
+	value := 0.0
+	if p.accept("=") {
+		value = p.parseExpr(nil).eval(nil)
+	} else if isConst {
+		p.errorf(name, "constant %q must be initialised", name.text)
+	}
+	p.expect(";")
+	p.types[name.text] = typeTok.text
+	p.consts[name.text] = isConst
+	p.vars[name.text] = convert(typeTok.text, value)
+}
+
+// Convert a value to the given classical type.
+func convert(typeName string, value float64) float64 {
+	switch typeName {
+	case "int", "uint":
+		return math.Trunc(value)
+	case "bool":
+		if value != 0 {
+			return 1
+		}
+	}
+	return value
+}
+
+// Parse an assignment to a classical variable, such as "theta += pi;".
+func (p *parser) parseAssignment() {
+	name := p.expectIdent()
+	op := p.next()
+	value := p.parseExpr(nil).eval(nil)
+	p.expect(";")
+	if p.consts[name.text] {
+		p.errorf(name, "cannot assign to constant %q", name.text)
+	}
+	p.checkAssignable(name)
+	if p.skip {
+		return
+	}
+	old := p.vars[name.text]
+	switch op.text {
+	case "=":
+	case "+=":
+		value = old + value
+	case "-=":
+		value = old - value
+	case "*=":
+		value = old * value
+	case "/=":
+		value = old / value
+	default:
+		p.errorf(op, "expected assignment, found %v", op)
+	}
+	p.vars[name.text] = convert(p.types[name.text], value)
+}
+
+// Blocks are parsed recursively, so their nesting is limited to keep the
+// parser from overflowing its stack, as for expressions.
+const maxBlockDepth = 1000
+
+// Parse the statement or block which is the body of an if or for statement.
+func (p *parser) parseBody() {
+	p.depth++
+	if p.depth > maxBlockDepth {
+		p.errorf(p.peek(), "block is nested more than %d levels deep",
+			maxBlockDepth)
+	}
+	if p.accept("{") {
+		for !p.accept("}") {
+			if p.peek().kind == eofToken {
+				p.errorf(p.peek(), "expected \"}\", found %v",
+					p.peek())
+			}
+			p.parseStatement3()
+		}
+	} else {
+		p.parseStatement3()
+	}
+	p.depth--
+}
+
+// Parse a branch of an if statement, which is run under the given condition,
+// or skipped if taken is false or the condition contradicts the one that the
+// if statement is already under.
+func (p *parser) parseBranch(tok token, taken bool, condition *quantum.Condition) {
+	savedCondition, savedSkip := p.condition, p.skip
+	var holds bool
+	p.condition, holds = p.conjoin(tok, p.condition, condition)
+	p.skip = p.skip || !taken || !holds
+	p.parseBody()
+	p.condition, p.skip = savedCondition, savedSkip
+}
+
+// Parse an if statement. A condition on classical variables is decided when
+// the program is parsed, and one on classical bits becomes a condition on
+// the operations in the branch.
+func (p *parser) parseIf() {
+	tok := p.expect("(")
+	taken, condition := p.parseCondition()
+	p.expect(")")
+	p.parseBranch(tok, taken, condition)
+
+	elseTok := p.peek()
+	if !p.accept("else") {
+		return
+	}
+	switch {
+	case condition == nil || !taken:
+		p.parseBranch(elseTok, !taken, nil)
+	case len(condition.Clbits) == 1:
+		p.parseBranch(elseTok, true, &quantum.Condition{
+			Clbits: condition.Clbits,
+			Value:  1 - condition.Value})
+	default:
+		p.errorf(elseTok, "else is only supported for a condition on "+
+			"a single bit")
+	}
+}
+
+// Parse the condition of an if statement, which is a conjunction of terms
+// joined by &&. Return whether the terms on classical variables hold, and
+// the condition given by the terms on classical bits, if any.
+func (p *parser) parseCondition() (bool, *quantum.Condition) {
+	holds := true
+	var condition *quantum.Condition
+	for {
+		tok := p.peek()
+		if tok.text == "!" {
+			tok = p.peek2()
+		}
+		if tok.kind == identToken && p.cregs[tok.text] != nil {
+			var consistent bool
+			condition, consistent = p.conjoin(tok, condition,
+				p.parseBitCondition())
+			holds = consistent && holds
+		} else {
+			holds = p.parseComparison() && holds
+		}
+		if !p.accept("&&") {
+			return holds, condition
+		}
+	}
+}
+
+// Parse a condition on classical bits: "c == n" for a register, or "c[i]",
+// "!c[i]", "c[i] == b" or "c[i] != b" for a single bit.
+func (p *parser) parseBitCondition() *quantum.Condition {
+	negate := p.accept("!")
+	arg := p.parseArgument(p.cregs, "classical")
+	singleBit := arg.index >= 0 || arg.reg.Size == 1
+	value := 1
+	if !negate && (p.accept("==") || p.peek().text == "!=") {
+		negate = p.accept("!=")
+		value, _ = p.parseInt()
+	} else if !singleBit {
+		p.errorf(arg.tok, "expected comparison of register %q",
+			arg.reg.Name)
+	}
+	if negate {
+		if !singleBit || value < 0 || value > 1 {
+			p.errorf(arg.tok, "negation is only supported for a "+
+				"single bit")
+		}
+		value = 1 - value
+	}
+	return arg.condition(value)
+}
+
+// Parse a comparison of classical values, or a single value which holds if
+// it is non-zero.
+func (p *parser) parseComparison() bool {
+	left := p.parseExpr(nil).eval(nil)
+	op := p.peek()
+	switch {
+	case p.accept("=="), p.accept("!="), p.accept("<"), p.accept("<="),
+		p.accept(">"), p.accept(">="):
+	default:
+		return left != 0
+	}
+	right := p.parseExpr(nil).eval(nil)
+	switch op.text {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	}
+	return left >= right
+}
+
+// The conjunction of two conditions, either of which may be nil, and whether
+// it can hold at all. A bit tested by both conditions is only tested once,
+// and the conjunction cannot hold if they require different values of it. The
+// value of the conjunction must fit in an int, so it may test at most
+// maxRegisterBits bits.
+func (p *parser) conjoin(tok token, a, b *quantum.Condition) (*quantum.Condition, bool) {
+	if a == nil {
+		return b, true
+	}
+	if b == nil {
+		return a, true
+	}
+	condition := &quantum.Condition{
+		Clbits: append([]int(nil), a.Clbits...),
+		Value:  a.Value}
+	holds := true
+	for i, clbit := range b.Clbits {
+		value := (b.Value >> uint(i)) & 1
+		if j := indexOfInt(condition.Clbits, clbit); j >= 0 {
+			if (condition.Value>>uint(j))&1 != value {
+				holds = false
+			}
+			continue
+		}
+		if len(condition.Clbits) == maxRegisterBits {
+			p.errorf(tok, "condition tests more than %d bits",
+				maxRegisterBits)
+		}
+		condition.Value |= value << uint(len(condition.Clbits))
+		condition.Clbits = append(condition.Clbits, clbit)
+	}
+	return condition, holds
+}
+
+// Loops are unrolled as they are parsed, so this limits the memory they can
+// use: a range may have at most maxLoopIterations values, and no loop may
+// take the program past maxOperations operations.
+const maxLoopIterations = 1 << 20
+
+// Parse a for loop, such as "for int i in [0:3] { ... }", which is unrolled
+// by parsing its body once for each value of the loop variable.
+func (p *parser) parseFor() {
+	if classicalTypes[p.peek().text] {
+		p.next()
+		if p.accept("[") {
+			p.parseInt()
+			p.expect("]")
+		}
+	}
+	name := p.expectIdent()
+	p.declare(name)
+	p.expect("in")
+	values := p.parseRange()
+
+	start := p.pos
+	p.types[name.text] = "int"
+	if len(values) == 0 {
+		// Parse the body just to move past it.
+		p.vars[name.text] = 0
+		savedSkip := p.skip
+		p.skip = true
+		p.parseBody()
+		p.skip = savedSkip
+	}
+	for _, value := range values {
+		p.pos = start
+		p.vars[name.text] = value
+		p.parseBody()
+		if len(p.ops) > maxOperations {
+			p.errorf(name, "loop expands to more than %d operations",
+				maxOperations)
+		}
+	}
+	delete(p.vars, name.text)
+	delete(p.types, name.text)
+}
+
+// Parse the values of a loop variable: a range "[start:end]" or
+// "[start:step:end]", which includes end, or a set "{a, b, c}".
+func (p *parser) parseRange() []float64 {
+	var values []float64
+	if p.accept("{") {
+		for {
+			values = append(values, p.parseExpr(nil).eval(nil))
+			if p.accept("}") {
+				return values
+			}
+			p.expect(",")
+		}
+	}
+	p.expect("[")
+	start, _ := p.parseInt()
+	p.expect(":")
+	step := 1
+	end, endTok := p.parseInt()
+	if p.accept(":") {
+		step = end
+		end, _ = p.parseInt()
+	}
+	p.expect("]")
+	if step == 0 {
+		p.errorf(endTok, "range must have a non-zero step")
+	}
+	if count := (end-start)/step + 1; count > maxLoopIterations {
+		p.errorf(endTok, "range has %d values, more than the limit "+
+			"of %d", count, maxLoopIterations)
+	}
+	for i := start; step > 0 && i <= end || step < 0 && i >= end; i += step {
+		values = append(values, float64(i))
+	}
+	return values
+}
+
+// The modifiers which may be applied to a gate.
+var modifierNames = map[string]bool{
+	"ctrl": true, "negctrl": true, "inv": true, "pow": true,
+}
+
+// Parse the modifiers of a gate, such as "ctrl @ inv @".
+func (p *parser) parseModifiers(params []string) []modifier {
+	var modifiers []modifier
+	for {
+		tok := p.peek()
+		if tok.kind != identToken || !modifierNames[tok.text] {
+			return modifiers
+		}
+		p.next()
+		m := modifier{tok: tok}
+		if p.accept("(") {
+			m.arg = p.parseExpr(params)
+			p.expect(")")
+		}
+		p.expect("@")
+		modifiers = append(modifiers, m)
+	}
+}
+
+// Evaluate the argument of a modifier, which must be an integer.
+func (p *parser) evalModifier(m modifier, env map[string]float64) gateModifier {
+	n := 1
+	if m.arg != nil {
+		value := m.arg.eval(env)
+		if m.tok.text == "inv" || value != math.Trunc(value) {
+			p.errorf(m.tok, "bad argument %v for %s", value, m.tok.text)
+		}
+		n = int(value)
+	}
+	if (m.tok.text == "ctrl" || m.tok.text == "negctrl") && n < 1 {
+		p.errorf(m.tok, "%s needs at least one control", m.tok.text)
+	}
+	return gateModifier{m.tok.text, n}
+}
@@ -0,0 +1,282 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"strings"
+	"testing"
+
+	"quantum"
+)
+
+const header3 = "OPENQASM 3.0;\ninclude \"stdgates.inc\";\n"
+
+func TestParse3_Declarations(t *testing.T) {
+	program := mustParse(t, header3+`
+const int n = 2;
+qubit[n + 1] q;
+qubit r;
+bit[n] c;
+bit d;
+qreg s[2];
+`)
+	expectedQRegs := []Register{{"q", 0, 3}, {"r", 3, 1}, {"s", 4, 2}}
+	expectedCRegs := []Register{{"c", 0, 2}, {"d", 2, 1}}
+	if len(program.QRegs) != len(expectedQRegs) ||
+		len(program.CRegs) != len(expectedCRegs) {
+		t.Fatalf("Bad registers %v and %v.", program.QRegs,
+			program.CRegs)
+	}
+	for i, reg := range expectedQRegs {
+		if program.QRegs[i] != reg {
+			t.Errorf("Bad quantum register %+v, expected %+v.",
+				program.QRegs[i], reg)
+		}
+	}
+	for i, reg := range expectedCRegs {
+		if program.CRegs[i] != reg {
+			t.Errorf("Bad classical register %+v, expected %+v.",
+				program.CRegs[i], reg)
+		}
+	}
+}
+
+func TestParse3_Modifiers(t *testing.T) {
+	tests := []struct {
+		body  string
+		label int
+	}{
+		{"x q[0]; ctrl @ x q[0], q[1];", 3},
+		{"negctrl @ x q[0], q[1];", 2},
+		{"x q[1]; negctrl @ x q[0], q[1];", 0},
+		{"x q[0]; x q[1]; ctrl(2) @ x q[0], q[1], q[2];", 7},
+		{"x q[1]; ctrl @ negctrl @ x q[0], q[1], q[2];", 2},
+		{"x q[0]; ctrl @ negctrl @ x q[0], q[1], q[2];", 5},
+		{"h q[0]; s q[0]; inv @ s q[0]; h q[0];", 0},
+		{"h q[0]; inv @ sdg q[0]; inv @ sdg q[0]; h q[0];", 1},
+		{"pow(2) @ sx q[0];", 1},
+		{"h q[0]; pow(-2) @ s q[0]; h q[0];", 1},
+		{"h q[0]; pow(4) @ t q[0]; h q[0];", 1},
+		{"h q[0]; ctrl @ gphase(pi) q[0]; h q[0];", 1},
+		{"gphase(pi / 2); x q[2];", 4},
+		{"x q[0]; ctrl @ inv @ rx(-pi) q[0], q[1];", 3},
+		{"x q[0]; inv @ ctrl @ rx(-pi) q[0], q[1];", 3},
+		{"U(pi, 0, pi) q[1]; CX q[1], q[0];", 3},
+	}
+	for _, test := range tests {
+		verifyBasisState(t, header3+"qubit[3] q;\n"+test.body, test.label)
+	}
+
+	ops := mustParse(t, header3+"qubit[3] q;\nctrl(2) @ x q[2], q[0], q[1];").
+		Circuit.Operations()
+	if len(ops) != 1 || ops[0].Gate.Name() != "ccx" ||
+		ops[0].Targets[0] != 2 || ops[0].Targets[2] != 1 {
+		t.Errorf("Bad operations %+v, expected ccx.", ops)
+	}
+}
+
+func TestParse3_GateDefinitions(t *testing.T) {
+	src := header3 + `
+const float half = 0.5;
+gate fanout a, b, c {
+	ctrl @ x a, b;
+	ctrl @ x a, c;
+}
+gate twice(theta) a { pow(2) @ ry(theta * half) a; }
+qubit[4] q;
+twice(pi) q[3];
+fanout q[3], q[0], q[1];
+inv @ fanout q[3], q[1], q[2];
+ctrl @ fanout q[0], q[3], q[1], q[2];
+`
+	// q[3] is rotated to |1>, which fans out to q[0] and q[1]. The inverse
+	// fan-out flips q[1] back and q[2] on, and the controlled fan-out
+	// then flips q[1] and q[2] again.
+	verifyBasisState(t, src, 1<<0|1<<1|1<<3)
+}
+
+func TestParse3_Loops(t *testing.T) {
+	tests := []struct {
+		body  string
+		label int
+	}{
+		{"for int i in [0:2] { x q[i]; }", 7},
+		{"for i in [0:2:3] x q[i];", 5},
+		{"for uint i in {3, 1} { x q[i]; }", 10},
+		{"for int i in [3:-1:2] { x q[i]; }", 12},
+		{"for int i in [0:-1] { x q[i]; }", 0},
+		{"for int i in [0:2] { cx q[i], q[i + 1]; } x q[0];", 1},
+		{"x q[0]; for int i in [0:2] { cx q[i], q[i + 1]; }", 15},
+		{"for int i in [0:1] { for int j in [0:1] { x q[2 * i + j]; } }", 15},
+		{"for int i in [1:3] { if (i % 2 == 1) x q[i]; }", 10},
+		{"for int i in [0:3] { if (i >= 2 && i != 3) x q[i]; else z q[i]; }", 4},
+		{"for int i in [0:3] { x q[-1 - i]; }", 15},
+	}
+	for _, test := range tests {
+		verifyBasisState(t, header3+"qubit[4] q;\n"+test.body, test.label)
+	}
+}
+
+func TestParse3_ClassicalVariables(t *testing.T) {
+	src := header3 + `
+qubit[3] q;
+const float theta = pi / 2;
+int n = 1;
+float phi = theta * 2 ** 1;
+rx(phi) q[n];
+n += 1;
+n *= 1.5;
+ry(tau / 2) q[n - 3];
+bool b = 3;
+if (b) x q[2];
+`
+	verifyBasisState(t, src, 2|1|4)
+}
+
+func TestParse3_Conditions(t *testing.T) {
+	tests := []struct {
+		body  string
+		label int
+	}{
+		{"x q[0]; c[0] = measure q[0]; if (c[0] == 1) x q[1]; else x q[2];", 3},
+		{"c[0] = measure q[0]; if (c[0] == 1) x q[1]; else x q[2];", 4},
+		{"x q[0]; c[0] = measure q[0]; if (!c[0]) x q[1]; else { x q[2]; x q[3]; }", 13},
+		{"x q[0]; c[0] = measure q[0]; if (c[0] != 1) x q[1];", 1},
+		{"x q[0]; c[0] = measure q[0]; c[1] = measure q[1]; if (c == 1) x q[3];", 9},
+		{"x q[1]; c[1] = measure q[1]; if (c[0] == 0 && c[1] == 1) { if (c[1]) x q[3]; }", 10},
+		{"x q[1]; c[1] = measure q[1]; if (c[1] && false) x q[3]; else x q[2];", 6},
+		{"measure q[2] -> c[1]; if (c == 0) x q[0];", 1},
+		{"x q[0]; c[0] = measure q[0]; reset q[0]; if (c[0]) x q[1];", 2},
+		{"x q[0]; c[0] = measure q[0]; if (c[0]) { if (!c[0]) x q[1]; }", 1},
+		{"x q[0]; c[0] = measure q[0]; if (c[0] && !c[0]) x q[1]; else x q[2];", 5},
+	}
+	for _, test := range tests {
+		verifyBasisState(t, header3+"qubit[4] q;\nbit[2] c;\n"+test.body,
+			test.label)
+	}
+
+	ops := mustParse(t, header3+"qubit q;\nbit[2] c;\nif (c[1] == 1 && !c[0]) x q;").
+		Circuit.Operations()
+	condition := ops[0].Condition
+	if condition == nil || len(condition.Clbits) != 2 ||
+		condition.Clbits[0] != 1 || condition.Clbits[1] != 0 ||
+		condition.Value != 1 {
+		t.Errorf("Bad condition %+v.", condition)
+	}
+
+	// A bit tested by nested conditions is only listed once.
+	ops = mustParse(t, header3+"qubit q;\nbit[2] c;\nif (c[0]) if (c[1]) if (c[0]) x q;").
+		Circuit.Operations()
+	condition = ops[0].Condition
+	if condition == nil || len(condition.Clbits) != 2 || condition.Value != 3 {
+		t.Errorf("Bad condition %+v.", condition)
+	}
+}
+
+func TestParse3_Errors(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"OPENQASM 3.0;\nqubit q;\nh q;", 3, 1, `undefined gate "h"`},
+		{"OPENQASM 3.0;\nqubit[2] q;\nCX q[0], q[1];", 3, 1, `undefined gate "CX"`},
+		{header3 + "qubit q;\nfor int i in [0:1] { qubit r; }", 4, 28, "at the top level"},
+		{header3 + "qubit q;\nfor int i in [0:1] { int j = i; }", 4, 26, "at the top level"},
+		{header3 + "const int n = 1;\nn = 2;", 4, 1, "cannot assign to constant"},
+		{header3 + "qubit q;\nbit c;\nint n;\nif (c) n = 1;", 6, 8, "under a condition"},
+		{header3 + "qubit q;\nbit[2] c;\nif (c == 1) x q; else x q;", 5, 18, "single bit"},
+		{header3 + "qubit q;\nbit[2] c;\nif (c) x q;", 5, 5, "expected comparison"},
+		{header3 + "qubit q;\npow(0.5) @ x q;", 4, 1, "bad argument 0.5 for pow"},
+		{header3 + "qubit q;\nctrl(0) @ x q;", 4, 1, "at least one control"},
+		{header3 + "qubit[2] q;\nctrl @ x q[0];", 4, 8, "acts on 2 qubits, given 1"},
+		{header3 + "qubit[2] q;\ngate g a, b { ctrl @ x a; }\ng q[0], q[1];", 4, 22, "acts on 2 qubits, given 1"},
+		{header3 + "qubit[2] q;\nx q[1.5];", 4, 5, "expected integer"},
+		{header3 + "qubit[2] q;\nx q[-3];", 4, 5, "out of range"},
+		{header3 + "int i = 0;\ngate g a { rx(i) a; }", 4, 15, `undefined parameter "i"`},
+		{header3 + "qubit q;\nfor int i in [0:0:2] x q;", 4, 17, "non-zero step"},
+		{header3 + "qubit q;\nfor int i in [0:1] { x q;", 4, 26, `expected "}"`},
+		{header3 + "qubit q;\nfor int i in [0:100000000] x q;", 4, 17, "more than the limit"},
+		{header3 + "qubit q;\nint n = 5 % 0;", 4, 11, "division by zero"},
+		{header3 + "qubit[1] q;\npow(100000000) @ x q[0];", 4, 18, "more than 1048576 operations"},
+		{header3 + "qubit[1] q;\npow(-100000000) @ x q[0];", 4, 19, "more than 1048576 operations"},
+		{header3 + "qubit[1] q;\ngate g a { pow(1000) @ x a; }\npow(2000) @ g q[0];", 5, 13, "more than 1048576 operations"},
+		{header3 + "bit[64] c;", 3, 9, "more than 63 bits"},
+		{header3 + "qubit q;\nbit c;\n" + strings.Repeat("if (c) ", 40000) + "x q;", 5, 7008, "nested more than 1000 levels"},
+		{header3 + "qubit q;\n" + strings.Repeat("if (true) { ", 2000), 4, 12011, "nested more than 1000 levels"},
+		{header3 + "qubit q;\nbit[63] c;\nbit[2] d;\nif (c == 0 && d == 1) x q;", 6, 15, "more than 63 bits"},
+		{header3 + "qubit q;\nbit[63] c;\nbit d;\nif (c == 0) { if (d) x q; }", 6, 18, "more than 63 bits"},
+		{header3 + "qubit q;\nfor int i in [0:1023] { for int j in [0:1023] { x q; x q; } }", 4, 49, "more than 1048576 operations"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.src)
+		qasmErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Expected an *Error for %q, got %v.", test.src, err)
+			continue
+		}
+		if qasmErr.Line != test.line || qasmErr.Col != test.col ||
+			!strings.Contains(qasmErr.Msg, test.msg) {
+			t.Errorf("Error for %q = %v, expected %q at line %d, "+
+				"column %d.", test.src, err, test.msg, test.line,
+				test.col)
+		}
+	}
+}
+
+// Programs from the repository's qiskit examples should be accepted.
+func TestParse3_QiskitOutput(t *testing.T) {
+	program := mustParse(t, `OPENQASM 3.0;
+include "stdgates.inc";
+qubit[3] q0;
+p(pi) q0[0];
+x q0[0];
+y q0[0];
+z q0[0];
+h q0[0];
+s q0[0];
+sdg q0[0];
+t q0[0];
+tdg q0[0];
+sx q0[0];
+rx(pi) q0[0];
+ry(pi) q0[0];
+rz(pi) q0[0];
+cx q0[0], q0[1];
+cy q0[0], q0[1];
+cz q0[0], q0[1];
+cp(pi) q0[0], q0[1];
+crx(pi) q0[0], q0[1];
+cry(pi) q0[0], q0[1];
+crz(pi) q0[0], q0[1];
+ch q0[0], q0[1];
+swap q0[0], q0[1];
+ccx q0[0], q0[1], q0[2];
+cswap q0[0], q0[1], q0[2];
+cu(pi, pi, pi, pi) q0[0], q0[1];
+id q0[0];
+`)
+	if n := len(program.Circuit.Operations()); n != 26 {
+		t.Errorf("Got %d operations, expected 26.", n)
+	}
+	for _, op := range program.Circuit.Operations() {
+		if op.Kind != quantum.GateOp {
+			t.Errorf("Bad operation %+v.", op)
+		}
+	}
+}
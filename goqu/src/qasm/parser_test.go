@@ -0,0 +1,276 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"quantum"
+)
+
+const header = "OPENQASM 2.0;\ninclude \"qelib1.inc\";\n"
+
+func mustParse(t *testing.T, src string) *Program {
+	program, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return program
+}
+
+// Run a program and verify that the register ends up in the given basis
+// state.
+func verifyBasisState(t *testing.T, src string, label int) {
+	program, err := Parse(src)
+	if err != nil {
+		t.Errorf("Unexpected error for %q: %v", src, err)
+		return
+	}
+	qreg := program.NewQReg()
+	program.Run(qreg)
+	if prob := qreg.StateProb(label); math.Abs(prob-1) > 1e-9 {
+		t.Errorf("Probability of |%d> after %q = %f, expected 1.",
+			label, src, prob)
+	}
+}
+
+func TestParse_Registers(t *testing.T) {
+	program := mustParse(t, header+
+		"qreg a[2];\ncreg c[3];\nqreg b[3];\ncreg d[1];\n")
+	expectedQRegs := []Register{{"a", 0, 2}, {"b", 2, 3}}
+	expectedCRegs := []Register{{"c", 0, 3}, {"d", 3, 1}}
+	for i, reg := range expectedQRegs {
+		if program.QRegs[i] != reg {
+			t.Errorf("Bad quantum register %+v, expected %+v.",
+				program.QRegs[i], reg)
+		}
+	}
+	for i, reg := range expectedCRegs {
+		if program.CRegs[i] != reg {
+			t.Errorf("Bad classical register %+v, expected %+v.",
+				program.CRegs[i], reg)
+		}
+	}
+	if program.Circuit.Width() != 5 {
+		t.Errorf("Bad width = %d, expected 5.", program.Circuit.Width())
+	}
+	if program.Circuit.NumClbits() != 4 {
+		t.Errorf("Bad number of classical bits = %d, expected 4.",
+			program.Circuit.NumClbits())
+	}
+}
+
+func TestParse_Broadcast(t *testing.T) {
+	// x flips both qubits of a, then cx copies them onto b.
+	verifyBasisState(t, header+"qreg a[2];\nqreg b[2];\nx a;\ncx a, b;\n", 15)
+	// A single control broadcast over a register of targets.
+	verifyBasisState(t, header+"qreg a[1];\nqreg b[3];\nx a[0];\ncx a[0], b;\n", 15)
+	program := mustParse(t, header+"qreg q[3];\ncreg c[3];\nh q;\nmeasure q -> c;\n")
+	ops := program.Circuit.Operations()
+	if len(ops) != 6 {
+		t.Fatalf("Got %d operations, expected 6.", len(ops))
+	}
+	for i, op := range ops[3:] {
+		if op.Kind != quantum.MeasureOp || op.Targets[0] != i || op.Clbit != i {
+			t.Errorf("Bad measurement %+v.", op)
+		}
+	}
+}
+
+func TestParse_Qelib1Gates(t *testing.T) {
+	// Each of these relies on the phases of the gates to interfere.
+	tests := []struct {
+		body  string
+		label int
+	}{
+		{"h q[0]; u1(pi) q[0]; h q[0];", 1},
+		{"h q[0]; p(pi) q[0]; h q[0];", 1},
+		{"h q[0]; rz(pi) q[0]; h q[0];", 1},
+		{"h q[0]; s q[0]; s q[0]; h q[0];", 1},
+		{"h q[0]; t q[0]; t q[0]; sdg q[0]; h q[0];", 0},
+		{"h q[0]; tdg q[0]; tdg q[0]; s q[0]; h q[0];", 0},
+		{"sx q[0]; sx q[0];", 1},
+		{"sx q[0]; sxdg q[0];", 0},
+		{"u2(0, pi) q[0]; h q[0];", 0},
+		{"x q[0]; u2(0, pi) q[0]; h q[0];", 1},
+		{"u3(pi, 0, pi) q[0];", 1},
+		{"rx(pi) q[0]; ry(pi) q[0];", 0},
+		{"y q[0]; z q[0]; id q[0];", 1},
+		{"x q[0]; h q[1]; cp(pi) q[0], q[1]; h q[1];", 3},
+		{"x q[0]; h q[1]; cu1(pi) q[0], q[1]; h q[1];", 3},
+		{"x q[0]; h q[1]; crz(pi) q[0], q[1]; h q[1];", 3},
+		{"x q[0]; h q[1]; cz q[0], q[1]; h q[1];", 3},
+		{"h q[0]; cu(0, 0, 0, pi) q[0], q[1]; h q[0];", 1},
+		{"x q[0]; cu3(pi, 0, pi) q[0], q[1];", 3},
+		{"x q[0]; crx(pi) q[0], q[1];", 3},
+		{"x q[0]; cry(pi) q[0], q[1];", 3},
+		{"x q[0]; cy q[0], q[1];", 3},
+		{"x q[0]; ch q[0], q[1]; h q[1];", 1},
+		{"x q[1]; swap q[0], q[1];", 1},
+		{"h q[0]; h q[1]; rzz(pi) q[0], q[1]; h q[0]; h q[1];", 3},
+		{"rxx(pi) q[0], q[1];", 3},
+		{"x q[0]; x q[1]; ccx q[0], q[1], q[2];", 7},
+		{"x q[0]; x q[1]; cswap q[0], q[1], q[2];", 5},
+		{"x q[0]; x q[1]; x q[2]; c3x q[0], q[1], q[2], q[3];", 15},
+		{"U(pi, 0, pi) q[0]; CX q[0], q[1];", 3},
+	}
+	for _, test := range tests {
+		verifyBasisState(t, header+"qreg q[4];\n"+test.body, test.label)
+	}
+}
+
+func TestParse_GateDefinitions(t *testing.T) {
+	src := header + `
+gate myrot(theta) a { U(theta, 0, 0) a; }
+gate flip2(theta) a, b {
+	myrot(theta * 2) a;
+	barrier a, b;
+	CX a, b;
+}
+qreg q[3];
+flip2(pi / 2) q[2], q[0];
+`
+	verifyBasisState(t, src, 5)
+	ops := mustParse(t, src).Circuit.Operations()
+	kinds := []quantum.OpKind{quantum.GateOp, quantum.BarrierOp, quantum.GateOp}
+	targets := [][]int{{2}, {2, 0}, {2, 0}}
+	if len(ops) != len(kinds) {
+		t.Fatalf("Got %d operations, expected %d.", len(ops), len(kinds))
+	}
+	for i, op := range ops {
+		if op.Kind != kinds[i] || op.Label != "flip2" {
+			t.Errorf("Bad operation %+v.", op)
+		}
+		for j, target := range targets[i] {
+			if op.Targets[j] != target {
+				t.Errorf("Bad targets %v for operation %d, "+
+					"expected %v.", op.Targets, i, targets[i])
+				break
+			}
+		}
+	}
+}
+
+func TestParse_Expressions(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-2^2", -4},
+		{"2^3^2", 512},
+		{"8 / 4 / 2", 1},
+		{"1 - 2 - 3", -4},
+		{"sin(pi / 2) + cos(0)", 2},
+		{"sqrt(4) * exp(0) + ln(1) + tan(0)", 2},
+		{"1.5e1 - .5", 14.5},
+	}
+	for _, test := range tests {
+		tokens, err := tokenize(test.src)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		p := &parser{tokens: tokens}
+		if actual := p.parseExpr(nil).eval(nil); math.Abs(actual-test.expected) > 1e-9 {
+			t.Errorf("%s = %f, expected %f.", test.src, actual,
+				test.expected)
+		}
+	}
+}
+
+func TestParse_ResetAndConditions(t *testing.T) {
+	src := header + `
+qreg q[2];
+creg c[1];
+x q[0];
+measure q[0] -> c[0];
+if(c==1) x q[1];
+if(c==0) x q[0];
+reset q[0];
+`
+	verifyBasisState(t, src, 2)
+}
+
+// Define gates g0 to g(n-1), each of which applies the one before it twice,
+// so that gate gi expands into 2^(i+1) operations.
+func nestedGates(n int) string {
+	defs := "gate g0 a { x a; x a; }\n"
+	for i := 1; i < n; i++ {
+		defs += fmt.Sprintf("gate g%d a { g%d a; g%d a; }\n", i, i-1, i-1)
+	}
+	return defs
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		src       string
+		line, col int
+		msg       string
+	}{
+		{"qreg q[1];", 1, 1, `expected "OPENQASM"`},
+		{"OPENQASM 4.0;", 1, 10, "unsupported OpenQASM version"},
+		{"OPENQASM 2.0;\nqreg q[1];\nh q[0];", 3, 1, `undefined gate "h"`},
+		{"OPENQASM 2.0;\ninclude \"other.inc\";", 2, 9, "only qelib1.inc"},
+		{header + "qreg q[2];\nx q[2];", 4, 5, "out of range"},
+		{header + "qreg q[2];\nx q[0]\nx q[1];", 5, 1, `expected ";"`},
+		{header + "qreg a[2];\nqreg b[3];\ncx a, b;", 5, 7, "does not match"},
+		{header + "qreg q[2];\ncx q[0], q[0];", 4, 10, "used more than once"},
+		{header + "qreg q[2];\nrx q[0];", 4, 1, "takes 1 parameters"},
+		{header + "qreg q[2];\ncx q[0];", 4, 1, "acts on 2 qubits"},
+		{header + "qreg q[2];\nqreg q[1];", 4, 6, "already declared"},
+		{header + "qreg q[0];", 3, 8, "at least one bit"},
+		{header + "gate g(theta) a {\n  rx(phi) a;\n}", 4, 6, `undefined parameter "phi"`},
+		{header + "gate g a {\n  x b;\n}", 4, 5, "not an argument"},
+		{header + "gate x a { }", 3, 6, "already defined"},
+		{header + "opaque magic a;\nqreg q[1];\nmagic q[0];", 5, 1, "cannot be simulated"},
+		{header + "qreg q[1];\nif(q==1) x q[0];", 4, 4, "not a classical register"},
+		{header + "qreg q[1];\ncreg c[1];\nmeasure q[0] -> q[0];", 5, 17, "not a classical register"},
+		{header + "qreg q[1];\nrx(1 +) q[0];", 4, 7, "expected expression"},
+		{header + "qreg q[31];", 3, 6, "limit of 30 qubits"},
+		{header + "qreg a[20];\nqreg b[20];", 4, 6, "limit of 30 qubits"},
+		{header + "creg c[64];", 3, 6, "more than 63 bits"},
+		{header + "qreg q[1];\nrx(pi/0) q[0];", 4, 6, "division by zero"},
+		{header + "gate g(t) a { rx(1/t) a; }\nqreg q[1];\ng(0) q[0];", 3, 19, "division by zero"},
+		{header + "qreg q[1];\nU(sqrt(-1),0,0) q[0];", 4, 3, "not a finite number"},
+		{header + "qreg q[1];\nrx(ln(0)) q[0];", 4, 4, "not a finite number"},
+		{header + "qreg q[1];\nrx(10^400) q[0];", 4, 6, "not a finite number"},
+		{header + "gate g(t) a { rx(sqrt(t)) a; }\nqreg q[1];\ng(-1) q[0];", 3, 18, "not a finite number"},
+		{header + "qreg q[1];\nrx(" + strings.Repeat("(", 2000000) + "1) q[0];", 4, 1004, "nested more than 1000 levels"},
+		{header + "qreg q[1];\nrx(" + strings.Repeat("-", 2000) + "1) q[0];", 4, 1004, "nested more than 1000 levels"},
+		{header + "qreg q[1];\nrx(" + strings.Repeat("1^", 2000) + "1) q[0];", 4, 2004, "nested more than 1000 levels"},
+		{header + nestedGates(40) + "qreg q[1];\ng39 q[0];", 3, 13, "more than 1048576 operations"},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.src)
+		qasmErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Expected an *Error for %q, got %v.", test.src, err)
+			continue
+		}
+		if qasmErr.Line != test.line || qasmErr.Col != test.col ||
+			!strings.Contains(qasmErr.Msg, test.msg) {
+			t.Errorf("Error for %q = %v, expected %q at line %d, "+
+				"column %d.", test.src, err, test.msg, test.line,
+				test.col)
+		}
+	}
+}
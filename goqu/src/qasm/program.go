@@ -0,0 +1,62 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"quantum"
+)
+
+// A quantum or classical register declared by a program. Its bits are bits
+// Offset to Offset+Size-1 of the program's circuit, so that bit i of the
+// register is bit Offset+i of the circuit.
+type Register struct {
+	Name   string
+	Offset int
+	Size   int
+}
+
+// A Program is a parsed OpenQASM program. Its quantum registers are laid out
+// one after another in the qubits of the circuit, in the order in which they
+// were declared, and likewise its classical registers in the classical bits.
+type Program struct {
+	Circuit *quantum.Circuit
+	QRegs   []Register
+	CRegs   []Register
+}
+
+// Constructor for a register of the width of the program's circuit, in the
+// state |0...0>.
+func (program *Program) NewQReg() *quantum.QReg {
+	return quantum.NewQReg(program.Circuit.Width())
+}
+
+// Run the program on a quantum register, and return the value of each
+// classical register, with bit 0 of the register as the least significant
+// bit.
+func (program *Program) Run(qreg *quantum.QReg) map[string]int {
+	clbits := program.Circuit.Run(qreg)
+	values := make(map[string]int, len(program.CRegs))
+	for _, creg := range program.CRegs {
+		value := 0
+		for i := 0; i < creg.Size; i++ {
+			value |= clbits[creg.Offset+i] << uint(i)
+		}
+		values[creg.Name] = value
+	}
+	return values
+}
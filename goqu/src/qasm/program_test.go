@@ -0,0 +1,61 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package qasm
+
+import (
+	"math/rand"
+	"testing"
+
+	"quantum"
+)
+
+func TestProgramRun_Bell(t *testing.T) {
+	program := mustParse(t, header+`
+qreg q[2];
+creg c[2];
+h q[0];
+cx q[0], q[1];
+measure q -> c;
+`)
+	counts := make(map[int]int)
+	for i := 0; i < 200; i++ {
+		qreg := quantum.NewQRegWithRand(rand.NewSource(int64(i)), 2)
+		counts[program.Run(qreg)["c"]]++
+	}
+	if counts[0]+counts[3] != 200 || counts[0] == 0 || counts[3] == 0 {
+		t.Errorf("Bad counts %v for a Bell pair.", counts)
+	}
+}
+
+func TestProgramRun_RegisterValues(t *testing.T) {
+	program := mustParse(t, header+`
+qreg q[4];
+creg a[1];
+creg b[3];
+x q[1];
+x q[3];
+measure q[0] -> a[0];
+measure q[1] -> b[0];
+measure q[2] -> b[1];
+measure q[3] -> b[2];
+`)
+	values := program.Run(program.NewQReg())
+	if values["a"] != 0 || values["b"] != 5 {
+		t.Errorf("Bad register values %v, expected a=0, b=5.", values)
+	}
+}
@@ -0,0 +1,479 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// A Channel is a quantum operation which need not be unitary, such as noise,
+// given by its Kraus operators K_k. It sends a density matrix rho to
+// sum_k K_k rho K_k^dag. On a QReg, which can only hold a pure state, it is
+// applied stochastically: one of the K_k is chosen with probability
+// |K_k psi|^2, as in a single trajectory of the evolution.
+type Channel struct {
+	// The width of this channel (how many qubits it acts upon).
+	width int
+
+	// The conventional name of the channel (e.g., "depolarizing"), or the
+	// empty string if it has none.
+	name string
+
+	// The arguments of the channel's constructor, e.g. the probability of
+	// an error, or nil if it has none.
+	params []float64
+
+	// The Kraus operators, as gates which need not be unitary.
+	operators []*Gate
+
+	// The matrices K_k^dag K_k of the Kraus operators in row-major order,
+	// which give the probability of each operator being chosen.
+	effects [][]complex128
+
+	// If every K_k^dag K_k is a multiple p_k I of the identity, as for a
+	// mixture of unitaries, the probabilities p_k, which then do not
+	// depend on the state. Otherwise nil.
+	fixedProbs []float64
+
+	// Whether each Kraus operator is a multiple of the identity, so that
+	// choosing it leaves a pure state unchanged.
+	isScalar []bool
+}
+
+// Accessor for the width of a Channel.
+func (channel *Channel) Width() int {
+	return channel.width
+}
+
+// Accessor for the name of a Channel.
+func (channel *Channel) Name() string {
+	return channel.name
+}
+
+// Accessor for the parameters of a Channel, e.g. the probability p of
+// BitFlip(p). This is nil for a channel without parameters.
+func (channel *Channel) Params() []float64 {
+	return append([]float64(nil), channel.params...)
+}
+
+// Constructor for a Channel from its Kraus operators, each given as a 2^n by
+// 2^n matrix in row-major order like the array of NewArrayGate.
+func NewKrausChannel(operators ...[]complex128) *Channel {
+	channel, err := TryNewKrausChannel(operators...)
+	if err != nil {
+		panic(err)
+	}
+	return channel
+}
+
+// Like NewKrausChannel, but returns ErrWidthMismatch if the operators are not
+// all 2^n by 2^n matrices of the same size, or ErrNotComplete, instead of
+// panicking.
+func TryNewKrausChannel(operators ...[]complex128) (*Channel, error) {
+	if len(operators) == 0 {
+		return nil, fmt.Errorf("%w: no Kraus operators", ErrNotComplete)
+	}
+	size := len(operators[0])
+	dim := int(math.Sqrt(float64(size)))
+	width := int(math.Log2(float64(dim)))
+	if dim == 0 || dim*dim != size || 1<<uint(width) != dim {
+		return nil, fmt.Errorf("%w: %d elements do not form a "+
+			"2^n by 2^n matrix", ErrWidthMismatch, size)
+	}
+	gates := make([]*Gate, len(operators))
+	for i, arr := range operators {
+		if len(arr) != size {
+			return nil, fmt.Errorf("%w: Kraus operators of %d and "+
+				"%d elements", ErrWidthMismatch, size, len(arr))
+		}
+		arr := append([]complex128(nil), arr...)
+		gates[i] = NewFuncGateNoCheck(func(row, col int) complex128 {
+			return arr[row*dim+col]
+		}, width)
+	}
+	channel := newChannel("", nil, gates...)
+	if !channel.isComplete() {
+		return nil, ErrNotComplete
+	}
+	return channel, nil
+}
+
+// Build a channel from Kraus operators which are known to be complete.
+func newChannel(name string, params []float64, operators ...*Gate) *Channel {
+	dim := operators[0].dim()
+	effects := make([][]complex128, len(operators))
+	for k, op := range operators {
+		effects[k] = make([]complex128, dim*dim)
+		for row := 0; row < dim; row++ {
+			for col := 0; col < dim; col++ {
+				sum := complex(0, 0)
+				for i := 0; i < dim; i++ {
+					sum += op.getDagger(row, i) * op.get(i, col)
+				}
+				effects[k][row*dim+col] = sum
+			}
+		}
+	}
+	fixedProbs := make([]float64, len(operators))
+	isScalar := make([]bool, len(operators))
+	mixesUnitaries := true
+	for k, op := range operators {
+		prob, ok := scalarMatrix(effects[k], dim)
+		fixedProbs[k] = real(prob)
+		mixesUnitaries = mixesUnitaries && ok
+		op00 := op.get(0, 0)
+		isScalar[k] = true
+		for row := 0; row < dim && isScalar[k]; row++ {
+			for col := 0; col < dim; col++ {
+				element := op.get(row, col)
+				if row == col && element != op00 ||
+					row != col && element != 0 {
+					isScalar[k] = false
+					break
+				}
+			}
+		}
+	}
+	if !mixesUnitaries {
+		fixedProbs = nil
+	}
+	return &Channel{
+		width:      operators[0].width,
+		name:       name,
+		params:     params,
+		operators:  operators,
+		effects:    effects,
+		fixedProbs: fixedProbs,
+		isScalar:   isScalar}
+}
+
+// If a dim by dim matrix in row-major order is a multiple c I of the
+// identity, return c and true.
+func scalarMatrix(matrix []complex128, dim int) (complex128, bool) {
+	c := matrix[0]
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			expected := complex(0, 0)
+			if row == col {
+				expected = c
+			}
+			if cmplx.Abs(matrix[row*dim+col]-expected) > threshold {
+				return 0, false
+			}
+		}
+	}
+	return c, true
+}
+
+// This tells us whether or not the Kraus operators of a channel satisfy the
+// completeness relation (they should always do).
+func (channel *Channel) isComplete() bool {
+	dim := 1 << uint(channel.width)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			sum := complex(0, 0)
+			for _, effect := range channel.effects {
+				sum += effect[row*dim+col]
+			}
+			expected := complex(0, 0)
+			if row == col {
+				expected = 1
+			}
+			if cmplx.Abs(sum-expected) > threshold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Apply the channel stochastically to a quantum register: one Kraus operator
+// K_k is chosen with probability |K_k psi|^2 and the state becomes
+// K_k psi / |K_k psi|. The index k of the operator chosen is returned. The
+// targets are as for Gate.Apply. Channels are not recorded by
+// QReg.StartRecording, since a Circuit has no operation for them.
+func (channel *Channel) Apply(qreg *QReg, targets []int) int {
+	k, err := channel.TryApply(qreg, targets)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+// Like Apply, but returns ErrWidthMismatch, ErrInvalidTarget or
+// ErrDuplicateTarget instead of panicking. The register is left unchanged if
+// an error is returned.
+func (channel *Channel) TryApply(qreg *QReg, targets []int) (int, error) {
+	if err := channel.operators[0].checkTargets(targets, qreg.width); err != nil {
+		return 0, err
+	}
+	// The probability of choosing K_k is Tr(K_k^dag K_k rho), where rho
+	// is the reduced density matrix of the targets, so only the chosen
+	// operator needs to be applied to the whole register.
+	bits := qreg.targetBits(targets)
+	probs := channel.fixedProbs
+	if probs == nil {
+		rho := reducedMatrix(qreg.amplitudes, bits, qreg.workers())
+		dim := 1 << uint(channel.width)
+		probs = make([]float64, len(channel.effects))
+		for k, effect := range channel.effects {
+			for i := 0; i < dim; i++ {
+				for j := 0; j < dim; j++ {
+					probs[k] += real(effect[i*dim+j] * rho[j*dim+i])
+				}
+			}
+		}
+	}
+	r := randFloat64(qreg.rng)
+	sum := float64(0.0)
+	chosen, chosenProb := 0, float64(0.0)
+	for k, prob := range probs {
+		// Fall back on the last operator with a non-zero probability
+		// in case rounding leaves r beyond the total.
+		if prob > threshold {
+			chosen, chosenProb = k, prob
+		}
+		sum += prob
+		if r < sum && prob > threshold {
+			break
+		}
+	}
+	if channel.isScalar[chosen] {
+		// The state is unchanged up to a global phase.
+		return chosen, nil
+	}
+	channel.operators[chosen].applyMasked(qreg.amplitudes, bits, 0, 0,
+		qreg.workers())
+	scale := complex(1/math.Sqrt(chosenProb), 0)
+	parallelFor(len(qreg.amplitudes), qreg.workers(), func(start, end int) {
+		for i := start; i < end; i++ {
+			qreg.amplitudes[i] *= scale
+		}
+	})
+	return chosen, nil
+}
+
+// Compute the reduced density matrix of the qubits at the given bit
+// positions of a state vector, in row-major order, where bit i of the row and
+// column indices corresponds to bits[i]. At most the given number of workers
+// are used.
+func reducedMatrix(amplitudes []complex128, bits []int, workers int) []complex128 {
+	offsets, sortedBits := groupOffsets(bits)
+	dim := len(offsets)
+	rho := make([]complex128, dim*dim)
+	bounds := chunkBounds(len(amplitudes)/dim, minChunkSize, workers)
+	partials := make([][]complex128, len(bounds)-1)
+	forEachChunk(bounds, func(chunk, start, end int) {
+		partial := make([]complex128, dim*dim)
+		for group := start; group < end; group++ {
+			base := insertZeroBits(group, sortedBits)
+			for i, rowOffset := range offsets {
+				a := amplitudes[base+rowOffset]
+				if a == 0 {
+					continue
+				}
+				for j, colOffset := range offsets {
+					partial[i*dim+j] += a * cmplx.Conj(amplitudes[base+colOffset])
+				}
+			}
+		}
+		partials[chunk] = partial
+	})
+	for _, partial := range partials {
+		for i, element := range partial {
+			rho[i] += element
+		}
+	}
+	return rho
+}
+
+// Apply a channel to the state, i.e. replace the matrix rho by
+// sum_k K_k rho K_k^dag, where the K_k are the channel's Kraus operators
+// applied to the given targets as by Gate.Apply.
+func (dm *DensityMatrix) ApplyChannel(channel *Channel, targets []int) {
+	if err := dm.TryApplyChannel(channel, targets); err != nil {
+		panic(err)
+	}
+}
+
+// Like ApplyChannel, but returns ErrWidthMismatch, ErrInvalidTarget or
+// ErrDuplicateTarget instead of panicking. The matrix is left unchanged if an
+// error is returned.
+func (dm *DensityMatrix) TryApplyChannel(channel *Channel, targets []int) error {
+	if err := channel.operators[0].checkTargets(targets, dm.width); err != nil {
+		return err
+	}
+	sum := make([]complex128, len(dm.elements))
+	for _, op := range channel.operators {
+		term := &DensityMatrix{
+			width:    dm.width,
+			elements: make([]complex128, len(dm.elements)),
+			order:    dm.order}
+		copy(term.elements, dm.elements)
+		term.Apply(op, targets)
+		parallelFor(len(sum), MaxWorkers(), func(start, end int) {
+			for i := start; i < end; i++ {
+				sum[i] += term.elements[i]
+			}
+		})
+	}
+	dm.elements = sum
+	return nil
+}
+
+// Verify that a parameter of a channel is a probability.
+func checkProbability(name string, p float64) {
+	if p < 0 || p > 1 || math.IsNaN(p) {
+		panic(fmt.Errorf("%w: %s %g should be between 0 and 1",
+			ErrInvalidProbability, name, p))
+	}
+}
+
+// Scale the matrix of a single-qubit gate by a real factor, giving a Kraus
+// operator.
+func scaledOneQubit(factor float64, gate *Gate) *Gate {
+	var arr [4]complex128
+	for i := range arr {
+		arr[i] = complex(factor, 0) * gate.get(i>>1, i&1)
+	}
+	return newOneQubitGate("", arr)
+}
+
+// The bit flip channel, which applies Pauli X with probability p.
+func BitFlip(p float64) *Channel {
+	checkProbability("probability", p)
+	return newChannel("bit_flip", []float64{p},
+		scaledOneQubit(math.Sqrt(1-p), Identity()),
+		scaledOneQubit(math.Sqrt(p), PauliX()))
+}
+
+// The phase flip channel, which applies Pauli Z with probability p.
+func PhaseFlip(p float64) *Channel {
+	checkProbability("probability", p)
+	return newChannel("phase_flip", []float64{p},
+		scaledOneQubit(math.Sqrt(1-p), Identity()),
+		scaledOneQubit(math.Sqrt(p), PauliZ()))
+}
+
+// The Pauli channel, which applies Pauli X, Y or Z with probabilities px, py
+// and pz respectively, and otherwise leaves the qubit unchanged.
+func PauliChannel(px, py, pz float64) *Channel {
+	checkProbability("probability", px)
+	checkProbability("probability", py)
+	checkProbability("probability", pz)
+	checkProbability("total probability", px+py+pz)
+	return newChannel("pauli", []float64{px, py, pz},
+		scaledOneQubit(math.Sqrt(1-px-py-pz), Identity()),
+		scaledOneQubit(math.Sqrt(px), PauliX()),
+		scaledOneQubit(math.Sqrt(py), PauliY()),
+		scaledOneQubit(math.Sqrt(pz), PauliZ()))
+}
+
+// The depolarizing channel on width qubits, which replaces the state by the
+// maximally mixed state with probability p, i.e. sends rho to
+// (1 - p) rho + p I / 2^width. Its Kraus operators are the tensor products of
+// Pauli matrices, so it is equivalent to applying one of the 4^width of them
+// chosen uniformly at random with probability p.
+func NewDepolarizingChannel(width int, p float64) *Channel {
+	checkProbability("probability", p)
+	paulis := []*Gate{Identity(), PauliX(), PauliY(), PauliZ()}
+	numStrings := 1 << uint(2*width)
+	operators := make([]*Gate, numStrings)
+	for s := range operators {
+		// Digit i of s in base 4 is the Pauli matrix on target i.
+		factor := complex(math.Sqrt(p/float64(numStrings)), 0)
+		if s == 0 {
+			factor = complex(math.Sqrt(1-p+p/float64(numStrings)), 0)
+		}
+		s := s
+		operators[s] = NewFuncGateNoCheck(func(row, col int) complex128 {
+			element := factor
+			for i := 0; i < width; i++ {
+				pauli := paulis[s>>uint(2*i)&3]
+				element *= pauli.get(row>>uint(i)&1, col>>uint(i)&1)
+			}
+			return element
+		}, width)
+	}
+	return newChannel("depolarizing", []float64{p}, operators...)
+}
+
+// The amplitude damping channel, which models the decay of |1> to |0> with
+// probability gamma, e.g. by spontaneous emission.
+func AmplitudeDamping(gamma float64) *Channel {
+	checkProbability("damping probability", gamma)
+	return newChannel("amplitude_damping", []float64{gamma},
+		newOneQubitGate("", [4]complex128{
+			1, 0,
+			0, complex(math.Sqrt(1-gamma), 0)}),
+		newOneQubitGate("", [4]complex128{
+			0, complex(math.Sqrt(gamma), 0),
+			0, 0}))
+}
+
+// The phase damping channel, which models the loss of coherence between |0>
+// and |1> without loss of energy: the off-diagonal elements of the density
+// matrix are scaled by sqrt(1 - lambda).
+func PhaseDamping(lambda float64) *Channel {
+	checkProbability("damping probability", lambda)
+	return newChannel("phase_damping", []float64{lambda},
+		newOneQubitGate("", [4]complex128{
+			1, 0,
+			0, complex(math.Sqrt(1-lambda), 0)}),
+		newOneQubitGate("", [4]complex128{
+			0, 0,
+			0, complex(math.Sqrt(lambda), 0)}))
+}
+
+// The thermal relaxation channel of a qubit with relaxation time t1 and
+// dephasing time t2 over the given duration, at zero temperature. The
+// population of |1> decays as exp(-time/t1) and the coherences as
+// exp(-time/t2), which requires t2 <= 2 t1. It is amplitude damping followed
+// by just enough phase damping to reach the dephasing time.
+func ThermalRelaxation(t1, t2, time float64) *Channel {
+	if t1 <= 0 || t2 <= 0 || t2 > 2*t1 {
+		panic(fmt.Errorf("%w: relaxation times t1 = %g and t2 = %g "+
+			"should be positive with t2 <= 2 t1",
+			ErrInvalidParameter, t1, t2))
+	}
+	if time < 0 {
+		panic(fmt.Errorf("%w: duration %g should not be negative",
+			ErrInvalidParameter, time))
+	}
+	gamma := 1 - math.Exp(-time/t1)
+	// The coherences are scaled by sqrt(1 - gamma) sqrt(1 - lambda).
+	lambda := 1 - math.Exp(time/t1-2*time/t2)
+	if lambda < 0 {
+		// Only possible by rounding when t2 = 2 t1.
+		lambda = 0
+	}
+	// The products of the phase damping and amplitude damping operators,
+	// omitting the one which is zero.
+	return newChannel("thermal_relaxation", []float64{t1, t2, time},
+		newOneQubitGate("", [4]complex128{
+			1, 0,
+			0, complex(math.Sqrt((1-gamma)*(1-lambda)), 0)}),
+		newOneQubitGate("", [4]complex128{
+			0, 0,
+			0, complex(math.Sqrt((1-gamma)*lambda), 0)}),
+		newOneQubitGate("", [4]complex128{
+			0, complex(math.Sqrt(gamma), 0),
+			0, 0}))
+}
@@ -0,0 +1,204 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+func TestChannels_Complete(t *testing.T) {
+	channels := []*Channel{
+		BitFlip(0.1),
+		PhaseFlip(0.9),
+		PauliChannel(0.1, 0.2, 0.3),
+		NewDepolarizingChannel(1, 0.4),
+		NewDepolarizingChannel(2, 0.05),
+		AmplitudeDamping(0.6),
+		PhaseDamping(0.3),
+		ThermalRelaxation(50, 70, 10),
+		ThermalRelaxation(50, 100, 10),
+	}
+	for _, channel := range channels {
+		if !channel.isComplete() {
+			t.Errorf("Channel %q%v is not complete.", channel.Name(),
+				channel.Params())
+		}
+	}
+}
+
+// Mixtures of unitaries are chosen without reading the state.
+func TestChannels_FixedProbs(t *testing.T) {
+	if probs := PauliChannel(0.1, 0.2, 0.3).fixedProbs; len(probs) != 4 ||
+		!verifyProb(0.4, probs[0]) || !verifyProb(0.3, probs[3]) {
+		t.Errorf("Bad probabilities %v, expected [0.4 0.1 0.2 0.3].",
+			probs)
+	}
+	if probs := AmplitudeDamping(0.1).fixedProbs; probs != nil {
+		t.Errorf("Amplitude damping has fixed probabilities %v.", probs)
+	}
+	if isScalar := BitFlip(0.1).isScalar; !isScalar[0] || isScalar[1] {
+		t.Errorf("Bad scalar operators %v, expected [true false].",
+			isScalar)
+	}
+}
+
+func TestChannels_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected error
+		f        func()
+	}{
+		{"negative probability", ErrInvalidProbability,
+			func() { BitFlip(-0.1) }},
+		{"NaN probability", ErrInvalidProbability,
+			func() { NewDepolarizingChannel(1, math.NaN()) }},
+		{"t2 > 2 t1", ErrInvalidParameter,
+			func() { ThermalRelaxation(1, 3, 0.1) }},
+		{"negative duration", ErrInvalidParameter,
+			func() { ThermalRelaxation(2, 1, -0.1) }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || !errors.Is(err, test.expected) {
+					t.Errorf("%s: expected panic with %v, got %v.",
+						test.name, test.expected, err)
+				}
+			}()
+			test.f()
+		}()
+	}
+}
+
+func TestNewKrausChannel(t *testing.T) {
+	// The reset channel sends every state to |0>.
+	channel, err := TryNewKrausChannel(
+		[]complex128{1, 0, 0, 0},
+		[]complex128{0, 1, 0, 0})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	qreg := NewQReg(2, 3)
+	channel.Apply(qreg, []int{1})
+	if !isBasisState(qreg, 1) {
+		t.Errorf("Reset channel did not reset the qubit.")
+	}
+
+	if _, err := TryNewKrausChannel(); !errors.Is(err, ErrNotComplete) {
+		t.Errorf("Expected ErrNotComplete, got %v.", err)
+	}
+	if _, err := TryNewKrausChannel([]complex128{1, 0, 0, 0}); !errors.Is(err, ErrNotComplete) {
+		t.Errorf("Expected ErrNotComplete, got %v.", err)
+	}
+	if _, err := TryNewKrausChannel([]complex128{1, 0, 0}); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+	if _, err := TryNewKrausChannel([]complex128{1, 0, 0, 1},
+		make([]complex128, 16)); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+}
+
+func TestChannels_DensityMatrix(t *testing.T) {
+	// A qubit in |0> and another in |+>.
+	newState := func() *DensityMatrix {
+		qreg := NewQReg(2)
+		Hadamard(qreg, 1)
+		return NewDensityMatrix(qreg)
+	}
+
+	dm := newState()
+	dm.ApplyChannel(BitFlip(0.3), []int{0})
+	if prob := dm.BProb(0); !verifyProb(0.3, prob[1]) {
+		t.Errorf("Bit flip gave probabilities %v, expected 0.7 and 0.3.",
+			prob)
+	}
+
+	dm = newState()
+	dm.ApplyChannel(PhaseDamping(0.36), []int{1})
+	if coherence := dm.Get(0, 2); !verifyAmplitude(0.4, coherence) {
+		t.Errorf("Phase damping left coherence %v, expected 0.4.",
+			coherence)
+	}
+
+	dm = newState()
+	dm.ApplyChannel(ThermalRelaxation(50, 70, 10), []int{1})
+	if prob := dm.BProb(1)[1]; !verifyProb(0.5*math.Exp(-10.0/50), prob) {
+		t.Errorf("Thermal relaxation left population %g.", prob)
+	}
+	if coherence := dm.Get(0, 2); !verifyProb(0.5*math.Exp(-10.0/70),
+		cmplx.Abs(coherence)) {
+		t.Errorf("Thermal relaxation left coherence %v.", coherence)
+	}
+
+	// Completely depolarizing both qubits gives the maximally mixed
+	// state.
+	dm = newState()
+	dm.ApplyChannel(NewDepolarizingChannel(2, 1), []int{0, 1})
+	if !verifyProb(0.25, dm.Purity()) || !verifyProb(1, dm.Trace()) {
+		t.Errorf("Depolarized state has purity %g and trace %g, "+
+			"expected 0.25 and 1.", dm.Purity(), dm.Trace())
+	}
+
+	if err := dm.TryApplyChannel(BitFlip(0.1), []int{2}); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget, got %v.", err)
+	}
+}
+
+// Applying a channel to a QReg many times should choose each Kraus operator
+// about as often as its probability.
+func TestChannel_Apply(t *testing.T) {
+	const trials = 2000
+	source := rand.NewSource(1)
+	decays := 0
+	for i := 0; i < trials; i++ {
+		qreg := NewQRegWithRand(source, 2, 2)
+		k := AmplitudeDamping(0.3).Apply(qreg, []int{1})
+		if k == 1 {
+			decays++
+			if !isBasisState(qreg, 0) {
+				t.Fatalf("Decayed state is not |00>.")
+			}
+		} else if !verifyProb(1, qreg.StateProb(2)) {
+			t.Fatalf("Undecayed state is not |10>.")
+		}
+	}
+	if fraction := float64(decays) / trials; math.Abs(fraction-0.3) > 0.05 {
+		t.Errorf("Decayed in %g of trials, expected 0.3.", fraction)
+	}
+
+	// The state should remain normalised when the operator chosen is not
+	// a multiple of a unitary.
+	qreg := NewQRegWithRand(source, 1)
+	Hadamard(qreg, 0)
+	AmplitudeDamping(0.5).Apply(qreg, []int{0})
+	norm := qreg.StateProb(0) + qreg.StateProb(1)
+	if prob := qreg.StateProb(0); !verifyProb(1, norm) ||
+		verifyProb(0.5, prob) {
+		t.Errorf("Bad probabilities %g and %g after damping.", prob,
+			qreg.StateProb(1))
+	}
+
+	if _, err := BitFlip(0.1).TryApply(qreg, []int{0, 1}); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+)
+
+// The kinds of operation which can appear in a circuit.
+type OpKind int
+
+const (
+	// Apply a gate to a list of targets.
+	GateOp OpKind = iota
+	// Measure a single qubit into a classical bit.
+	MeasureOp
+	// Reset a single qubit to |0>.
+	ResetOp
+	// A barrier across the targets, which has no effect when run.
+	BarrierOp
+)
+
+// A Condition makes an operation run only when the classical bits Clbits,
+// read as a binary number with Clbits[0] as the least significant bit, are
+// equal to Value.
+type Condition struct {
+	Clbits []int
+	Value  int
+}
+
+// An Operation is a single step of a Circuit.
+type Operation struct {
+	Kind OpKind
+
+	// The gate to apply, for a GateOp.
+	Gate *Gate
+
+	// The qubits acted upon, in the same order as the targets passed to
+	// Gate.Apply. A MeasureOp or ResetOp has exactly one target.
+	Targets []int
+
+	// The classical bit which receives the result of a MeasureOp.
+	Clbit int
+
+	// An optional label describing the operation, e.g. "oracle".
+	Label string
+
+	// An optional condition on the classical bits for the operation to
+	// run.
+	Condition *Condition
+}
+
+// A Circuit is an ordered sequence of operations on a quantum register, which
+// can be built once and then run on any number of registers.
+type Circuit struct {
+	// The number of qubits the circuit acts upon.
+	width int
+
+	// The number of classical bits written by measurements.
+	numClbits int
+
+	ops []Operation
+}
+
+// Constructor for an empty Circuit acting upon width qubits.
+func NewCircuit(width int) *Circuit {
+	return &Circuit{width: width}
+}
+
+// Accessor for the width of a Circuit.
+func (circuit *Circuit) Width() int {
+	return circuit.width
+}
+
+// The number of classical bits written by the measurements in the circuit.
+func (circuit *Circuit) NumClbits() int {
+	return circuit.numClbits
+}
+
+// Get a copy of the list of operations in the circuit.
+func (circuit *Circuit) Operations() []Operation {
+	ops := make([]Operation, len(circuit.ops))
+	copy(ops, circuit.ops)
+	return ops
+}
+
+// Ensure that the circuit has at least n classical bits, e.g. to hold a
+// classical register which is not written by any measurement.
+func (circuit *Circuit) ReserveClbits(n int) *Circuit {
+	if n > circuit.numClbits {
+		circuit.numClbits = n
+	}
+	return circuit
+}
+
+// Verify that all the targets are valid qubits of the circuit, and unless a
+// qubit may be repeated, as in a barrier, that they are distinct.
+func (circuit *Circuit) checkTargets(targets []int, repeatable bool) {
+	if !repeatable {
+		if err := checkQubits(targets, circuit.width); err != nil {
+			panic(err)
+		}
+		return
+	}
+	for _, target := range targets {
+		if err := checkQubits([]int{target}, circuit.width); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Append the application of a gate to the given targets. The circuit is
+// returned so that calls can be chained.
+func (circuit *Circuit) Append(gate *Gate, targets ...int) *Circuit {
+	return circuit.AppendLabelled("", gate, targets...)
+}
+
+// Append the application of a gate to the given targets, with a label
+// describing it.
+func (circuit *Circuit) AppendLabelled(label string, gate *Gate, targets ...int) *Circuit {
+	return circuit.AppendOperation(Operation{
+		Kind:    GateOp,
+		Gate:    gate,
+		Targets: targets,
+		Label:   label})
+}
+
+// Append the application of a gate to a range of consecutive targets, like
+// Gate.ApplyRange.
+func (circuit *Circuit) AppendRange(gate *Gate, targetRangeStart int) *Circuit {
+	targets := make([]int, gate.Width())
+	for i := range targets {
+		targets[i] = targetRangeStart + i
+	}
+	return circuit.Append(gate, targets...)
+}
+
+// Append a measurement of a qubit, whose result is stored in the given
+// classical bit when the circuit is run.
+func (circuit *Circuit) Measure(qubit, clbit int) *Circuit {
+	return circuit.AppendOperation(Operation{
+		Kind:    MeasureOp,
+		Targets: []int{qubit},
+		Clbit:   clbit})
+}
+
+// Append a reset of a qubit to |0>.
+func (circuit *Circuit) Reset(qubit int) *Circuit {
+	return circuit.AppendOperation(Operation{
+		Kind:    ResetOp,
+		Targets: []int{qubit}})
+}
+
+// Append a barrier across the given qubits.
+func (circuit *Circuit) Barrier(qubits ...int) *Circuit {
+	return circuit.AppendOperation(Operation{
+		Kind:    BarrierOp,
+		Targets: qubits})
+}
+
+// Verify that a classical bit index is valid, and make room for it.
+func (circuit *Circuit) useClbit(clbit int) {
+	if clbit < 0 {
+		panic(fmt.Errorf("%w: %d is not a valid classical bit",
+			ErrInvalidTarget, clbit))
+	}
+	circuit.ReserveClbits(clbit + 1)
+}
+
+// Append an arbitrary operation, such as one with a condition. The
+// operation's targets and condition are copied.
+func (circuit *Circuit) AppendOperation(op Operation) *Circuit {
+	switch op.Kind {
+	case GateOp:
+		if err := op.Gate.checkTargets(op.Targets, circuit.width); err != nil {
+			panic(err)
+		}
+	case MeasureOp, ResetOp:
+		if len(op.Targets) != 1 {
+			panic(fmt.Errorf("%w: operation of kind %d given %d "+
+				"targets", ErrWidthMismatch, op.Kind,
+				len(op.Targets)))
+		}
+		circuit.checkTargets(op.Targets, false)
+		if op.Kind == MeasureOp {
+			circuit.useClbit(op.Clbit)
+		}
+	case BarrierOp:
+		circuit.checkTargets(op.Targets, true)
+	default:
+		panic(fmt.Errorf("%w: %d is not a valid kind of operation",
+			ErrInvalidOperation, op.Kind))
+	}
+	op.Targets = append([]int(nil), op.Targets...)
+	if op.Condition != nil {
+		for _, clbit := range op.Condition.Clbits {
+			circuit.useClbit(clbit)
+		}
+		op.Condition = &Condition{
+			Clbits: append([]int(nil), op.Condition.Clbits...),
+			Value:  op.Condition.Value}
+	}
+	circuit.ops = append(circuit.ops, op)
+	return circuit
+}
+
+// Append all the operations of another circuit. If qubits are given, qubit i
+// of other is mapped to qubits[i]; otherwise the qubits are mapped to
+// themselves. Classical bits are shared between the two circuits.
+func (circuit *Circuit) Compose(other *Circuit, qubits ...int) *Circuit {
+	if len(qubits) == 0 {
+		if other.width > circuit.width {
+			panic(fmt.Errorf("%w: circuit of width %d cannot "+
+				"contain a circuit of width %d", ErrWidthMismatch,
+				circuit.width, other.width))
+		}
+		qubits = make([]int, other.width)
+		for i := range qubits {
+			qubits[i] = i
+		}
+	} else if len(qubits) != other.width {
+		panic(fmt.Errorf("%w: circuit of width %d given %d qubits",
+			ErrWidthMismatch, other.width, len(qubits)))
+	}
+	circuit.checkTargets(qubits, false)
+
+	for _, op := range other.ops {
+		targets := make([]int, len(op.Targets))
+		for i, target := range op.Targets {
+			targets[i] = qubits[target]
+		}
+		op.Targets = targets
+		circuit.ops = append(circuit.ops, op)
+	}
+	if other.numClbits > circuit.numClbits {
+		circuit.numClbits = other.numClbits
+	}
+	return circuit
+}
+
+// Run the circuit on a quantum register, which must be at least as wide as
+// the circuit. The values of the classical bits are returned.
+func (circuit *Circuit) Run(qreg *QReg) []int {
+	if qreg.width < circuit.width {
+		panic(fmt.Errorf("%w: circuit of width %d cannot run on a "+
+			"register of width %d", ErrWidthMismatch, circuit.width,
+			qreg.width))
+	}
+	clbits := make([]int, circuit.numClbits)
+	for _, op := range circuit.ops {
+		if !op.Condition.holds(clbits) {
+			continue
+		}
+		switch op.Kind {
+		case GateOp:
+			op.Gate.Apply(qreg, op.Targets)
+		case MeasureOp:
+			clbits[op.Clbit] = qreg.BMeasure(op.Targets[0])
+		case ResetOp:
+			qreg.reset(op.Targets[0])
+		}
+	}
+	return clbits
+}
+
+// Test whether a condition holds for the given values of the classical bits.
+// A nil condition always holds.
+func (condition *Condition) holds(clbits []int) bool {
+	if condition == nil {
+		return true
+	}
+	value := 0
+	for i, clbit := range condition.Clbits {
+		value |= clbits[clbit] << uint(i)
+	}
+	return value == condition.Value
+}
+
+// Build the circuit of Hadamard and controlled phase gates which performs the
+// quantum Fourier transform on width qubits, like NewQFTGate(width). Each
+// qubit in turn, from the most significant, is put into a superposition whose
+// relative phase depends on the less significant qubits, and finally the
+// order of the qubits is reversed.
+func NewQFTCircuit(width int) *Circuit {
+	circuit := NewCircuit(width)
+	for i := width - 1; i >= 0; i-- {
+		circuit.Append(NewHadamardGate(1), i)
+		for k := i - 1; k >= 0; k-- {
+			circuit.Append(Controlled(Phase(fourierAngle(i-k)), 1),
+				k, i)
+		}
+	}
+	for i := 0; i < width/2; i++ {
+		circuit.Append(Swap(), i, width-1-i)
+	}
+	return circuit
+}
+
+// Build the circuit for the inverse quantum Fourier transform, which is that
+// of NewQFTCircuit in reverse with the phases negated.
+func NewInverseQFTCircuit(width int) *Circuit {
+	circuit := NewCircuit(width)
+	for i := 0; i < width/2; i++ {
+		circuit.Append(Swap(), i, width-1-i)
+	}
+	for i := 0; i < width; i++ {
+		for k := 0; k < i; k++ {
+			circuit.Append(Controlled(Phase(-fourierAngle(i-k)), 1),
+				k, i)
+		}
+		circuit.Append(NewHadamardGate(1), i)
+	}
+	return circuit
+}
+
+// The phase pi / 2^distance applied between qubits distance apart in the
+// quantum Fourier transform.
+func fourierAngle(distance int) float64 {
+	return math.Pi / float64(int(1)<<uint(distance))
+}
@@ -0,0 +1,193 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCircuit_Run(t *testing.T) {
+	// Prepare a Bell state and measure both qubits.
+	circuit := NewCircuit(2).
+		AppendLabelled("prepare", NewHadamardGate(1), 0).
+		Append(CX(), 0, 1).
+		Measure(0, 0).
+		Measure(1, 1)
+	if circuit.NumClbits() != 2 {
+		t.Fatalf("Bad number of classical bits = %d, expected 2.",
+			circuit.NumClbits())
+	}
+	// The same circuit can be run many times.
+	for i := 0; i < 20; i++ {
+		qreg := NewQReg(2)
+		clbits := circuit.Run(qreg)
+		if clbits[0] != clbits[1] {
+			t.Fatalf("Bell state measured as %v.", clbits)
+		}
+		if !verifyProb(1, qreg.StateProb(3*clbits[0])) {
+			t.Errorf("Expected register to collapse to |%d%d>.",
+				clbits[1], clbits[0])
+		}
+	}
+
+	ops := circuit.Operations()
+	if len(ops) != 4 {
+		t.Fatalf("Bad number of operations = %d, expected 4.", len(ops))
+	}
+	if ops[0].Kind != GateOp || ops[0].Label != "prepare" ||
+		ops[0].Gate.Name() != "h" {
+		t.Errorf("Bad first operation %+v.", ops[0])
+	}
+	if ops[3].Kind != MeasureOp || ops[3].Targets[0] != 1 ||
+		ops[3].Clbit != 1 {
+		t.Errorf("Bad last operation %+v.", ops[3])
+	}
+}
+
+func TestCircuit_Compose(t *testing.T) {
+	// Flip qubit 1 of a two-qubit sub-circuit.
+	flip := NewCircuit(2).Append(PauliX(), 1)
+
+	circuit := NewCircuit(3)
+	circuit.Compose(flip)
+	circuit.Compose(flip, 2, 0)
+	qreg := NewQReg(3)
+	circuit.Run(qreg)
+	if !isBasisState(qreg, 3) {
+		t.Error("Expected |011>.")
+	}
+
+	// Repeating a sub-circuit twice undoes it.
+	circuit.Compose(circuit)
+	qreg = NewQReg(3)
+	circuit.Run(qreg)
+	if !isBasisState(qreg, 0) {
+		t.Error("Expected |000>.")
+	}
+}
+
+func TestCircuit_AppendRange(t *testing.T) {
+	circuit := NewCircuit(3).AppendRange(Controlled(PauliX(), 1), 1)
+	qreg := NewQReg(3, 2)
+	circuit.Run(qreg)
+	if !isBasisState(qreg, 6) {
+		t.Error("Expected |110>.")
+	}
+}
+
+func TestCircuit_InvalidTargets(t *testing.T) {
+	expectPanic := func(name string, expected error, f func()) {
+		defer func() {
+			if err, ok := recover().(error); !ok || !errors.Is(err, expected) {
+				t.Errorf("Expected %s to panic with %v, got %v.",
+					name, expected, err)
+			}
+		}()
+		f()
+	}
+	expectPanic("out of range target", ErrInvalidTarget, func() {
+		NewCircuit(2).Append(PauliX(), 2)
+	})
+	expectPanic("duplicate target", ErrDuplicateTarget, func() {
+		NewCircuit(2).Append(CX(), 1, 1)
+	})
+	expectPanic("wrong number of targets", ErrWidthMismatch, func() {
+		NewCircuit(2).Append(CX(), 0)
+	})
+	expectPanic("out of range measurement", ErrInvalidTarget, func() {
+		NewCircuit(2).Measure(2, 0)
+	})
+	expectPanic("negative classical bit", ErrInvalidTarget, func() {
+		NewCircuit(2).Measure(0, -1)
+	})
+	expectPanic("out of range barrier", ErrInvalidTarget, func() {
+		NewCircuit(2).Barrier(0, 2)
+	})
+	expectPanic("invalid kind", ErrInvalidOperation, func() {
+		NewCircuit(2).AppendOperation(Operation{Kind: OpKind(-1)})
+	})
+	expectPanic("wider sub-circuit", ErrWidthMismatch, func() {
+		NewCircuit(1).Compose(NewCircuit(2))
+	})
+	expectPanic("sub-circuit given too few qubits", ErrWidthMismatch, func() {
+		NewCircuit(3).Compose(NewCircuit(2), 0)
+	})
+	expectPanic("sub-circuit mapped onto one qubit", ErrDuplicateTarget, func() {
+		NewCircuit(3).Compose(NewCircuit(2), 1, 1)
+	})
+	expectPanic("narrower register", ErrWidthMismatch, func() {
+		NewCircuit(3).Run(NewQReg(2))
+	})
+
+	// A barrier may name a qubit more than once.
+	NewCircuit(2).Barrier(0, 0)
+}
+
+func TestCircuit_ResetAndConditions(t *testing.T) {
+	circuit := NewCircuit(3).
+		Append(PauliX(), 0).
+		Measure(0, 0).
+		Reset(0).
+		Barrier(0, 1, 2).
+		Measure(0, 1)
+	// Flip qubit 2 only if the classical bits read 01, i.e. c[0] = 1 and
+	// c[1] = 0, and qubit 1 only if they read 11.
+	circuit.AppendOperation(Operation{
+		Kind:      GateOp,
+		Gate:      PauliX(),
+		Targets:   []int{2},
+		Condition: &Condition{Clbits: []int{0, 1}, Value: 1}})
+	circuit.AppendOperation(Operation{
+		Kind:      GateOp,
+		Gate:      PauliX(),
+		Targets:   []int{1},
+		Condition: &Condition{Clbits: []int{0, 1}, Value: 3}})
+	circuit.ReserveClbits(4)
+	if circuit.NumClbits() != 4 {
+		t.Errorf("Bad number of classical bits = %d, expected 4.",
+			circuit.NumClbits())
+	}
+
+	qreg := NewQReg(3)
+	clbits := circuit.Run(qreg)
+	if clbits[0] != 1 || clbits[1] != 0 {
+		t.Errorf("Bad classical bits %v, expected [1 0 ...].", clbits)
+	}
+	if !verifyProb(1, qreg.StateProb(4)) {
+		t.Error("Expected |100>.")
+	}
+}
+
+// The decomposed QFT must agree with the QFT gate, and be undone by the
+// decomposed inverse.
+func TestQFTCircuit(t *testing.T) {
+	for width := 1; width <= 5; width++ {
+		expected := newDistinctQReg(width)
+		NewQFTGate(width).ApplyReg(expected)
+		actual := newDistinctQReg(width)
+		NewQFTCircuit(width).Run(actual)
+		if !verifySameState(expected, actual) {
+			t.Errorf("Bad QFT circuit of width %d.", width)
+		}
+		NewInverseQFTCircuit(width).Run(actual)
+		if !verifySameState(newDistinctQReg(width), actual) {
+			t.Errorf("Bad inverse QFT circuit of width %d.", width)
+		}
+	}
+}
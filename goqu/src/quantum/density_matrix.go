@@ -0,0 +1,380 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"math/rand"
+)
+
+// A DensityMatrix represents the state of a quantum register which may be in
+// a mixed state, i.e. a probabilistic mixture of pure states, such as one
+// which has lost coherence with its environment. Qubits are indexed according
+// to the matrix's bit order, as for a QReg.
+type DensityMatrix struct {
+	// The width (number of qubits) of the register described.
+	width int
+
+	// The elements of the matrix in the standard basis. The element in row
+	// r and column c is at index r + c<<width, so the low width bits of an
+	// index are the row label and the high width bits are the column label.
+	elements []complex128
+
+	// The source of randomness for measurements. If this is nil, the
+	// global source of randomness is used.
+	rng *rand.Rand
+
+	// The correspondence between qubit indices and bits of basis state
+	// labels.
+	order BitOrder
+}
+
+// Constructor for the DensityMatrix |psi><psi| of the pure state of a QReg.
+// The density matrix has the register's bit order and source of randomness.
+func NewDensityMatrix(qreg *QReg) *DensityMatrix {
+	dim := len(qreg.amplitudes)
+	dm := &DensityMatrix{
+		width:    qreg.width,
+		elements: make([]complex128, dim*dim),
+		rng:      qreg.rng,
+		order:    qreg.order}
+	parallelFor(dim, MaxWorkers(), func(start, end int) {
+		for col := start; col < end; col++ {
+			conj := cmplx.Conj(qreg.amplitudes[col])
+			for row, amplitude := range qreg.amplitudes {
+				dm.elements[row+col*dim] = amplitude * conj
+			}
+		}
+	})
+	return dm
+}
+
+// Compute the reduced density matrix of a subsystem of a register, i.e.
+// trace out all its qubits except the given ones. Qubit i of the returned
+// matrix is qubits[i] of the register, and the matrix has the register's bit
+// order and source of randomness.
+func (qreg *QReg) ReducedDensityMatrix(qubits []int) *DensityMatrix {
+	dm, err := qreg.TryReducedDensityMatrix(qubits)
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}
+
+// Like ReducedDensityMatrix, but returns ErrInvalidTarget or
+// ErrDuplicateTarget instead of panicking.
+func (qreg *QReg) TryReducedDensityMatrix(qubits []int) (*DensityMatrix, error) {
+	if err := checkQubits(qubits, qreg.width); err != nil {
+		return nil, err
+	}
+	dm := &DensityMatrix{
+		width: len(qubits),
+		rng:   qreg.rng,
+		order: qreg.order}
+	// Bit i of the labels of the reduced matrix holds the value of the
+	// register's qubit at position bits[i].
+	bits := make([]int, len(qubits))
+	for i, qubit := range qubits {
+		bits[dm.bitPos(i)] = int(qreg.bitPos(qubit))
+	}
+	rho := reducedMatrix(qreg.amplitudes, bits, MaxWorkers())
+	dim := dm.dim()
+	dm.elements = make([]complex128, len(rho))
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			dm.elements[row+col*dim] = rho[row*dim+col]
+		}
+	}
+	return dm, nil
+}
+
+// Accessor for the width of a DensityMatrix.
+func (dm *DensityMatrix) Width() int {
+	return dm.width
+}
+
+// The dimension of the Hilbert space of the register described.
+func (dm *DensityMatrix) dim() int {
+	return 1 << uint(dm.width)
+}
+
+// Accessor for the element of a DensityMatrix in the given row and column,
+// which are basis state labels.
+func (dm *DensityMatrix) Get(row, col int) complex128 {
+	return dm.elements[row+col*dm.dim()]
+}
+
+// Copy a DensityMatrix.
+func (dm *DensityMatrix) Copy() *DensityMatrix {
+	elements := make([]complex128, len(dm.elements))
+	copy(elements, dm.elements)
+	return &DensityMatrix{
+		width:    dm.width,
+		elements: elements,
+		rng:      dm.rng,
+		order:    dm.order}
+}
+
+// Set the source of randomness used for measurements of the DensityMatrix. If
+// source is nil, the global source of randomness is used.
+func (dm *DensityMatrix) SetRandSource(source rand.Source) {
+	if source == nil {
+		dm.rng = nil
+		return
+	}
+	dm.rng = rand.New(source)
+}
+
+// Accessor for the bit order of a DensityMatrix.
+func (dm *DensityMatrix) BitOrder() BitOrder {
+	return dm.order
+}
+
+// Set the bit order of the DensityMatrix. This only changes how qubits are
+// indexed, not the state described.
+func (dm *DensityMatrix) SetBitOrder(order BitOrder) {
+	dm.order = order
+}
+
+// The position within a basis state label of the bit holding the value of
+// the given qubit.
+func (dm *DensityMatrix) bitPos(qubit int) uint {
+	if dm.order == BigEndian {
+		return uint(dm.width - 1 - qubit)
+	}
+	return uint(qubit)
+}
+
+// Evolve the state by a gate, i.e. replace the matrix rho by U rho U^dag,
+// where U is the gate applied to the given targets as by Gate.Apply.
+func (dm *DensityMatrix) Apply(gate *Gate, targets []int) {
+	if err := dm.TryApply(gate, targets); err != nil {
+		panic(err)
+	}
+}
+
+// Like Apply, but returns ErrWidthMismatch, ErrInvalidTarget or
+// ErrDuplicateTarget instead of panicking. The matrix is left unchanged if an
+// error is returned.
+func (dm *DensityMatrix) TryApply(gate *Gate, targets []int) error {
+	if err := gate.checkTargets(targets, dm.width); err != nil {
+		return err
+	}
+	// Viewed as a state vector on twice as many qubits, the matrix is
+	// multiplied on the left by U by applying U to the row bits, and on
+	// the right by U^dag by applying the complex conjugate of U to the
+	// column bits.
+	rowBits := make([]int, len(targets))
+	colBits := make([]int, len(targets))
+	for i, target := range targets {
+		rowBits[i] = int(dm.bitPos(target))
+		colBits[i] = rowBits[i] + dm.width
+	}
+	gate.applyMasked(dm.elements, rowBits, 0, 0, MaxWorkers())
+	conjugate(gate).applyMasked(dm.elements, colBits, 0, 0, MaxWorkers())
+	return nil
+}
+
+// Return a gate whose matrix is the complex conjugate (not the Hermitian
+// conjugate) of the given gate's matrix. A gate with a real matrix is
+// returned unchanged, so that it keeps its specialised kernel.
+func conjugate(gate *Gate) *Gate {
+	if gate.base != nil {
+		return ControlledOn(conjugate(gate.base), gate.controls...)
+	}
+	if gate.permutation != nil {
+		return gate
+	}
+	dim := gate.dim()
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			if imag(gate.get(row, col)) != 0 {
+				return NewFuncGateNoCheck(func(row, col int) complex128 {
+					return cmplx.Conj(gate.get(row, col))
+				}, gate.width)
+			}
+		}
+	}
+	return gate
+}
+
+// The trace of the matrix, which is 1 for any valid state.
+func (dm *DensityMatrix) Trace() float64 {
+	sum := float64(0.0)
+	for label := 0; label < dm.dim(); label++ {
+		sum += real(dm.Get(label, label))
+	}
+	return sum
+}
+
+// The purity Tr(rho^2) of the state, which is 1 for a pure state and 1/2^n
+// for the maximally mixed state of n qubits.
+func (dm *DensityMatrix) Purity() float64 {
+	// Since rho is Hermitian, Tr(rho^2) is the sum of the squared
+	// magnitudes of its elements.
+	return parallelSum(len(dm.elements), MaxWorkers(), func(start, end int) float64 {
+		sum := float64(0.0)
+		for _, element := range dm.elements[start:end] {
+			sum += amplitudeProb(element)
+		}
+		return sum
+	})
+}
+
+// Test whether the state is pure, i.e. whether it can be described by a QReg.
+func (dm *DensityMatrix) IsPure() bool {
+	return math.Abs(dm.Purity()-1) < threshold
+}
+
+// Convert a pure state into a QReg with the same bit order and source of
+// randomness. Since a density matrix does not record the global phase of a
+// state, the amplitude of the most probable basis state is made real and
+// positive.
+func (dm *DensityMatrix) ToQReg() *QReg {
+	qreg, err := dm.TryToQReg()
+	if err != nil {
+		panic(err)
+	}
+	return qreg
+}
+
+// Like ToQReg, but returns ErrNotPure instead of panicking.
+func (dm *DensityMatrix) TryToQReg() (*QReg, error) {
+	if !dm.IsPure() {
+		return nil, fmt.Errorf("%w: purity %g", ErrNotPure, dm.Purity())
+	}
+	// For rho = |psi><psi|, column k of rho is psi times conj(psi_k), so
+	// the column of the most probable state k gives psi up to a phase.
+	k := 0
+	for label := 1; label < dm.dim(); label++ {
+		if real(dm.Get(label, label)) > real(dm.Get(k, k)) {
+			k = label
+		}
+	}
+	norm := complex(math.Sqrt(real(dm.Get(k, k))), 0)
+	qreg := &QReg{
+		width:      dm.width,
+		amplitudes: make([]complex128, dm.dim()),
+		rng:        dm.rng,
+		order:      dm.order}
+	for label := range qreg.amplitudes {
+		qreg.amplitudes[label] = dm.Get(label, k) / norm
+	}
+	return qreg, nil
+}
+
+// Compute the probability of observing a basis state.
+func (dm *DensityMatrix) StateProb(values ...int) float64 {
+	prob, err := dm.TryStateProb(values...)
+	if err != nil {
+		panic(err)
+	}
+	return prob
+}
+
+// Like StateProb, but returns ErrInvalidLabel instead of panicking.
+func (dm *DensityMatrix) TryStateProb(values ...int) (float64, error) {
+	label, err := (&QReg{width: dm.width}).tryBasisStateLabel(values...)
+	if err != nil {
+		return 0, err
+	}
+	return real(dm.Get(label, label)), nil
+}
+
+// Compute the probabilities of observing 0 and 1 when measuring a qubit, as
+// QReg.BProb.
+func (dm *DensityMatrix) BProb(bitIndex int) [2]float64 {
+	bitMask := 1 << dm.bitPos(bitIndex)
+	prob := float64(0.0)
+	for label := 0; label < dm.dim(); label++ {
+		if label&bitMask != 0 {
+			prob += real(dm.Get(label, label))
+		}
+	}
+	return [2]float64{1.0 - prob, prob}
+}
+
+// Simulate a measurement on a bit, i.e., get the result of the measurement
+// but without collapsing the state.
+func (dm *DensityMatrix) BMeasurePreserve(bitIndex int) int {
+	if randFloat64(dm.rng) < dm.BProb(bitIndex)[0] {
+		return 0
+	}
+	return 1
+}
+
+// Measure a bit, collapsing the state onto the subspace in which it has the
+// value observed.
+func (dm *DensityMatrix) BMeasure(bitIndex int) int {
+	if bitIndex < 0 || bitIndex >= dm.width {
+		panic(fmt.Errorf("%w: bit index %d", ErrInvalidTarget, bitIndex))
+	}
+	b := dm.BMeasurePreserve(bitIndex)
+	pos := dm.bitPos(bitIndex)
+	scale := complex(1/dm.BProb(bitIndex)[b], 0)
+	// Keep only the elements whose row and column both have the observed
+	// value of the bit, i.e. compute P rho P / p for the projector P.
+	mask := 1<<pos | 1<<(pos+uint(dm.width))
+	value := b<<pos | b<<(pos+uint(dm.width))
+	parallelFor(len(dm.elements), MaxWorkers(), func(start, end int) {
+		for i := start; i < end; i++ {
+			if i&mask == value {
+				dm.elements[i] *= scale
+			} else {
+				dm.elements[i] = 0
+			}
+		}
+	})
+	return b
+}
+
+// Simulate a measurement of every qubit, i.e., get the basis state label
+// observed without collapsing the state.
+func (dm *DensityMatrix) MeasurePreserve() int {
+	r := randFloat64(dm.rng)
+	sum := float64(0.0)
+	for label := 0; label < dm.dim(); label++ {
+		sum += real(dm.Get(label, label))
+		if r < sum {
+			return label
+		}
+	}
+	return dm.dim() - 1
+}
+
+// Measure every qubit, collapsing the state to the basis state observed.
+func (dm *DensityMatrix) Measure() int {
+	outputLabel := dm.MeasurePreserve()
+	for i := range dm.elements {
+		dm.elements[i] = 0
+	}
+	dm.elements[outputLabel+outputLabel*dm.dim()] = 1
+	return outputLabel
+}
+
+// Print the matrix, one row per line.
+func (dm *DensityMatrix) Print() {
+	for row := 0; row < dm.dim(); row++ {
+		for col := 0; col < dm.dim(); col++ {
+			fmt.Printf(" %+f", dm.Get(row, col))
+		}
+		fmt.Println()
+	}
+}
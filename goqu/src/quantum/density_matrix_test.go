@@ -0,0 +1,215 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// Helper function to test that a density matrix is |psi><psi| for the state
+// psi of a register.
+func verifyPureDensityMatrix(qreg *QReg, dm *DensityMatrix) bool {
+	for row, amplitude := range qreg.amplitudes {
+		for col, other := range qreg.amplitudes {
+			expected := amplitude * cmplx.Conj(other)
+			if cmplx.Abs(dm.Get(row, col)-expected) > threshold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestDensityMatrix_MatchesQReg(t *testing.T) {
+	gates := []struct {
+		gate    *Gate
+		targets []int
+	}{
+		{NewHadamardGate(1), []int{1}},
+		{U3(0.3, 0.7, -1.1), []int{2}},
+		{S(), []int{0}},
+		{ISwap(), []int{2, 0}},
+		{ControlledOn(RotationY(0.4), 0), []int{1, 2}},
+		{Controlled(Phase(0.9), 2), []int{2, 1, 0}},
+		{NewClassicalGate(func(x int) int { return (x + 3) % 8 }, 3),
+			[]int{1, 0, 2}},
+		{NewHadamardGate(3), []int{0, 1, 2}},
+	}
+	for _, order := range []BitOrder{LittleEndian, BigEndian} {
+		qreg := newDistinctQReg(3)
+		qreg.SetBitOrder(order)
+		dm := NewDensityMatrix(qreg)
+		for i, test := range gates {
+			test.gate.Apply(qreg, test.targets)
+			dm.Apply(test.gate, test.targets)
+			if !verifyPureDensityMatrix(qreg, dm) {
+				t.Errorf("Bad density matrix after gate %d with "+
+					"bit order %d.", i, order)
+			}
+		}
+		if !verifyProb(1, dm.Trace()) || !verifyProb(1, dm.Purity()) {
+			t.Errorf("Bad trace %g or purity %g, expected 1.",
+				dm.Trace(), dm.Purity())
+		}
+	}
+}
+
+func TestDensityMatrix_TryApply_Errors(t *testing.T) {
+	dm := NewDensityMatrix(NewQReg(2))
+	if err := dm.TryApply(CX(), []int{0}); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+	if err := dm.TryApply(CX(), []int{0, 2}); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget, got %v.", err)
+	}
+	if err := dm.TryApply(CX(), []int{1, 1}); !errors.Is(err, ErrDuplicateTarget) {
+		t.Errorf("Expected ErrDuplicateTarget, got %v.", err)
+	}
+}
+
+// Build the density matrix of a Bell state (|00> + |11>)/sqrt(2).
+func newBellDensityMatrix() *DensityMatrix {
+	qreg := NewQReg(2)
+	Hadamard(qreg, 0)
+	CX().Apply(qreg, []int{0, 1})
+	return NewDensityMatrix(qreg)
+}
+
+func TestDensityMatrix_Purity(t *testing.T) {
+	dm := newBellDensityMatrix()
+	if !dm.IsPure() {
+		t.Errorf("Bell state has purity %g, expected 1.", dm.Purity())
+	}
+
+	// Removing the coherences between |00> and |11> leaves an equal
+	// mixture of them.
+	dm.elements[3] = 0
+	dm.elements[12] = 0
+	if !verifyProb(0.5, dm.Purity()) || dm.IsPure() {
+		t.Errorf("Mixed state has purity %g, expected 0.5.", dm.Purity())
+	}
+	if !verifyProb(1, dm.Trace()) {
+		t.Errorf("Mixed state has trace %g, expected 1.", dm.Trace())
+	}
+	if _, err := dm.TryToQReg(); !errors.Is(err, ErrNotPure) {
+		t.Errorf("Expected ErrNotPure, got %v.", err)
+	}
+
+	// The maximally mixed state of two qubits has purity 1/4.
+	for i := range dm.elements {
+		dm.elements[i] = 0
+	}
+	for label := 0; label < 4; label++ {
+		dm.elements[label*5] = 0.25
+	}
+	if !verifyProb(0.25, dm.Purity()) {
+		t.Errorf("Maximally mixed state has purity %g, expected 0.25.",
+			dm.Purity())
+	}
+}
+
+func TestDensityMatrix_ToQReg(t *testing.T) {
+	original := newDistinctQReg(3)
+	original.SetBitOrder(BigEndian)
+	qreg := NewDensityMatrix(original).ToQReg()
+	if qreg.BitOrder() != BigEndian {
+		t.Errorf("Bit order not preserved.")
+	}
+
+	// A density matrix doesn't record the global phase of the state.
+	if !EqualUpToGlobalPhase(original, qreg, threshold) {
+		t.Errorf("Converted state differs from the original.")
+	}
+}
+
+func TestDensityMatrix_Measure(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		dm := newBellDensityMatrix()
+		dm.SetRandSource(rand.NewSource(seed))
+		if prob := dm.BProb(1); !verifyProb(0.5, prob[0]) {
+			t.Errorf("Bad probability %v, expected 0.5.", prob)
+		}
+		b := dm.BMeasure(1)
+		// Measuring one qubit of a Bell state collapses the other.
+		if !verifyProb(1, dm.BProb(0)[b]) ||
+			!verifyProb(1, dm.StateProb(b*3)) {
+			t.Errorf("Measured %d but state is not |%d%d>.", b, b, b)
+		}
+		if !verifyProb(1, dm.Trace()) || !dm.IsPure() {
+			t.Errorf("Bad trace %g or purity %g after measurement.",
+				dm.Trace(), dm.Purity())
+		}
+		if label := dm.Measure(); label != b*3 {
+			t.Errorf("Measured |%d>, expected |%d>.", label, b*3)
+		}
+	}
+}
+
+func TestQRegReducedDensityMatrix(t *testing.T) {
+	for _, order := range []BitOrder{LittleEndian, BigEndian} {
+		qreg := newDistinctQReg(3)
+		qreg.SetBitOrder(order)
+		qubits := []int{2, 0}
+		dm := qreg.ReducedDensityMatrix(qubits)
+		if dm.Width() != 2 || dm.BitOrder() != order {
+			t.Fatalf("Bad reduced density matrix of width %d.",
+				dm.Width())
+		}
+		// Sum over the values of the traced out qubit 1.
+		label := func(reduced, b1 int) int {
+			values := []int{0, b1, 0}
+			for i, qubit := range qubits {
+				values[qubit] = (reduced >> dm.bitPos(i)) & 1
+			}
+			label := 0
+			for qubit, value := range values {
+				label |= value << qreg.bitPos(qubit)
+			}
+			return label
+		}
+		for row := 0; row < 4; row++ {
+			for col := 0; col < 4; col++ {
+				expected := complex(0, 0)
+				for b1 := 0; b1 < 2; b1++ {
+					expected += qreg.amplitudes[label(row, b1)] *
+						cmplx.Conj(qreg.amplitudes[label(col, b1)])
+				}
+				if cmplx.Abs(dm.Get(row, col)-expected) > threshold {
+					t.Errorf("Bad element (%d, %d) = %v with "+
+						"bit order %d, expected %v.", row, col,
+						dm.Get(row, col), order, expected)
+				}
+			}
+		}
+	}
+
+	// Either qubit of a Bell state on its own is maximally mixed.
+	bell := NewQReg(2)
+	Hadamard(bell, 0)
+	CX().Apply(bell, []int{0, 1})
+	if purity := bell.ReducedDensityMatrix([]int{1}).Purity(); !verifyProb(0.5, purity) {
+		t.Errorf("Qubit of a Bell state has purity %g, expected 0.5.",
+			purity)
+	}
+	if _, err := bell.TryReducedDensityMatrix([]int{0, 0}); !errors.Is(err, ErrDuplicateTarget) {
+		t.Errorf("Expected ErrDuplicateTarget, got %v.", err)
+	}
+}
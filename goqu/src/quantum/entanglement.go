@@ -0,0 +1,273 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// The measures in this file are computed from the reduced density matrices
+// of subsystems of a register, by diagonalising them, so they are meant for
+// subsystems of a few qubits. The qubits are indexed according to the
+// register's bit order, and entropies are measured in bits.
+
+// The smallest eigenvalue of a density matrix which is not treated as zero.
+const eigenvalueThreshold = 1e-12
+
+// The eigenvalues of a density matrix, in decreasing order, and its
+// eigenvectors, as for hermitianEigen.
+func (dm *DensityMatrix) eigen() ([]float64, []complex128) {
+	dim := dm.dim()
+	matrix := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			matrix[row*dim+col] = dm.Get(row, col)
+		}
+	}
+	return hermitianEigen(matrix, dim)
+}
+
+// The von Neumann entropy -Tr(rho log2 rho) of the state, which is 0 for a
+// pure state and the width for the maximally mixed state.
+func (dm *DensityMatrix) VonNeumannEntropy() float64 {
+	values, _ := dm.eigen()
+	return entropy(values)
+}
+
+// The Shannon entropy in bits of a probability distribution.
+func entropy(probs []float64) float64 {
+	sum := float64(0.0)
+	for _, p := range probs {
+		if p > eigenvalueThreshold {
+			sum -= p * math.Log2(p)
+		}
+	}
+	return sum
+}
+
+// The von Neumann entropy of the subsystem of the given qubits, which for a
+// bipartition of the register into those qubits and the rest measures the
+// entanglement between them.
+func (qreg *QReg) VonNeumannEntropy(qubits []int) float64 {
+	return qreg.ReducedDensityMatrix(qubits).VonNeumannEntropy()
+}
+
+// The mutual information S(A) + S(B) - S(AB) between two disjoint subsystems,
+// which counts both their classical and quantum correlations.
+func (qreg *QReg) MutualInformation(partA, partB []int) float64 {
+	both := append(append([]int(nil), partA...), partB...)
+	if err := checkQubits(both, qreg.width); err != nil {
+		panic(err)
+	}
+	return qreg.VonNeumannEntropy(partA) + qreg.VonNeumannEntropy(partB) -
+		qreg.VonNeumannEntropy(both)
+}
+
+// The qubits of the register which are not in part, in increasing order.
+func (qreg *QReg) complement(part []int) []int {
+	if err := checkQubits(part, qreg.width); err != nil {
+		panic(err)
+	}
+	inPart := make([]bool, qreg.width)
+	for _, qubit := range part {
+		inPart[qubit] = true
+	}
+	var rest []int
+	for qubit, in := range inPart {
+		if !in {
+			rest = append(rest, qubit)
+		}
+	}
+	return rest
+}
+
+// The label of the basis state of the subsystem of the given qubits, with the
+// register's bit order, in the basis state of the register with the given
+// label.
+func (qreg *QReg) subsystemLabel(label int, qubits []int) int {
+	subLabel := 0
+	for i, qubit := range qubits {
+		pos := uint(i)
+		if qreg.order == BigEndian {
+			pos = uint(len(qubits) - 1 - i)
+		}
+		subLabel |= ((label >> qreg.bitPos(qubit)) & 1) << pos
+	}
+	return subLabel
+}
+
+// Compute the Schmidt decomposition of the state with respect to the
+// bipartition into the given qubits A and the rest B, i.e. the positive
+// coefficients s_k, in decreasing order, and the orthonormal states a_k of A
+// and b_k of B such that the state is sum_k s_k a_k (x) b_k. Qubit i of a_k
+// is partA[i] of the register, and the qubits of b_k are the rest of the
+// register in increasing order. The state is entangled across the
+// bipartition exactly when there is more than one coefficient.
+func (qreg *QReg) SchmidtDecomposition(partA []int) ([]float64, []*QReg, []*QReg) {
+	partB := qreg.complement(partA)
+	values, vectors := qreg.ReducedDensityMatrix(partA).eigen()
+	dimA := 1 << uint(len(partA))
+
+	var coefficients []float64
+	var statesA, statesB []*QReg
+	for k, value := range values {
+		if value <= eigenvalueThreshold {
+			break
+		}
+		a := &QReg{
+			width:      len(partA),
+			amplitudes: make([]complex128, dimA),
+			rng:        qreg.rng,
+			order:      qreg.order}
+		for i := range a.amplitudes {
+			a.amplitudes[i] = vectors[i*dimA+k]
+		}
+		// b_k = (<a_k| (x) I) psi / s_k.
+		s := math.Sqrt(value)
+		b := &QReg{
+			width:      len(partB),
+			amplitudes: make([]complex128, 1<<uint(len(partB))),
+			rng:        qreg.rng,
+			order:      qreg.order}
+		for label, amplitude := range qreg.amplitudes {
+			labelA := qreg.subsystemLabel(label, partA)
+			labelB := qreg.subsystemLabel(label, partB)
+			b.amplitudes[labelB] += cmplx.Conj(a.amplitudes[labelA]) *
+				amplitude
+		}
+		for i := range b.amplitudes {
+			b.amplitudes[i] /= complex(s, 0)
+		}
+		coefficients = append(coefficients, s)
+		statesA = append(statesA, a)
+		statesB = append(statesB, b)
+	}
+	return coefficients, statesA, statesB
+}
+
+// The Schmidt coefficients of the state with respect to the bipartition into
+// the given qubits and the rest, as returned by SchmidtDecomposition.
+func (qreg *QReg) SchmidtCoefficients(partA []int) []float64 {
+	qreg.complement(partA)
+	values, _ := qreg.ReducedDensityMatrix(partA).eigen()
+	var coefficients []float64
+	for _, value := range values {
+		if value <= eigenvalueThreshold {
+			break
+		}
+		coefficients = append(coefficients, math.Sqrt(value))
+	}
+	return coefficients
+}
+
+// The concurrence of the state of two qubits of the register, which is 0 when
+// they are not entangled with each other and 1 when they are maximally
+// entangled, as in a Bell state.
+func (qreg *QReg) Concurrence(qubitA, qubitB int) float64 {
+	return qreg.ReducedDensityMatrix([]int{qubitA, qubitB}).Concurrence()
+}
+
+// The concurrence of the state of two qubits, computed with Wootters' formula
+// max(0, l1 - l2 - l3 - l4), where the l_i are the square roots of the
+// eigenvalues of rho (Y (x) Y) conj(rho) (Y (x) Y) in decreasing order.
+func (dm *DensityMatrix) Concurrence() float64 {
+	if dm.width != 2 {
+		panic(fmt.Errorf("%w: concurrence of %d qubits",
+			ErrWidthMismatch, dm.width))
+	}
+	// The l_i are also the square roots of the eigenvalues of the Hermitian
+	// matrix sqrt(rho) tilde(rho) sqrt(rho), where tilde(rho) is the spin
+	// flip of rho.
+	const dim = 4
+	values, vectors := dm.eigen()
+	sqrtRho := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			for k, value := range values {
+				if value > 0 {
+					sqrtRho[row*dim+col] += complex(math.Sqrt(value), 0) *
+						vectors[row*dim+k] * cmplx.Conj(vectors[col*dim+k])
+				}
+			}
+		}
+	}
+	// Y (x) Y sends |x> to -|3 - x> for x = 0, 3 and to |3 - x> for x = 1,
+	// 2, so tilde(rho) only reverses and conjugates the elements, with a
+	// sign for each of the row and column which is 0 or 3.
+	sign := [dim]float64{-1, 1, 1, -1}
+	flipped := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			flipped[row*dim+col] = complex(sign[row]*sign[col], 0) *
+				cmplx.Conj(dm.Get(3-row, 3-col))
+		}
+	}
+	product := matMul(matMul(sqrtRho, flipped, dim), sqrtRho, dim)
+	lambdas, _ := hermitianEigen(product, dim)
+	concurrence := math.Sqrt(math.Max(lambdas[0], 0))
+	for _, lambda := range lambdas[1:] {
+		concurrence -= math.Sqrt(math.Max(lambda, 0))
+	}
+	return math.Max(concurrence, 0)
+}
+
+// Test whether two disjoint subsystems of the register are entangled with
+// each other. If together they make up the whole register, the test is exact:
+// the state is entangled when it has more than one Schmidt coefficient.
+// Otherwise their joint state is mixed, and the Peres-Horodecki criterion is
+// used: the subsystems are entangled if the partial transpose of their
+// density matrix has a negative eigenvalue. This is exact only for two
+// qubits; larger subsystems, even a qubit and two qubits, may be entangled
+// without being detected.
+func (qreg *QReg) IsEntangled(partA, partB []int) bool {
+	both := append(append([]int(nil), partA...), partB...)
+	if err := checkQubits(both, qreg.width); err != nil {
+		panic(err)
+	}
+	if len(partA) == 0 || len(partB) == 0 {
+		return false
+	}
+	if len(both) == qreg.width {
+		// Diagonalise the smaller of the two reduced density matrices.
+		if len(partB) < len(partA) {
+			partA = partB
+		}
+		return len(qreg.SchmidtCoefficients(partA)) > 1
+	}
+
+	dm := qreg.ReducedDensityMatrix(both)
+	dim := dm.dim()
+	// Transposing B swaps the bits of the row and column labels which
+	// belong to the qubits of B.
+	maskB := 0
+	for i := len(partA); i < len(both); i++ {
+		maskB |= 1 << dm.bitPos(i)
+	}
+	transposed := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			newRow := row&^maskB | col&maskB
+			newCol := col&^maskB | row&maskB
+			transposed[newRow*dim+newCol] = dm.Get(row, col)
+		}
+	}
+	values, _ := hermitianEigen(transposed, dim)
+	return values[dim-1] < -eigenvalueThreshold
+}
@@ -0,0 +1,186 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// Build the GHZ state (|0...0> + |1...1>)/sqrt(2) of the given width.
+func newGHZState(width int) *QReg {
+	qreg := NewQReg(width)
+	Hadamard(qreg, 0)
+	for i := 0; i+1 < width; i++ {
+		CX().Apply(qreg, []int{i, i + 1})
+	}
+	return qreg
+}
+
+// Build the W state (|001> + |010> + |100>)/sqrt(3).
+func newWState() *QReg {
+	qreg := NewQReg(3)
+	for _, label := range []int{1, 2, 4} {
+		qreg.amplitudes[label] = complex(1/math.Sqrt(3), 0)
+	}
+	qreg.amplitudes[0] = 0
+	return qreg
+}
+
+func TestVonNeumannEntropy(t *testing.T) {
+	ghz := newGHZState(3)
+	product := Compose(KetPlus(), newGHZState(2))
+	tests := []struct {
+		qreg     *QReg
+		qubits   []int
+		expected float64
+	}{
+		{newGHZState(2), []int{0}, 1},
+		{ghz, []int{0}, 1},
+		{ghz, []int{0, 2}, 1},
+		{ghz, []int{0, 1, 2}, 0},
+		{product, []int{2}, 0},
+		{product, []int{1}, 1},
+		{newWState(), []int{1}, -(math.Log2(1.0/3)/3 + math.Log2(2.0/3)*2/3)},
+	}
+	for i, test := range tests {
+		actual := test.qreg.VonNeumannEntropy(test.qubits)
+		if !verifyProb(test.expected, actual) {
+			t.Errorf("Test %d: entropy %g, expected %g.", i, actual,
+				test.expected)
+		}
+	}
+
+	// The maximally mixed state of two qubits has an entropy of 2 bits.
+	mixed := newGHZState(4).ReducedDensityMatrix([]int{0, 1})
+	mixed.ApplyChannel(NewDepolarizingChannel(2, 1), []int{0, 1})
+	if entropy := mixed.VonNeumannEntropy(); !verifyProb(2, entropy) {
+		t.Errorf("Maximally mixed state has entropy %g, expected 2.",
+			entropy)
+	}
+}
+
+func TestMutualInformation(t *testing.T) {
+	if mi := newGHZState(2).MutualInformation([]int{0}, []int{1}); !verifyProb(2, mi) {
+		t.Errorf("Bell state has mutual information %g, expected 2.", mi)
+	}
+	// Two qubits of a GHZ state are only classically correlated.
+	if mi := newGHZState(3).MutualInformation([]int{0}, []int{2}); !verifyProb(1, mi) {
+		t.Errorf("GHZ state has mutual information %g, expected 1.", mi)
+	}
+	product := Compose(KetPlus(), KetMinusI())
+	if mi := product.MutualInformation([]int{0}, []int{1}); !verifyProb(0, mi) {
+		t.Errorf("Product state has mutual information %g, expected 0.",
+			mi)
+	}
+}
+
+func TestSchmidtDecomposition(t *testing.T) {
+	coefficients := newGHZState(3).SchmidtCoefficients([]int{1})
+	if len(coefficients) != 2 || !verifyProb(math.Sqrt(0.5), coefficients[0]) ||
+		!verifyProb(math.Sqrt(0.5), coefficients[1]) {
+		t.Errorf("GHZ state has Schmidt coefficients %v, expected two "+
+			"of 1/sqrt(2).", coefficients)
+	}
+	if coefficients := Compose(KetPlus(), KetOne()).SchmidtCoefficients([]int{0}); len(coefficients) != 1 {
+		t.Errorf("Product state has Schmidt coefficients %v.",
+			coefficients)
+	}
+
+	// Check that sum_k s_k a_k (x) b_k gives back the state.
+	for _, order := range []BitOrder{LittleEndian, BigEndian} {
+		qreg := newDistinctQReg(4)
+		qreg.SetBitOrder(order)
+		partA := []int{3, 1}
+		partB := []int{0, 2}
+		coefficients, statesA, statesB := qreg.SchmidtDecomposition(partA)
+		for label, amplitude := range qreg.amplitudes {
+			labelA := qreg.subsystemLabel(label, partA)
+			labelB := qreg.subsystemLabel(label, partB)
+			sum := complex(0, 0)
+			for k, s := range coefficients {
+				sum += complex(s, 0) * statesA[k].amplitudes[labelA] *
+					statesB[k].amplitudes[labelB]
+			}
+			if cmplx.Abs(sum-amplitude) > threshold {
+				t.Errorf("Amplitude of |%d> is %v, expected %v "+
+					"with bit order %d.", label, sum,
+					amplitude, order)
+			}
+		}
+		for k := range statesB {
+			if !verifyProb(1, statesB[k].StateProb(0)+
+				statesB[k].StateProb(1)+statesB[k].StateProb(2)+
+				statesB[k].StateProb(3)) {
+				t.Errorf("State b_%d is not normalised.", k)
+			}
+		}
+	}
+}
+
+func TestConcurrence(t *testing.T) {
+	tests := []struct {
+		qreg     *QReg
+		qubitA   int
+		qubitB   int
+		expected float64
+	}{
+		{newGHZState(2), 0, 1, 1},
+		{Compose(KetPlus(), KetOne()), 0, 1, 0},
+		{newGHZState(3), 0, 2, 0},
+		{newWState(), 0, 1, 2.0 / 3},
+		{newWState(), 2, 0, 2.0 / 3},
+	}
+	for i, test := range tests {
+		actual := test.qreg.Concurrence(test.qubitA, test.qubitB)
+		if math.Abs(actual-test.expected) > 1e-6 {
+			t.Errorf("Test %d: concurrence %g, expected %g.", i,
+				actual, test.expected)
+		}
+	}
+}
+
+func TestIsEntangled(t *testing.T) {
+	ghz := newGHZState(3)
+	product := Compose(newGHZState(2), KetPlus())
+	tests := []struct {
+		qreg     *QReg
+		partA    []int
+		partB    []int
+		expected bool
+	}{
+		{newGHZState(2), []int{0}, []int{1}, true},
+		{ghz, []int{0}, []int{1, 2}, true},
+		{ghz, []int{2, 0}, []int{1}, true},
+		// Tracing out one qubit of a GHZ state leaves a separable
+		// mixture, unlike for a W state.
+		{ghz, []int{0}, []int{1}, false},
+		{newWState(), []int{0}, []int{1}, true},
+		{product, []int{0}, []int{1, 2}, false},
+		{product, []int{1, 2}, []int{0}, false},
+		{product, []int{1}, []int{2}, true},
+		{product, []int{0}, []int{}, false},
+	}
+	for i, test := range tests {
+		if actual := test.qreg.IsEntangled(test.partA, test.partB); actual != test.expected {
+			t.Errorf("Test %d: IsEntangled returned %v, expected %v.",
+				i, actual, test.expected)
+		}
+	}
+}
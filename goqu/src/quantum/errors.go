@@ -0,0 +1,78 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+)
+
+// The errors returned by the Try* variants of functions which otherwise
+// panic on invalid input. The returned errors wrap these with the details of
+// the failure, so they should be checked with errors.Is.
+var (
+	// A gate's matrix is not unitary.
+	ErrNotUnitary = errors.New("quantum: gate is not unitary")
+
+	// A target or bit index does not refer to a qubit of the register.
+	ErrInvalidTarget = errors.New("quantum: invalid target")
+
+	// The same qubit appears more than once in a list of targets.
+	ErrDuplicateTarget = errors.New("quantum: duplicate target")
+
+	// The sizes of two objects which should agree do not, e.g. a gate's
+	// matrix is not of size 2^n by 2^n.
+	ErrWidthMismatch = errors.New("quantum: width mismatch")
+
+	// A basis state label or bit value is not valid for the register.
+	ErrInvalidLabel = errors.New("quantum: invalid basis state label")
+
+	// Compose was called with fewer than two quantum registers.
+	ErrTooFewRegisters = errors.New("quantum: too few quantum registers")
+
+	// An operation of a circuit is not of a known kind.
+	ErrInvalidOperation = errors.New("quantum: invalid operation")
+
+	// A quantum register given to a function is nil.
+	ErrNilRegister = errors.New("quantum: nil quantum register")
+
+	// A density matrix does not describe a pure state, so it cannot be
+	// converted to a quantum register.
+	ErrNotPure = errors.New("quantum: density matrix is not pure")
+
+	// The Kraus operators of a channel do not satisfy the completeness
+	// relation sum_k K_k^dag K_k = I, so the channel does not preserve the
+	// trace of a state.
+	ErrNotComplete = errors.New("quantum: Kraus operators are not complete")
+
+	// A parameter of a channel or noise model which is a probability is
+	// not between 0 and 1, or probabilities which should sum to 1 do not.
+	ErrInvalidProbability = errors.New("quantum: invalid probability")
+
+	// A physical parameter of a channel is out of range, e.g. a negative
+	// duration.
+	ErrInvalidParameter = errors.New("quantum: invalid parameter")
+
+	// A state cannot be split into the requested subsystems, because they
+	// are entangled.
+	ErrEntangled = errors.New("quantum: state is entangled")
+
+	// A number of qubits is out of range, e.g. the number of precision bits
+	// of phase estimation is not positive, or the width of a register is
+	// negative or too large for its amplitudes to be allocated.
+	ErrInvalidWidth = errors.New("quantum: invalid number of qubits")
+)
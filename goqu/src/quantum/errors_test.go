@@ -0,0 +1,193 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTryNewGate_Errors(t *testing.T) {
+	if _, err := TryNewRealArrayGate([]float64{1, 1, 1, 1}); !errors.Is(err, ErrNotUnitary) {
+		t.Errorf("Expected ErrNotUnitary, got %v.", err)
+	}
+	if _, err := TryNewRealArrayGate([]float64{1, 0, 0}); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch for 3 elements, got %v.", err)
+	}
+	// A 3x3 matrix is square, but not over a whole number of qubits.
+	identity3 := []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	if _, err := TryNewRealArrayGate(identity3); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch for 3x3 matrix, got %v.", err)
+	}
+	notPermutation := func(x int) int { return 0 }
+	if _, err := TryNewClassicalGate(notPermutation, 2); !errors.Is(err, ErrNotUnitary) {
+		t.Errorf("Expected ErrNotUnitary, got %v.", err)
+	}
+	gate, err := TryNewRealArrayGate([]float64{0, 1, 1, 0})
+	if err != nil || !verifyGate(PauliX(), gate) {
+		t.Errorf("Expected Pauli X, got error %v.", err)
+	}
+}
+
+func TestTryApply_Errors(t *testing.T) {
+	tests := []struct {
+		gate     *Gate
+		targets  []int
+		expected error
+	}{
+		{PauliX(), []int{2}, ErrInvalidTarget},
+		{PauliX(), []int{-1}, ErrInvalidTarget},
+		{CX(), []int{1, 1}, ErrDuplicateTarget},
+		{CCX(), []int{0, 1, 0}, ErrDuplicateTarget},
+		{CX(), []int{0}, ErrWidthMismatch},
+		{PauliX(), []int{0, 1}, ErrWidthMismatch},
+		{PauliX(), nil, ErrWidthMismatch},
+	}
+	for _, test := range tests {
+		qreg := newDistinctQReg(2)
+		original := qreg.Copy()
+		err := test.gate.TryApply(qreg, test.targets)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Targets %v: expected %v, got %v.",
+				test.targets, test.expected, err)
+		}
+		for label := range qreg.amplitudes {
+			if qreg.amplitudes[label] != original.amplitudes[label] {
+				t.Errorf("Targets %v: register changed.",
+					test.targets)
+				break
+			}
+		}
+	}
+
+	qreg := NewQReg(2)
+	if err := PauliX().TryApply(qreg, []int{1}); err != nil {
+		t.Errorf("Unexpected error %v.", err)
+	}
+	if err := CX().TryApply(qreg, []int{1, 0}); err != nil || !isBasisState(qreg, 3) {
+		t.Errorf("Expected |11>, got error %v.", err)
+	}
+}
+
+func TestApply_PanicsOnInvalidTargets(t *testing.T) {
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrDuplicateTarget) {
+			t.Errorf("Expected panic with ErrDuplicateTarget, got %v.", err)
+		}
+	}()
+	CX().Apply(NewQReg(2), []int{0, 0})
+}
+
+func TestSampleQubits_PanicsOnInvalidQubits(t *testing.T) {
+	tests := []struct {
+		qubits   []int
+		expected error
+	}{
+		{[]int{2}, ErrInvalidTarget},
+		{[]int{0, 0}, ErrDuplicateTarget},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || !errors.Is(err, test.expected) {
+					t.Errorf("Qubits %v: expected panic with %v, got %v.",
+						test.qubits, test.expected, err)
+				}
+			}()
+			NewQReg(2).SampleQubits(1, nil, test.qubits)
+		}()
+	}
+}
+
+func TestBMeasure_PanicsOnInvalidBit(t *testing.T) {
+	defer func() {
+		if err, ok := recover().(error); !ok || !errors.Is(err, ErrInvalidTarget) {
+			t.Errorf("Expected panic with ErrInvalidTarget, got %v.", err)
+		}
+	}()
+	NewQReg(2).BMeasure(2)
+}
+
+func TestTryQReg_Errors(t *testing.T) {
+	if _, err := TryNewQReg(2, 4); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for |4>, got %v.", err)
+	}
+	if _, err := TryNewQReg(2, 0, 2); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for |02>, got %v.", err)
+	}
+	if _, err := TryNewQReg(3, 0, 1); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for two values, got %v.", err)
+	}
+	if _, err := TryNewQReg(-1); !errors.Is(err, ErrInvalidWidth) {
+		t.Errorf("Expected ErrInvalidWidth for width -1, got %v.", err)
+	}
+	for _, width := range []int{31, 62, 64} {
+		if _, err := TryNewQReg(width); !errors.Is(err, ErrInvalidWidth) {
+			t.Errorf("Expected ErrInvalidWidth for width %d, got %v.",
+				width, err)
+		}
+	}
+
+	qreg := NewQReg(2, 3)
+	if err := qreg.TrySet(-1); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for |-1>, got %v.", err)
+	}
+	if !isBasisState(qreg, 3) {
+		t.Error("Expected register to be unchanged.")
+	}
+	if _, err := qreg.TryStateProb(5); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for |5>, got %v.", err)
+	}
+	if err := qreg.TryBSet(0, 2); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("Expected ErrInvalidLabel for value 2, got %v.", err)
+	}
+	if err := qreg.TryBSet(2, 0); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget for bit 2, got %v.", err)
+	}
+	if _, err := qreg.TryBProb(2); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget for bit 2, got %v.", err)
+	}
+	if _, err := qreg.TryBMeasurePreserve(-1); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget for bit -1, got %v.", err)
+	}
+	if _, err := qreg.TryBMeasure(2); !errors.Is(err, ErrInvalidTarget) {
+		t.Errorf("Expected ErrInvalidTarget for bit 2, got %v.", err)
+	}
+	if !isBasisState(qreg, 3) {
+		t.Error("Expected register to be unchanged.")
+	}
+	if b, err := qreg.TryBMeasure(1); err != nil || b != 1 {
+		t.Errorf("Expected 1, got %d with error %v.", b, err)
+	}
+
+	if _, err := TryCompose(KetZero()); !errors.Is(err, ErrTooFewRegisters) {
+		t.Errorf("Expected ErrTooFewRegisters, got %v.", err)
+	}
+	if _, err := TryCompose(KetOne(), nil); !errors.Is(err, ErrNilRegister) {
+		t.Errorf("Expected ErrNilRegister, got %v.", err)
+	}
+	// The widths are checked before any amplitudes are allocated.
+	wide := &QReg{width: 20}
+	if _, err := TryCompose(wide, wide); !errors.Is(err, ErrInvalidWidth) {
+		t.Errorf("Expected ErrInvalidWidth for width 40, got %v.", err)
+	}
+	composed, err := TryCompose(KetOne(), KetZero())
+	if err != nil || !isBasisState(composed, 2) {
+		t.Errorf("Expected |10>, got error %v.", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// The functions in this file compare the pure states of two registers of the
+// same width. Their amplitudes are compared label by label, so their bit
+// orders are ignored.
+
+// Compute the inner product <a|b> of the states of two registers.
+func InnerProduct(a, b *QReg) complex128 {
+	if a.width != b.width {
+		panic(fmt.Errorf("%w: registers of widths %d and %d",
+			ErrWidthMismatch, a.width, b.width))
+	}
+	bounds := chunkBounds(len(a.amplitudes), minChunkSize, MaxWorkers())
+	partials := make([]complex128, len(bounds)-1)
+	forEachChunk(bounds, func(chunk, start, end int) {
+		for label := start; label < end; label++ {
+			partials[chunk] += cmplx.Conj(a.amplitudes[label]) * b.amplitudes[label]
+		}
+	})
+	sum := complex(0, 0)
+	for _, partial := range partials {
+		sum += partial
+	}
+	return sum
+}
+
+// The fidelity |<a|b>|^2 of the states of two registers, which is 1 when they
+// are the same up to a global phase and 0 when they are orthogonal.
+func Fidelity(a, b *QReg) float64 {
+	return amplitudeProb(InnerProduct(a, b))
+}
+
+// The trace distance between the states of two registers, i.e. the largest
+// difference in the probability of any outcome of any measurement of them,
+// which is sqrt(1 - F) for pure states of fidelity F.
+func TraceDistance(a, b *QReg) float64 {
+	// Computing 1 - F directly loses half the digits of precision when
+	// the states are close, so it is found from the distance d between b
+	// and a with its phase aligned, as 1 - F = d^2 (1 + |<a|b>|) / 2.
+	overlap, phase := alignPhase(a, b)
+	distance := 0.0
+	for label, amplitude := range a.amplitudes {
+		distance += amplitudeProb(b.amplitudes[label] - phase*amplitude)
+	}
+	return math.Sqrt(distance * (1 + cmplx.Abs(overlap)) / 2)
+}
+
+// Test whether the states of two registers are the same up to a global phase,
+// i.e. whether there is a phase e^{i phi} such that each amplitude of b is
+// within tol of e^{i phi} times the corresponding amplitude of a. Registers of
+// different widths are never equal.
+func EqualUpToGlobalPhase(a, b *QReg, tol float64) bool {
+	if a.width != b.width {
+		return false
+	}
+	_, phase := alignPhase(a, b)
+	for label, amplitude := range a.amplitudes {
+		if cmplx.Abs(b.amplitudes[label]-phase*amplitude) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// Find the inner product <a|b> and the phase e^{i phi} which brings a closest
+// to b, which is that of the inner product.
+func alignPhase(a, b *QReg) (overlap, phase complex128) {
+	overlap = InnerProduct(a, b)
+	phase = complex(1, 0)
+	if abs := cmplx.Abs(overlap); abs > 0 {
+		phase = overlap / complex(abs, 0)
+	}
+	return overlap, phase
+}
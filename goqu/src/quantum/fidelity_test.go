@@ -0,0 +1,141 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestInnerProduct(t *testing.T) {
+	plus := KetPlus()
+	minusI := KetMinusI()
+	// <+|-i> = (1 - i)/2.
+	if actual := InnerProduct(plus, minusI); !verifyAmplitude(complex(0.5, -0.5), actual) {
+		t.Errorf("Bad inner product %v, expected (0.5-0.5i).", actual)
+	}
+	// Swapping the arguments conjugates the inner product.
+	if actual := InnerProduct(minusI, plus); !verifyAmplitude(complex(0.5, 0.5), actual) {
+		t.Errorf("Bad inner product %v, expected (0.5+0.5i).", actual)
+	}
+	qreg := newDistinctQReg(4)
+	if actual := InnerProduct(qreg, qreg); !verifyAmplitude(1, actual) {
+		t.Errorf("State has inner product %v with itself.", actual)
+	}
+}
+
+func TestInnerProduct_WidthMismatch(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		if !ok || !errors.Is(err, ErrWidthMismatch) {
+			t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+		}
+	}()
+	InnerProduct(NewQReg(1), NewQReg(2))
+}
+
+func TestFidelity(t *testing.T) {
+	tests := []struct {
+		a, b     *QReg
+		fidelity float64
+	}{
+		{NewQReg(1, 0), NewQReg(1, 0), 1},
+		{NewQReg(1, 0), NewQReg(1, 1), 0},
+		{NewQReg(1, 0), KetPlus(), 0.5},
+		{KetPlus(), KetMinusI(), 0.5},
+		{newGHZState(3), NewQReg(3, 7), 0.5},
+		{newDistinctQReg(3), newDistinctQReg(3), 1},
+	}
+	for i, test := range tests {
+		if actual := Fidelity(test.a, test.b); !verifyProb(test.fidelity, actual) {
+			t.Errorf("Test %d: bad fidelity %g, expected %g.", i,
+				actual, test.fidelity)
+		}
+		expected := math.Sqrt(1 - test.fidelity)
+		if actual := TraceDistance(test.a, test.b); !verifyProb(expected, actual) {
+			t.Errorf("Test %d: bad trace distance %g, expected %g.", i,
+				actual, expected)
+		}
+	}
+}
+
+// The trace distance of two pure states must agree with that of their density
+// matrices, i.e. half the sum of the magnitudes of the eigenvalues of their
+// difference.
+func TestTraceDistance_DensityMatrix(t *testing.T) {
+	a := newDistinctQReg(2)
+	b := NewQReg(2)
+	Hadamard(b, 0)
+	RotationY(0.7).Apply(b, []int{1})
+	difference := NewDensityMatrix(a)
+	other := NewDensityMatrix(b)
+	for i := range difference.elements {
+		difference.elements[i] -= other.elements[i]
+	}
+	values, _ := hermitianEigen(difference.elements, difference.dim())
+	expected := 0.0
+	for _, value := range values {
+		expected += math.Abs(value) / 2
+	}
+	if actual := TraceDistance(a, b); !verifyProb(expected, actual) {
+		t.Errorf("Bad trace distance %g, expected %g.", actual, expected)
+	}
+}
+
+func TestEqualUpToGlobalPhase(t *testing.T) {
+	qreg := newDistinctQReg(3)
+	for _, theta := range []float64{0, 0.4, math.Pi, -2} {
+		rotated := qreg.Copy()
+		for i := range rotated.amplitudes {
+			rotated.amplitudes[i] *= cmplx.Rect(1, theta)
+		}
+		name := fmt.Sprintf("phase %g", theta)
+		if !EqualUpToGlobalPhase(qreg, rotated, threshold) {
+			t.Errorf("Expected equality up to a %s.", name)
+		}
+		if theta != 0 && verifySameState(qreg, rotated) {
+			t.Errorf("Expected a %s to be detected.", name)
+		}
+	}
+
+	// A relative phase is not a global phase.
+	other := qreg.Copy()
+	PauliZ().Apply(other, []int{1})
+	if EqualUpToGlobalPhase(qreg, other, 1e-3) {
+		t.Error("Expected a relative phase to be detected.")
+	}
+	if EqualUpToGlobalPhase(NewQReg(1, 0), NewQReg(1, 1), 0.5) {
+		t.Error("Expected orthogonal states to differ.")
+	}
+	if EqualUpToGlobalPhase(NewQReg(1), NewQReg(2), 1) {
+		t.Error("Expected registers of different widths to differ.")
+	}
+
+	// Small differences are allowed by the tolerance.
+	perturbed := qreg.Copy()
+	perturbed.amplitudes[0] += 1e-6
+	if EqualUpToGlobalPhase(qreg, perturbed, threshold) {
+		t.Error("Expected a perturbation to be detected.")
+	}
+	if !EqualUpToGlobalPhase(qreg, perturbed, 1e-5) {
+		t.Error("Expected a perturbation within the tolerance.")
+	}
+}
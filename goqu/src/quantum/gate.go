@@ -21,10 +21,16 @@ import (
 	"fmt"
 	"math"
 	"math/cmplx"
+	"strings"
+	"sync/atomic"
 )
 
+// Threshold for how close two probabilities or complex amplitudes have to be
+// before they're considered equal.
+const threshold = 0.0000000001
+
 func closeEnough(a complex128, b complex128) bool {
-	return math.Abs(cmplx.Abs(a)-cmplx.Abs(b)) < threshold
+	return cmplx.Abs(a-b) < threshold
 }
 
 // A quantum gate.
@@ -35,6 +41,30 @@ type Gate struct {
 	// The elements of the matrix representation of the gate in the standard
         // basis.
 	get   func(row, col int) complex128
+
+	// The conventional name of the gate (e.g., "h" or "cx"), or the empty
+	// string if it has none.
+	name string
+
+	// For a controlled gate, the gate which is applied to the targets
+	// following the controls. This is nil for an ordinary gate.
+	base *Gate
+
+	// For a controlled gate, the value (0 or 1) which each control qubit
+	// must have for base to be applied.
+	controls []int
+
+	// For a classical gate, the basis state to which each basis state is
+	// sent. This is nil for a non-classical gate.
+	permutation []int
+
+	// The arguments of the gate's constructor, e.g. the angle of a
+	// rotation, or nil if it has none.
+	params []float64
+
+	// A specialised kernel for applying the gate, or nil if the kernel
+	// should be chosen according to the gate's width.
+	kernel kernel
 }
 
 // Get an element of the Hermitian conjugate (dagger) of the gate's matrix.
@@ -47,170 +77,364 @@ func (gate *Gate) Width() int {
 	return gate.width
 }
 
+// Accessor for the name of a Gate.
+func (gate *Gate) Name() string {
+	return gate.name
+}
+
+// Accessor for an element of the matrix representation of a Gate in the
+// standard basis.
+func (gate *Gate) Get(row, col int) complex128 {
+	return gate.get(row, col)
+}
+
+// Accessor for the parameters of a Gate, e.g. the angle theta of
+// RotationX(theta). This is nil for a gate without parameters.
+func (gate *Gate) Params() []float64 {
+	return append([]float64(nil), gate.params...)
+}
+
+// For a controlled gate, accessor for the gate which is applied to the
+// targets following the controls. This is nil for an ordinary gate.
+func (gate *Gate) Base() *Gate {
+	return gate.base
+}
+
+// For a controlled gate, accessor for the value (0 or 1) which each control
+// must have for the base gate to be applied.
+func (gate *Gate) ControlValues() []int {
+	return append([]int(nil), gate.controls...)
+}
+
 // The dimension of the Hilbert space over which this gate acts.
 func (gate *Gate) dim() int {
 	// This is equal to math.Pow(2, width).
 	return 1<<uint(gate.width)
 }
 
-// Compute the value of one element of U^{dag} U, and return true if differs
-// from the corresponding element in the identity matrix.
-// TODO(davinci): Move this to the test file.
-func (gate *Gate) computeSquareElement(row, col int, c chan bool) {
-	sum := complex(0, 0)
-	for i := 0; i < gate.dim(); i++ {
-		sum += gate.getDagger(row, i) * gate.get(i, col)
-	}
-	if row == col {
-                // Check that the diagonal elements sum to 1.
-		if closeEnough(sum, complex(1, 0)) {
-			c <- false
-			return
-		}
-	} else if closeEnough(sum, complex(0, 0)) {
-                // Check that the off-diagonal elements sum to 0.
-		c <- false
-		return
+// The matrix of a gate in row-major order.
+func gateMatrix(gate *Gate) []complex128 {
+	dim := gate.dim()
+	matrix := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			matrix[row*dim+col] = gate.get(row, col)
+		}
 	}
-	c <- true
+	return matrix
+}
+
+// A gate with the given matrix in row-major order, which is assumed to be
+// unitary.
+func newMatrixGate(matrix []complex128, width int) *Gate {
+	dim := 1 << uint(width)
+	return NewFuncGateNoCheck(func(row int, col int) complex128 {
+		return matrix[row*dim+col]
+	},
+		width)
 }
 
 // This tells us whether or not a gate is unitary (it should always be).
 // TODO(davinci): Move this to the test file.
 func (gate *Gate) IsUnitary() bool {
-	c := make(chan bool)
-	for row := 0; row < gate.dim(); row++ {
-		for col := 0; col < gate.dim(); col++ {
-			go gate.computeSquareElement(row, col, c)
+	dim := gate.dim()
+	var failed int32
+	// Each row of U^{dag} U takes dim*dim steps to compute.
+	parallelForGrain(dim, minChunkSize/(dim*dim), MaxWorkers(), func(start, end int) {
+		for row := start; row < end; row++ {
+			if atomic.LoadInt32(&failed) != 0 {
+				return
+			}
+			if !gate.isIdentityRow(row) {
+				atomic.StoreInt32(&failed, 1)
+			}
 		}
-	}
-	for i := 0; i < gate.dim()*gate.dim(); i++ {
-		if <-c {
-			// U^{dag} U is not the identity matrix, so U is not
-			// unitary.
+	})
+	// U passes the test if U^{dag} U is the identity matrix.
+	return failed == 0
+}
+
+// Compute one row of U^{dag} U, and return true if it matches the
+// corresponding row of the identity matrix.
+func (gate *Gate) isIdentityRow(row int) bool {
+	for col := 0; col < gate.dim(); col++ {
+		sum := complex(0, 0)
+		for i := 0; i < gate.dim(); i++ {
+			sum += gate.getDagger(row, i) * gate.get(i, col)
+		}
+		if row == col {
+			// Check that the diagonal elements sum to 1.
+			if !closeEnough(sum, complex(1, 0)) {
+				return false
+			}
+		} else if !closeEnough(sum, complex(0, 0)) {
+			// Check that the off-diagonal elements sum to 0.
 			return false
 		}
 	}
-	// U passes the test, so it is unitary.
 	return true
 }
 
-
-
 func NewFuncGateNoCheck(f func(row int, col int) complex128, width int) *Gate {
-	return &Gate{width, f}
+	return &Gate{width: width, get: f}
 }
 
 func NewFuncGate(f func(row int, col int) complex128, width int) *Gate {
+	gate, err := TryNewFuncGate(f, width)
+	if err != nil {
+		panic(err)
+	}
+	return gate
+}
+
+// Like NewFuncGate, but returns ErrNotUnitary instead of panicking.
+func TryNewFuncGate(f func(row int, col int) complex128, width int) (*Gate, error) {
 	gate := NewFuncGateNoCheck(f, width)
 	if !gate.IsUnitary() {
-		panic("Gate is not unitary")
+		return nil, ErrNotUnitary
 	}
-	return gate
+	return gate, nil
 }
 
 func NewArrayGate(arr []complex128) *Gate {
+	gate, err := TryNewArrayGate(arr)
+	if err != nil {
+		panic(err)
+	}
+	return gate
+}
+
+// Like NewArrayGate, but returns ErrWidthMismatch if the array does not hold
+// a 2^n by 2^n matrix, or ErrNotUnitary, instead of panicking.
+func TryNewArrayGate(arr []complex128) (*Gate, error) {
 	dim := int(math.Sqrt(float64(len(arr))))
-	return NewFuncGate(
+	width := int(math.Log2(float64(dim)))
+	if dim == 0 || dim*dim != len(arr) || 1<<uint(width) != dim {
+		return nil, fmt.Errorf("%w: %d elements do not form a "+
+			"2^n by 2^n matrix", ErrWidthMismatch, len(arr))
+	}
+	return TryNewFuncGate(
 		// get(row, col)
 		func(row int, col int) complex128 {
 			return arr[row*dim+col]
 		},
-		// width
-		int(math.Log2(float64(dim))))
+		width)
 }
 
 func NewRealArrayGate(arr []float64) *Gate {
+	gate, err := TryNewRealArrayGate(arr)
+	if err != nil {
+		panic(err)
+	}
+	return gate
+}
+
+// Like NewRealArrayGate, but returns an error instead of panicking.
+func TryNewRealArrayGate(arr []float64) (*Gate, error) {
 	newArr := make([]complex128, len(arr))
 	for i, a := range arr {
 		newArr[i] = complex(a, 0)
 	}
-	return NewArrayGate(newArr)
+	return TryNewArrayGate(newArr)
 }
 
 func NewClassicalGate(f func(x int) int, width int) *Gate {
-	return NewFuncGate(func(row int, col int) complex128 {
-		if f(col) == row {
+	gate, err := TryNewClassicalGate(f, width)
+	if err != nil {
+		panic(err)
+	}
+	return gate
+}
+
+// Like NewClassicalGate, but returns ErrNotUnitary if f is not a permutation
+// instead of panicking.
+func TryNewClassicalGate(f func(x int) int, width int) (*Gate, error) {
+	// The matrix is unitary exactly when f is a permutation, which is much
+	// cheaper to check than multiplying out the matrix.
+	dim := 1 << uint(width)
+	permutation := make([]int, dim)
+	seen := make([]bool, dim)
+	for x := range permutation {
+		y := f(x)
+		if y < 0 || y >= dim || seen[y] {
+			return nil, fmt.Errorf("%w: classical function is not a "+
+				"permutation of %d bits", ErrNotUnitary, width)
+		}
+		seen[y] = true
+		permutation[x] = y
+	}
+	gate := NewFuncGateNoCheck(func(row int, col int) complex128 {
+		if permutation[col] == row {
 			return complex(1, 0)
 		}
 		return complex(0, 0)
 	},
 		width)
+	// Since f is a permutation, the gate can be applied by moving
+	// amplitudes rather than by matrix multiplication.
+	gate.permutation = permutation
+	return gate, nil
 }
 
-func stateIndexForTarget(application int, targetValue int, width int, targets []int) int {
-	// It seems terribly inefficient to have to compute this for every
-	// value of targetValue.
-	// TODO(davinci): Fix this.
-
-	// Create a vector with width elements and initialise to an invalid value.
-	stateVector := make([]int, width)
-	for i := 0; i < width; i++ {
-		stateVector[i] = 2
+// Controlled returns a gate which applies gate to its last gate.Width()
+// targets only when each of its first numControls targets is set to 1. For
+// example, Controlled(PauliX(), 1).Apply(qreg, []int{c, t}) is a CNOT with
+// control c and target t.
+func Controlled(gate *Gate, numControls int) *Gate {
+	if numControls < 0 {
+		panic(fmt.Sprintf("%d is not a valid number of controls",
+			numControls))
 	}
-	// For each target qubit, compute its contribution to the bit
-	// vector of the final index.
-	for i := 0; i < len(targets); i++ {
-		stateVector[targets[i]] = (targetValue >> uint(i)) & 1
+	controlValues := make([]int, numControls)
+	for i := range controlValues {
+		controlValues[i] = 1
 	}
-	// Ditto for each non-target qubit.
-	appPos := 0
-	for i := 0; i < width; i++ {
-		if stateVector[i] == 2 {
-			stateVector[i] = (application >> uint(appPos)) & 1
-			appPos++
+	return ControlledOn(gate, controlValues...)
+}
+
+// ControlledOn is like Controlled, but each control is given the value which
+// it must have for gate to be applied: 1 for an ordinary control, or 0 for a
+// negative (open) control.
+func ControlledOn(gate *Gate, controlValues ...int) *Gate {
+	numControls := len(controlValues)
+	controlMask := 1<<uint(numControls) - 1
+	controlValue := 0
+	for i, value := range controlValues {
+		if value < 0 || value > 1 {
+			panic(fmt.Sprintf("Control value %d should be either "+
+				"0 or 1.", value))
 		}
+		controlValue |= value << uint(i)
 	}
-	// Sum them to form the final index.
-	index := 0
-	for i := 0; i < width; i++ {
-		index += stateVector[i] << uint(i)
+	controls := make([]int, numControls)
+	copy(controls, controlValues)
+
+	// A gate controlled only on 1s is named as in OpenQASM, e.g. "cx".
+	name := ""
+	if gate.name != "" && controlValue == controlMask {
+		name = strings.Repeat("c", numControls) + gate.name
 	}
-	return index
-}
 
-type indexAmplitude struct {
-	index     int
-	amplitude complex128
+	// The controls occupy the low bits of the row and column indices, so
+	// the matrix is block diagonal with gate in the block where all the
+	// controls have their required value, and the identity elsewhere.
+	get := func(row, col int) complex128 {
+		if row&controlMask == controlValue && col&controlMask == controlValue {
+			return gate.get(row>>uint(numControls), col>>uint(numControls))
+		}
+		if row == col {
+			return complex(1, 0)
+		}
+		return complex(0, 0)
+	}
+	return &Gate{
+		width:    numControls + gate.width,
+		get:      get,
+		name:     name,
+		base:     gate,
+		controls: controls,
+		params:   gate.params}
 }
 
-// Compute one row of matrix multiplication
-func (gate *Gate) computeRow(qreg *QReg, app int, row int, targets []int, c chan indexAmplitude) {
-	sum := complex128(complex(0, 0))
-	for col := 0; col < gate.dim(); col++ {
-		index := stateIndexForTarget(app, col, qreg.width, targets)
-		sum += gate.get(row, col) * qreg.amplitudes[index]
+// Adjoint returns the Hermitian conjugate of a gate, which undoes it. Where
+// the adjoint has a conventional name it is built with the corresponding
+// constructor, e.g. the adjoint of S() is SDagger() and the adjoint of
+// RotationX(theta) is RotationX(-theta).
+func Adjoint(gate *Gate) *Gate {
+	if adjoint := namedAdjoint(gate); adjoint != nil {
+		return adjoint
 	}
-	index := stateIndexForTarget(app, row, qreg.width, targets)
-	c <- indexAmplitude{index, sum}
+	if gate.base != nil {
+		return ControlledOn(Adjoint(gate.base), gate.controls...)
+	}
+	adjoint := NewFuncGateNoCheck(gate.getDagger, gate.width)
+	if gate.permutation != nil {
+		adjoint.permutation = make([]int, len(gate.permutation))
+		for x, y := range gate.permutation {
+			adjoint.permutation[y] = x
+		}
+	}
+	return adjoint
 }
 
 // Apply an arbitrary matrix to a quantum register.
 // len(matrix) == 4 ** len(targets)
+// Bit i of the matrix's row and column indices corresponds to the qubit
+// targets[i], where qubits are indexed according to the register's bit order.
 func (gate *Gate) Apply(qreg *QReg, targets []int) {
-	// Verify that all the targets are valid.
-	for _, target := range targets {
-		if target >= qreg.width {
-			panic(fmt.Sprintf("%d is not a valid target", target))
-		}
+	if err := gate.TryApply(qreg, targets); err != nil {
+		panic(err)
 	}
+}
 
-	numApps := 1 << uint(qreg.width-len(targets))
-	newAmplitudes := make([]complex128, len(qreg.amplitudes))
-	// Each application of the matrix
-	// app is the binary representation of the non-target states
-	for app := 0; app < numApps; app++ {
-		// Each row of the matrix
-		c := make(chan indexAmplitude)
-		for row := 0; row < gate.dim(); row++ {
-			go gate.computeRow(qreg, app, row, targets, c)
+// Like Apply, but returns ErrWidthMismatch, ErrInvalidTarget or
+// ErrDuplicateTarget instead of panicking. The register is left unchanged if
+// an error is returned.
+func (gate *Gate) TryApply(qreg *QReg, targets []int) error {
+	if err := gate.checkTargets(targets, qreg.width); err != nil {
+		return err
+	}
+	gate.applyMasked(qreg.amplitudes, qreg.targetBits(targets), 0, 0,
+		qreg.workers())
+	qreg.record(Operation{Kind: GateOp, Gate: gate, Targets: targets})
+	if qreg.noise != nil {
+		qreg.noise.applyGateErrors(qreg, gate, targets)
+	}
+	return nil
+}
+
+// Verify that the targets are valid for applying the gate to a register of
+// the given width: there must be one target per qubit of the gate, and each
+// must be a distinct qubit of the register.
+func (gate *Gate) checkTargets(targets []int, width int) error {
+	if len(targets) != gate.width {
+		return fmt.Errorf("%w: gate of width %d given %d targets",
+			ErrWidthMismatch, gate.width, len(targets))
+	}
+	return checkQubits(targets, width)
+}
+
+// Verify that each of a list of qubits is a distinct qubit of a register of
+// the given width.
+func checkQubits(targets []int, width int) error {
+	for i, target := range targets {
+		if target < 0 || target >= width {
+			return fmt.Errorf("%w: %d is not a qubit of a register "+
+				"of width %d", ErrInvalidTarget, target, width)
 		}
-		for row := 0; row < gate.dim(); row++ {
-			ia := <-c
-			newAmplitudes[ia.index] = ia.amplitude
+		for _, previous := range targets[:i] {
+			if target == previous {
+				return fmt.Errorf("%w: %d", ErrDuplicateTarget,
+					target)
+			}
 		}
 	}
-	qreg.amplitudes = newAmplitudes
+	return nil
+}
+
+// Apply the gate only to those groups of amplitudes whose basis state labels
+// match value on the bits in mask, using at most the given number of workers.
+// Here the targets are bit positions within the labels rather than qubit
+// indices, and the bits in mask are never targets.
+func (gate *Gate) applyMasked(amplitudes []complex128, targets []int, mask, value, workers int) {
+	if gate.base != nil {
+		// Fold the controls into the mask and apply the base gate to
+		// the remaining targets, so that amplitudes where the controls
+		// do not have their required values are never touched.
+		numControls := len(gate.controls)
+		for i, control := range gate.controls {
+			bit := 1 << uint(targets[i])
+			mask |= bit
+			if control == 1 {
+				value |= bit
+			}
+		}
+		gate.base.applyMasked(amplitudes, targets[numControls:], mask,
+			value, workers)
+		return
+	}
+
+	gate.newKernel()(amplitudes, targets, mask, value, workers)
 }
 
 func (gate *Gate) ApplyRange(qreg *QReg, targetRangeStart int) {
@@ -22,35 +22,43 @@ import (
 	"math/cmplx"
 )
 
-// Define gates for single-qubit operations.
-func newOneQubitGate(arr [4]complex128) *Gate {
+// Define gates for single-qubit operations. The params are the arguments of
+// the gate's constructor, if it has any.
+func newOneQubitGate(name string, arr [4]complex128, params ...float64) *Gate {
 	var matrix [2][]complex128
 	matrix[0] = arr[0:2]
 	matrix[1] = arr[2:4]
 	get := func(row, col int) complex128 {
 		return matrix[row][col]
 	}
-	return &Gate{1, get}
+	return &Gate{width: 1, get: get, name: name, params: params}
+}
+
+// The identity gate on a single qubit.
+func Identity() *Gate {
+	return newOneQubitGate("id", [4]complex128{
+		1, 0,
+		0, 1})
 }
 
 // Define the gates coresponding to the Pauli matrices.
 // The Pauli X gate or NOT gate.
 func PauliX() *Gate {
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("x", [4]complex128{
 		0, 1,
 		1, 0})
 }
 
 // The Pauli Y gate.
 func PauliY() *Gate {
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("y", [4]complex128{
 		0, complex(0, -1),
 		complex(0, 1), 0})
 }
 
 // The Pauli Z gate.
 func PauliZ() *Gate {
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("z", [4]complex128{
 		1,  0,
 		0, -1})
 }
@@ -62,9 +70,9 @@ func RotationX(theta float64) *Gate {
 	t := theta/2
 	cos := complex(math.Cos(t), 0)
 	nisin := complex(0, -1 * math.Sin(t))
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("rx", [4]complex128{
 		cos,   nisin,
-		nisin, cos})
+		nisin, cos}, theta)
 }
 
 // Rotation about the Y-axis.
@@ -74,9 +82,9 @@ func RotationY(theta float64) *Gate {
 	cos := complex(math.Cos(t), 0)
 	nsin := complex(-1 * math.Sin(t), 0)
 	sin := complex(math.Sin(t), 0)
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("ry", [4]complex128{
 		cos, nsin,
-		sin, cos})
+		sin, cos}, theta)
 }
 
 // Rotation about the Z-axis.
@@ -85,9 +93,263 @@ func RotationZ(theta float64) *Gate {
 	t := theta/2
 	nexp := cmplx.Exp(complex(0, -1 * t))
 	exp := cmplx.Exp(complex(0, t))
-	return newOneQubitGate([4]complex128{
+	return newOneQubitGate("rz", [4]complex128{
 		nexp, 0,
-		0,    exp})
+		0,    exp}, theta)
+}
+
+// Define the phase gates.
+// The phase gate P(lambda) = diag(1, e^{i lambda}).
+func Phase(lambda float64) *Gate {
+	return newOneQubitGate("p", [4]complex128{
+		1, 0,
+		0, cmplx.Exp(complex(0, lambda))}, lambda)
+}
+
+// The S gate, the square root of Pauli Z.
+func S() *Gate {
+	return newOneQubitGate("s", [4]complex128{
+		1, 0,
+		0, complex(0, 1)})
+}
+
+// The Hermitian conjugate of the S gate.
+func SDagger() *Gate {
+	return newOneQubitGate("sdg", [4]complex128{
+		1, 0,
+		0, complex(0, -1)})
+}
+
+// The T gate, the square root of the S gate.
+func T() *Gate {
+	return newOneQubitGate("t", [4]complex128{
+		1, 0,
+		0, cmplx.Exp(complex(0, math.Pi/4))})
+}
+
+// The Hermitian conjugate of the T gate.
+func TDagger() *Gate {
+	return newOneQubitGate("tdg", [4]complex128{
+		1, 0,
+		0, cmplx.Exp(complex(0, -math.Pi/4))})
+}
+
+// The SX gate, the square root of Pauli X.
+func SqrtX() *Gate {
+	p := complex(0.5, 0.5)
+	n := complex(0.5, -0.5)
+	return newOneQubitGate("sx", [4]complex128{
+		p, n,
+		n, p})
+}
+
+// The adjoint of the square root of Pauli X.
+func SqrtXDagger() *Gate {
+	p := complex(0.5, -0.5)
+	n := complex(0.5, 0.5)
+	return newOneQubitGate("sxdg", [4]complex128{
+		p, n,
+		n, p})
+}
+
+// The general single-qubit gate U3(theta, phi, lambda), which is
+// R_z(phi) R_y(theta) R_z(lambda) up to a global phase.
+func U3(theta, phi, lambda float64) *Gate {
+	t := theta / 2
+	cos := complex(math.Cos(t), 0)
+	sin := complex(math.Sin(t), 0)
+	return newOneQubitGate("u3", [4]complex128{
+		cos, -cmplx.Exp(complex(0, lambda)) * sin,
+		cmplx.Exp(complex(0, phi)) * sin, cmplx.Exp(complex(0, phi+lambda)) * cos}, theta, phi, lambda)
+}
+
+// Define gates for two-qubit operations. Bit i of a row or column index
+// corresponds to targets[i] when the gate is applied.
+func newTwoQubitGate(name string, arr [16]complex128, params ...float64) *Gate {
+	get := func(row, col int) complex128 {
+		return arr[row*4+col]
+	}
+	return &Gate{width: 2, get: get, name: name, params: params}
+}
+
+// The SWAP gate, which exchanges two qubits.
+func Swap() *Gate {
+	return newTwoQubitGate("swap", [16]complex128{
+		1, 0, 0, 0,
+		0, 0, 1, 0,
+		0, 1, 0, 0,
+		0, 0, 0, 1})
+}
+
+// The iSWAP gate, which exchanges two qubits and multiplies the amplitudes
+// of |01> and |10> by i.
+func ISwap() *Gate {
+	i := complex(0, 1)
+	return newTwoQubitGate("iswap", [16]complex128{
+		1, 0, 0, 0,
+		0, 0, i, 0,
+		0, i, 0, 0,
+		0, 0, 0, 1})
+}
+
+// The square root of the SWAP gate.
+func SqrtSwap() *Gate {
+	p := complex(0.5, 0.5)
+	n := complex(0.5, -0.5)
+	return newTwoQubitGate("sqrtswap", [16]complex128{
+		1, 0, 0, 0,
+		0, p, n, 0,
+		0, n, p, 0,
+		0, 0, 0, 1})
+}
+
+// The Ising coupling gate XX(theta) = exp(-i theta/2 X(x)X).
+func IsingXX(theta float64) *Gate {
+	t := theta / 2
+	c := complex(math.Cos(t), 0)
+	s := complex(0, -1*math.Sin(t))
+	return newTwoQubitGate("rxx", [16]complex128{
+		c, 0, 0, s,
+		0, c, s, 0,
+		0, s, c, 0,
+		s, 0, 0, c}, theta)
+}
+
+// The Ising coupling gate YY(theta) = exp(-i theta/2 Y(x)Y).
+func IsingYY(theta float64) *Gate {
+	t := theta / 2
+	c := complex(math.Cos(t), 0)
+	s := complex(0, math.Sin(t))
+	return newTwoQubitGate("ryy", [16]complex128{
+		c, 0, 0, s,
+		0, c, -s, 0,
+		0, -s, c, 0,
+		s, 0, 0, c}, theta)
+}
+
+// The Ising coupling gate ZZ(theta) = exp(-i theta/2 Z(x)Z).
+func IsingZZ(theta float64) *Gate {
+	t := theta / 2
+	nexp := cmplx.Exp(complex(0, -1*t))
+	exp := cmplx.Exp(complex(0, t))
+	return newTwoQubitGate("rzz", [16]complex128{
+		nexp, 0, 0, 0,
+		0, exp, 0, 0,
+		0, 0, exp, 0,
+		0, 0, 0, nexp}, theta)
+}
+
+// The fermionic simulation gate fSim(theta, phi), an iSWAP-like rotation by
+// theta followed by a controlled phase of -phi.
+func FSim(theta, phi float64) *Gate {
+	c := complex(math.Cos(theta), 0)
+	s := complex(0, -1*math.Sin(theta))
+	return newTwoQubitGate("fsim", [16]complex128{
+		1, 0, 0, 0,
+		0, c, s, 0,
+		0, s, c, 0,
+		0, 0, 0, cmplx.Exp(complex(0, -1*phi))}, theta, phi)
+}
+
+// The global phase gate, which acts on no qubits and multiplies the whole
+// state by e^{i theta}. It only has an observable effect when controlled, as
+// Controlled(GlobalPhase(theta), 1) is Phase(theta).
+func GlobalPhase(theta float64) *Gate {
+	phase := cmplx.Exp(complex(0, theta))
+	return &Gate{
+		width: 0,
+		get: func(row, col int) complex128 {
+			return phase
+		},
+		name:   "gphase",
+		params: []float64{theta}}
+}
+
+// Return the adjoint of a named gate as another named gate, or nil if it has
+// no conventional name.
+func namedAdjoint(gate *Gate) *Gate {
+	p := gate.params
+	switch gate.name {
+	case "id", "x", "y", "z", "h", "swap":
+		return gate
+	case "s":
+		return SDagger()
+	case "sdg":
+		return S()
+	case "t":
+		return TDagger()
+	case "tdg":
+		return T()
+	case "sx":
+		return SqrtXDagger()
+	case "sxdg":
+		return SqrtX()
+	case "rx":
+		return RotationX(-p[0])
+	case "ry":
+		return RotationY(-p[0])
+	case "rz":
+		return RotationZ(-p[0])
+	case "p":
+		return Phase(-p[0])
+	case "u3":
+		return U3(-p[0], -p[2], -p[1])
+	case "rxx":
+		return IsingXX(-p[0])
+	case "ryy":
+		return IsingYY(-p[0])
+	case "rzz":
+		return IsingZZ(-p[0])
+	case "fsim":
+		return FSim(-p[0], -p[1])
+	case "cu":
+		return CU(-p[0], -p[2], -p[1], -p[3])
+	case "gphase":
+		return GlobalPhase(-p[0])
+	case "qft":
+		return NewInverseQFTGate(gate.width)
+	case "iqft":
+		return NewQFTGate(gate.width)
+	}
+	return nil
+}
+
+// Define the common controlled gates. The controls come first in the list of
+// targets, e.g. CX().Apply(qreg, []int{control, target}).
+// The controlled-NOT gate.
+func CX() *Gate {
+	return Controlled(PauliX(), 1)
+}
+
+// The controlled-Z gate.
+func CZ() *Gate {
+	return Controlled(PauliZ(), 1)
+}
+
+// The Toffoli (controlled-controlled-NOT) gate.
+func CCX() *Gate {
+	return Controlled(PauliX(), 2)
+}
+
+// The Fredkin (controlled-SWAP) gate.
+func CSwap() *Gate {
+	return Controlled(Swap(), 1)
+}
+
+// The controlled-U gate of OpenQASM 3, which applies U3(theta, phi, lambda)
+// with an extra phase of gamma, observable only because it is controlled.
+func CU(theta, phi, lambda, gamma float64) *Gate {
+	cos := complex(math.Cos(theta/2), 0)
+	sin := complex(math.Sin(theta/2), 0)
+	phase := func(angle float64) complex128 {
+		return cmplx.Exp(complex(0, angle+gamma))
+	}
+	gate := Controlled(newOneQubitGate("", [4]complex128{
+		phase(0) * cos, -phase(lambda) * sin,
+		phase(phi) * sin, phase(phi+lambda) * cos}), 1)
+	gate.name = "cu"
+	gate.params = []float64{theta, phi, lambda, gamma}
+	return gate
 }
 
 // Hadamard Gate
@@ -99,7 +361,7 @@ func NewHadamardGate(width int) *Gate {
 	}
 	p := complex(1.0/d, 0)
 	n := -p
-	return NewFuncGateNoCheck(
+	gate := NewFuncGateNoCheck(
 		// get(row, col)
 		func(row int, col int) complex128 {
 			// Calculate (-1)**<i,j> / sqrt(2**n)
@@ -113,6 +375,14 @@ func NewHadamardGate(width int) *Gate {
 			return p
 		},
 		width)
+	gate.name = "h"
+	// The gate is the tensor product of single-qubit Hadamard gates, which
+	// is much cheaper to apply than its matrix.
+	r := complex(1/math.Sqrt2, 0)
+	gate.kernel = newTensorPowerKernel(newOneQubitGate("h", [4]complex128{
+		r, r,
+		r, -r}))
+	return gate
 }
 
 func Hadamard(qreg *QReg, target int) {
@@ -156,3 +426,50 @@ func DiffusionRange(qreg *QReg, targetRangeStart int, targetRangeEnd int) {
 func DiffusionReg(qreg *QReg) {
 	DiffusionRange(qreg, 0, qreg.width)
 }
+
+// Quantum Fourier Transform Gates
+
+// The quantum Fourier transform maps |x> to the sum over y of
+// e^(2 pi i x y / 2^width) |y> / sqrt(2^width), where targets[0] holds the
+// least significant bit of x and y. See NewQFTCircuit for its decomposition.
+func NewQFTGate(width int) *Gate {
+	return newFourierGate("qft", width, 1)
+}
+
+// The inverse of the quantum Fourier transform, which has the opposite sign
+// in its exponent.
+func NewInverseQFTGate(width int) *Gate {
+	return newFourierGate("iqft", width, -1)
+}
+
+func newFourierGate(name string, width int, sign float64) *Gate {
+	dim := 1 << uint(width)
+	norm := complex(1/math.Sqrt(float64(dim)), 0)
+	gate := NewFuncGateNoCheck(
+		// get(row, col)
+		func(row int, col int) complex128 {
+			// Only row*col mod dim matters, which keeps the angle
+			// accurate.
+			angle := sign * 2 * math.Pi * float64(row*col%dim) /
+				float64(dim)
+			return cmplx.Rect(1, angle) * norm
+		},
+		width)
+	gate.name = name
+	// The matrix is a discrete Fourier transform, so each group of
+	// amplitudes can be transformed in place with an FFT.
+	gate.kernel = newFourierKernel(width, sign)
+	return gate
+}
+
+func QFTRange(qreg *QReg, targetRangeStart int, targetRangeEnd int) {
+	targetRangeSize := targetRangeEnd - targetRangeStart
+	gate := NewQFTGate(targetRangeSize)
+	gate.ApplyRange(qreg, targetRangeStart)
+}
+
+func InverseQFTRange(qreg *QReg, targetRangeStart int, targetRangeEnd int) {
+	targetRangeSize := targetRangeEnd - targetRangeStart
+	gate := NewInverseQFTGate(targetRangeSize)
+	gate.ApplyRange(qreg, targetRangeStart)
+}
@@ -20,13 +20,15 @@ package quantum
 import (
 	"fmt"
 	"math"
+	"math/cmplx"
 	"testing"
 )
 
 // For debugging.
 var _ = fmt.Println
 
-// Check each element of the gate against the expected value.
+// Check each element of the gate against the expected value, including its
+// phase.
 func verifyGate(expected, actual *Gate) bool {
 	if actual.Width() != expected.Width() {
 		return false
@@ -46,21 +48,23 @@ func TestPauliGates(t *testing.T) {
 }
 
 func TestOneQubitRotationGates(t *testing.T) {
-	x := PauliX()
-	y := PauliY()
-	z := PauliZ()
+	// A rotation by pi about an axis is the corresponding Pauli gate, up to
+	// a global phase of -i.
+	x := NewArrayGate([]complex128{0, -1i, -1i, 0})
+	y := NewArrayGate([]complex128{0, -1, 1, 0})
+	z := NewArrayGate([]complex128{-1i, 0, 0, 1i})
 	rx := RotationX(math.Pi)
 	ry := RotationY(math.Pi)
 	rz := RotationZ(math.Pi)
 
 	if !verifyGate(x, rx) {
-		t.Error("Expected Pauli X.")
+		t.Error("Expected -i times Pauli X.")
 	}
 	if !verifyGate(y, ry) {
-		t.Error("Expected Pauli Y.")
+		t.Error("Expected -i times Pauli Y.")
 	}
 	if !verifyGate(z, rz) {
-		t.Error("Expected Pauli Z.")
+		t.Error("Expected -i times Pauli Z.")
 	}
 
 	// TODO(davinci): Add more tests.
@@ -161,3 +165,231 @@ func TestDiffusionGate_4x4(t *testing.T) {
 		}
 	}
 }
+
+// Test the named gates against reference matrices. Bit i of a row or column
+// index corresponds to targets[i] when the gate is applied.
+func TestNamedGates(t *testing.T) {
+	i := complex(0, 1)
+	r := complex(1/math.Sqrt2, 0)
+	p := complex(0.5, 0.5)
+	n := complex(0.5, -0.5)
+	tests := []struct {
+		name     string
+		gate     *Gate
+		expected []complex128
+	}{
+		{"s", S(), []complex128{
+			1, 0,
+			0, i}},
+		{"sdg", SDagger(), []complex128{
+			1, 0,
+			0, -i}},
+		{"t", T(), []complex128{
+			1, 0,
+			0, r + r*i}},
+		{"tdg", TDagger(), []complex128{
+			1, 0,
+			0, r - r*i}},
+		{"sx", SqrtX(), []complex128{
+			p, n,
+			n, p}},
+		{"sxdg", SqrtXDagger(), []complex128{
+			n, p,
+			p, n}},
+		{"p", Phase(math.Pi / 2), []complex128{
+			1, 0,
+			0, i}},
+		{"u3", U3(math.Pi/2, 0, math.Pi), []complex128{
+			r, r,
+			r, -r}},
+		{"u3", U3(math.Pi, math.Pi/2, 0), []complex128{
+			0, -1,
+			i, 0}},
+		{"swap", Swap(), []complex128{
+			1, 0, 0, 0,
+			0, 0, 1, 0,
+			0, 1, 0, 0,
+			0, 0, 0, 1}},
+		{"iswap", ISwap(), []complex128{
+			1, 0, 0, 0,
+			0, 0, i, 0,
+			0, i, 0, 0,
+			0, 0, 0, 1}},
+		{"sqrtswap", SqrtSwap(), []complex128{
+			1, 0, 0, 0,
+			0, p, n, 0,
+			0, n, p, 0,
+			0, 0, 0, 1}},
+		{"cx", CX(), []complex128{
+			1, 0, 0, 0,
+			0, 0, 0, 1,
+			0, 0, 1, 0,
+			0, 1, 0, 0}},
+		{"cz", CZ(), []complex128{
+			1, 0, 0, 0,
+			0, 1, 0, 0,
+			0, 0, 1, 0,
+			0, 0, 0, -1}},
+		{"ccx", CCX(), []complex128{
+			1, 0, 0, 0, 0, 0, 0, 0,
+			0, 1, 0, 0, 0, 0, 0, 0,
+			0, 0, 1, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 1,
+			0, 0, 0, 0, 1, 0, 0, 0,
+			0, 0, 0, 0, 0, 1, 0, 0,
+			0, 0, 0, 0, 0, 0, 1, 0,
+			0, 0, 0, 1, 0, 0, 0, 0}},
+		{"cswap", CSwap(), []complex128{
+			1, 0, 0, 0, 0, 0, 0, 0,
+			0, 1, 0, 0, 0, 0, 0, 0,
+			0, 0, 1, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 1, 0, 0,
+			0, 0, 0, 0, 1, 0, 0, 0,
+			0, 0, 0, 1, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 1, 0,
+			0, 0, 0, 0, 0, 0, 0, 1}},
+		{"rxx", IsingXX(math.Pi / 2), []complex128{
+			r, 0, 0, -r * i,
+			0, r, -r * i, 0,
+			0, -r * i, r, 0,
+			-r * i, 0, 0, r}},
+		{"ryy", IsingYY(math.Pi / 2), []complex128{
+			r, 0, 0, r * i,
+			0, r, -r * i, 0,
+			0, -r * i, r, 0,
+			r * i, 0, 0, r}},
+		{"rzz", IsingZZ(math.Pi / 2), []complex128{
+			r - r*i, 0, 0, 0,
+			0, r + r*i, 0, 0,
+			0, 0, r + r*i, 0,
+			0, 0, 0, r - r*i}},
+		{"fsim", FSim(math.Pi/2, math.Pi), []complex128{
+			1, 0, 0, 0,
+			0, 0, -i, 0,
+			0, -i, 0, 0,
+			0, 0, 0, -1}},
+	}
+	for _, test := range tests {
+		if test.gate.Name() != test.name {
+			t.Errorf("Bad name %q, expected %q.", test.gate.Name(),
+				test.name)
+		}
+		if !verifyGate(NewArrayGate(test.expected), test.gate) {
+			t.Errorf("Bad matrix for %s gate.", test.name)
+		}
+		if !test.gate.IsUnitary() {
+			t.Errorf("Expected %s gate to be unitary.", test.name)
+		}
+	}
+}
+
+// The named gates should be related to one another in the expected ways.
+func TestNamedGateIdentities(t *testing.T) {
+	if !verifyGate(S(), Phase(math.Pi/2)) {
+		t.Error("Expected S = P(pi/2).")
+	}
+	if !verifyGate(T(), Phase(math.Pi/4)) {
+		t.Error("Expected T = P(pi/4).")
+	}
+	if !verifyGate(NewHadamardGate(1), U3(math.Pi/2, 0, math.Pi)) {
+		t.Error("Expected H = U3(pi/2, 0, pi).")
+	}
+	if !verifyGate(ISwap(), FSim(-math.Pi/2, 0)) {
+		t.Error("Expected iSWAP = fSim(-pi/2, 0).")
+	}
+}
+
+func TestGlobalPhase(t *testing.T) {
+	gate := GlobalPhase(math.Pi / 3)
+	if gate.Width() != 0 {
+		t.Errorf("Bad width for global phase = %d, expected 0.",
+			gate.Width())
+	}
+	qreg := NewQReg(2, 1)
+	gate.Apply(qreg, []int{})
+	expected := cmplx.Exp(complex(0, math.Pi/3))
+	if cmplx.Abs(qreg.amplitudes[1]-expected) >= threshold {
+		t.Errorf("Bad amplitude %f, expected %f.", qreg.amplitudes[1],
+			expected)
+	}
+	if !verifyGate(Phase(math.Pi/3), Controlled(gate, 1)) {
+		t.Error("Expected a controlled global phase to be P(pi/3).")
+	}
+}
+
+func TestQFTGate(t *testing.T) {
+	// The QFT of width 1 is the Hadamard gate.
+	if !verifyGate(NewHadamardGate(1), NewQFTGate(1)) {
+		t.Error("Expected the QFT of width 1 to be a Hadamard gate.")
+	}
+	// The QFT of width 2 is the discrete Fourier transform with w = i.
+	expected := NewArrayGate([]complex128{
+		0.5, 0.5, 0.5, 0.5,
+		0.5, 0.5i, -0.5, -0.5i,
+		0.5, -0.5, 0.5, -0.5,
+		0.5, -0.5i, -0.5, 0.5i})
+	if !verifyGate(expected, NewQFTGate(2)) {
+		t.Error("Bad matrix for the QFT of width 2.")
+	}
+	for width := 1; width <= 4; width++ {
+		qft := NewQFTGate(width)
+		if !qft.IsUnitary() {
+			t.Errorf("QFT of width %d is not unitary.", width)
+		}
+		if !verifyGate(Adjoint(NewFuncGateNoCheck(qft.get, width)),
+			NewInverseQFTGate(width)) {
+			t.Errorf("Bad inverse QFT of width %d.", width)
+		}
+		if Adjoint(qft).Name() != "iqft" {
+			t.Errorf("Expected the adjoint of %q to be named iqft.",
+				qft.Name())
+		}
+	}
+}
+
+// The QFT of a basis state is a uniform superposition whose phases increase
+// by 2 pi x / 2^width from each state to the next.
+func TestQFTRange(t *testing.T) {
+	qreg := NewQReg(5, 3<<1|1)
+	QFTRange(qreg, 1, 5)
+	for label, amplitude := range qreg.amplitudes {
+		expected := complex(0, 0)
+		if label&1 == 1 {
+			y := label >> 1
+			expected = cmplx.Rect(0.25, 2*math.Pi*float64(3*y)/16)
+		}
+		if !verifyAmplitude(expected, amplitude) {
+			t.Errorf("Bad amplitude for state %d = %+f, expected %+f.",
+				label, amplitude, expected)
+		}
+	}
+	InverseQFTRange(qreg, 1, 5)
+	if !verifyAmplitude(1, qreg.amplitudes[3<<1|1]) {
+		t.Error("Expected the inverse QFT to restore |00111>.")
+	}
+}
+
+func TestCU(t *testing.T) {
+	if !verifyGate(Controlled(U3(0.1, 0.2, 0.3), 1), CU(0.1, 0.2, 0.3, 0)) {
+		t.Error("Expected cu with no phase to be a controlled u3.")
+	}
+	// The phase gamma applies whenever the control is set, so it acts as
+	// a phase gate on the control.
+	e := cmplx.Rect(1, 0.7)
+	expected := NewArrayGate([]complex128{
+		1, 0, 0, 0,
+		0, e, 0, 0,
+		0, 0, 1, 0,
+		0, 0, 0, e})
+	if !verifyGate(expected, CU(0, 0, 0, 0.7)) {
+		t.Error("Expected cu(0, 0, 0, gamma) to be p(gamma) on the control.")
+	}
+	gate := CU(0.4, 0.5, 0.6, 0.7)
+	adjoint := Adjoint(gate)
+	if adjoint.Name() != "cu" {
+		t.Errorf("Bad name %q for the adjoint of cu.", adjoint.Name())
+	}
+	if !verifyGate(Adjoint(NewFuncGateNoCheck(gate.get, 2)), adjoint) {
+		t.Error("Bad adjoint of cu.")
+	}
+}
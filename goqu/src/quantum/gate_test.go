@@ -0,0 +1,204 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// Helper function to build a register in a state where every amplitude is
+// distinct, so that any misplaced amplitude is detected.
+func newDistinctQReg(width int) *QReg {
+	qreg := NewQReg(width)
+	norm := 0.0
+	for i := range qreg.amplitudes {
+		qreg.amplitudes[i] = complex(float64(i+1), float64(-i))
+		norm += float64((i+1)*(i+1) + i*i)
+	}
+	for i := range qreg.amplitudes {
+		qreg.amplitudes[i] /= complex(math.Sqrt(norm), 0)
+	}
+	return qreg
+}
+
+func TestControlledGate_CNOT(t *testing.T) {
+	cnot := Controlled(PauliX(), 1)
+	if cnot.Width() != 2 {
+		t.Fatalf("Bad width for CNOT = %d, expected 2.", cnot.Width())
+	}
+	// The control is targets[0], which is bit 0 of the matrix index.
+	expected := NewRealArrayGate([]float64{
+		1, 0, 0, 0,
+		0, 0, 0, 1,
+		0, 0, 1, 0,
+		0, 1, 0, 0,
+	})
+	if !verifyGate(expected, cnot) {
+		t.Error("Expected CNOT.")
+	}
+	if !cnot.IsUnitary() {
+		t.Error("Expected CNOT to be unitary.")
+	}
+
+	// Control on qubit 2, target qubit 0: |100> -> |101>.
+	qreg := NewQReg(3, 4)
+	cnot.Apply(qreg, []int{2, 0})
+	if !isBasisState(qreg, 5) {
+		t.Error("Expected |101>.")
+	}
+	// Control not set: |010> is unchanged.
+	qreg = NewQReg(3, 2)
+	cnot.Apply(qreg, []int{2, 0})
+	if !isBasisState(qreg, 2) {
+		t.Error("Expected |010>.")
+	}
+}
+
+func TestControlledGate_Toffoli(t *testing.T) {
+	toffoli := Controlled(PauliX(), 2)
+	for label := 0; label < 8; label++ {
+		qreg := NewQReg(3, label)
+		toffoli.Apply(qreg, []int{1, 2, 0})
+		expected := label
+		if label&6 == 6 {
+			expected ^= 1
+		}
+		if !isBasisState(qreg, expected) {
+			t.Errorf("Toffoli on |%d>: expected |%d>.", label, expected)
+		}
+	}
+}
+
+func TestControlledGate_OpenControls(t *testing.T) {
+	// Flip qubit 0 when qubit 1 is 0 and qubit 2 is 1.
+	gate := ControlledOn(PauliX(), 0, 1)
+	for label := 0; label < 8; label++ {
+		qreg := NewQReg(3, label)
+		gate.Apply(qreg, []int{1, 2, 0})
+		expected := label
+		if label&6 == 4 {
+			expected ^= 1
+		}
+		if !isBasisState(qreg, expected) {
+			t.Errorf("Open control on |%d>: expected |%d>.",
+				label, expected)
+		}
+	}
+}
+
+// A controlled gate must agree with its dense matrix, and leave the
+// amplitudes where the controls are not set exactly as they were.
+func TestControlledGate_MatchesDense(t *testing.T) {
+	controlled := ControlledOn(RotationY(0.3), 1, 0)
+	dense := NewFuncGate(controlled.get, controlled.Width())
+	targets := []int{3, 0, 2}
+
+	expected := newDistinctQReg(4)
+	dense.Apply(expected, targets)
+	actual := newDistinctQReg(4)
+	original := actual.Copy()
+	controlled.Apply(actual, targets)
+
+	for label := range actual.amplitudes {
+		if !verifyAmplitude(expected.amplitudes[label], actual.amplitudes[label]) {
+			t.Errorf("Bad amplitude for state %d = %+f, expected %+f.",
+				label, actual.amplitudes[label],
+				expected.amplitudes[label])
+		}
+		if label&(1<<3|1<<0) != 1<<3 &&
+			actual.amplitudes[label] != original.amplitudes[label] {
+			t.Errorf("Amplitude for state %d changed without its "+
+				"controls set.", label)
+		}
+	}
+}
+
+func TestControlledGate_Nested(t *testing.T) {
+	nested := Controlled(Controlled(PauliX(), 1), 1)
+	if !verifyGate(Controlled(PauliX(), 2), nested) {
+		t.Error("Expected Toffoli.")
+	}
+	qreg := NewQReg(3, 3)
+	nested.Apply(qreg, []int{0, 1, 2})
+	if !isBasisState(qreg, 7) {
+		t.Error("Expected |111>.")
+	}
+}
+
+func TestAdjoint(t *testing.T) {
+	tests := []struct {
+		gate *Gate
+		name string
+	}{
+		{PauliX(), "x"},
+		{NewHadamardGate(2), "h"},
+		{S(), "sdg"},
+		{TDagger(), "t"},
+		{SqrtX(), "sxdg"},
+		{RotationY(0.3), "ry"},
+		{U3(0.1, 0.2, 0.3), "u3"},
+		{IsingZZ(0.4), "rzz"},
+		{FSim(0.5, 0.6), "fsim"},
+		{ISwap(), ""},
+		{Controlled(Phase(0.7), 1), "cp"},
+		{ControlledOn(SqrtSwap(), 0, 1), ""},
+		{NewClassicalGate(func(x int) int { return (x + 1) % 8 }, 3), ""},
+	}
+	for _, test := range tests {
+		adjoint := Adjoint(test.gate)
+		if adjoint.Name() != test.name {
+			t.Errorf("Bad name %q for adjoint, expected %q.",
+				adjoint.Name(), test.name)
+		}
+		expected := NewFuncGateNoCheck(test.gate.getDagger, test.gate.Width())
+		if !verifyGate(expected, adjoint) {
+			t.Errorf("Bad matrix for adjoint of %q.", test.gate.Name())
+		}
+
+		// Applying the gate and then its adjoint should have no
+		// effect.
+		targets := []int{3, 1, 0, 2}[:test.gate.Width()]
+		qreg := newDistinctQReg(4)
+		original := qreg.Copy()
+		test.gate.Apply(qreg, targets)
+		adjoint.Apply(qreg, targets)
+		for label, amplitude := range qreg.amplitudes {
+			if cmplx.Abs(amplitude-original.amplitudes[label]) >= threshold {
+				t.Errorf("Adjoint of %q did not undo it.",
+					test.gate.Name())
+				break
+			}
+		}
+	}
+}
+
+func TestGateParams(t *testing.T) {
+	if params := U3(0.1, 0.2, 0.3).Params(); len(params) != 3 ||
+		params[0] != 0.1 || params[1] != 0.2 || params[2] != 0.3 {
+		t.Errorf("Bad params %v for U3, expected [0.1 0.2 0.3].", params)
+	}
+	if params := Controlled(RotationX(0.5), 2).Params(); len(params) != 1 ||
+		params[0] != 0.5 {
+		t.Errorf("Bad params %v for controlled RX, expected [0.5].", params)
+	}
+	if params := PauliX().Params(); params != nil {
+		t.Errorf("Bad params %v for X, expected none.", params)
+	}
+}
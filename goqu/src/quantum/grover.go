@@ -0,0 +1,135 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// A phase oracle on width qubits, which negates the amplitude of each basis
+// state x for which predicate(x) is true, where targets[0] holds the least
+// significant bit of x. The predicate is evaluated once for each state here.
+func NewPhaseOracle(width int, predicate func(x int) bool) *Gate {
+	diagonal := make([]complex128, 1<<uint(width))
+	for x := range diagonal {
+		diagonal[x] = 1
+		if predicate(x) {
+			diagonal[x] = -1
+		}
+	}
+	gate := NewFuncGateNoCheck(func(row int, col int) complex128 {
+		if row == col {
+			return diagonal[row]
+		}
+		return 0
+	},
+		width)
+	gate.kernel = newDiagonalKernel(diagonal)
+	return gate
+}
+
+// Search for a value of n bits which satisfies predicate with Grover's
+// algorithm, and return the value measured. Any number of values may satisfy
+// it, and the number of iterations is chosen to make finding one of them
+// most likely, but the value returned should still be checked.
+func GroverSearch(n int, predicate func(x int) bool) int {
+	return groverSearch(NewQReg(n), predicate)
+}
+
+// Like GroverSearch, but the measurement draws from the given source of
+// randomness, so that the value returned can be reproduced.
+func GroverSearchWithRand(source rand.Source, n int, predicate func(x int) bool) int {
+	return groverSearch(NewQRegWithRand(source, n), predicate)
+}
+
+// Search with Grover's algorithm on a register of n qubits in state |0>.
+func groverSearch(qreg *QReg, predicate func(x int) bool) int {
+	AmplitudeAmplification(qreg, NewHadamardGate(qreg.width), predicate)
+	return qreg.Measure()
+}
+
+// Amplify the amplitudes of the basis states of a register which satisfy
+// predicate, where bit i of the value passed to predicate is that of qubit i,
+// as for NewPhaseOracle. This is the basis state label in little-endian
+// order whatever the bit order of the register, so for a BigEndian register
+// the predicate sees its labels, e.g. those returned by Measure, with the bits
+// reversed. The register should be in the state |0...0>, to which prepare is
+// applied to give the initial superposition, e.g. NewHadamardGate(width) for
+// Grover's algorithm. Each iteration reflects the state about the states which
+// do not satisfy predicate and then about the initial state, rotating it
+// towards the states which do. The number of iterations is chosen to
+// maximise the probability of measuring such a state, and is returned.
+func AmplitudeAmplification(qreg *QReg, prepare *Gate, predicate func(x int) bool) int {
+	iterations, err := TryAmplitudeAmplification(qreg, prepare, predicate)
+	if err != nil {
+		panic(err)
+	}
+	return iterations
+}
+
+// Like AmplitudeAmplification, but returns ErrWidthMismatch if prepare is not
+// as wide as the register, instead of panicking.
+func TryAmplitudeAmplification(qreg *QReg, prepare *Gate, predicate func(x int) bool) (int, error) {
+	if prepare.width != qreg.width {
+		return 0, fmt.Errorf("%w: preparation of width %d given a "+
+			"register of width %d", ErrWidthMismatch,
+			prepare.width, qreg.width)
+	}
+	oracle := NewPhaseOracle(qreg.width, predicate)
+	unprepare := Adjoint(prepare)
+	// Reflecting about |0...0> negates every other basis state.
+	reflection := NewPhaseOracle(qreg.width, func(x int) bool {
+		return x != 0
+	})
+
+	// The probability of success without amplification determines the
+	// angle of each rotation, and is found from a noiseless copy of the
+	// initial state.
+	initial := NewQReg(qreg.width)
+	prepare.ApplyReg(initial)
+	successProb := 0.0
+	for label, amplitude := range initial.amplitudes {
+		if predicate(label) {
+			successProb += amplitudeProb(amplitude)
+		}
+	}
+	iterations := optimalIterations(successProb)
+
+	prepare.ApplyReg(qreg)
+	for i := 0; i < iterations; i++ {
+		oracle.ApplyReg(qreg)
+		unprepare.ApplyReg(qreg)
+		reflection.ApplyReg(qreg)
+		prepare.ApplyReg(qreg)
+	}
+	return iterations, nil
+}
+
+// The number of iterations of amplitude amplification which maximises the
+// probability of success, given its probability sin^2(theta) without
+// amplification. Each iteration rotates the state by 2 theta, so after k
+// iterations the probability is sin^2((2k + 1) theta).
+func optimalIterations(successProb float64) int {
+	if successProb <= 0 {
+		return 0
+	}
+	theta := math.Asin(math.Sqrt(math.Min(successProb, 1)))
+	return int(math.Pi / (4 * theta))
+}
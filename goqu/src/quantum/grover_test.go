@@ -0,0 +1,167 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNewPhaseOracle(t *testing.T) {
+	oracle := NewPhaseOracle(3, func(x int) bool { return x == 2 || x == 5 })
+	if !oracle.IsUnitary() {
+		t.Error("Phase oracle is not unitary.")
+	}
+	// The kernel must agree with the matrix, with the targets in any
+	// order.
+	targets := []int{3, 0, 2}
+	expected := newDistinctQReg(4)
+	NewFuncGateNoCheck(oracle.get, 3).Apply(expected, targets)
+	actual := newDistinctQReg(4)
+	oracle.Apply(actual, targets)
+	if !verifySameState(expected, actual) {
+		t.Error("Phase oracle kernel disagrees with its matrix.")
+	}
+	for x := 0; x < 8; x++ {
+		expected := complex(1, 0)
+		if x == 2 || x == 5 {
+			expected = -1
+		}
+		if actual := oracle.Get(x, x); actual != expected {
+			t.Errorf("Bad diagonal element %d = %v, expected %v.", x,
+				actual, expected)
+		}
+	}
+}
+
+func TestOptimalIterations(t *testing.T) {
+	tests := []struct {
+		successProb float64
+		iterations  int
+	}{
+		{0, 0}, {1, 0}, {0.5, 1}, {0.25, 1}, {1.0 / 8, 2},
+		{1.0 / 1024, 25},
+	}
+	for _, test := range tests {
+		if actual := optimalIterations(test.successProb); actual != test.iterations {
+			t.Errorf("optimalIterations(%g) = %d, expected %d.",
+				test.successProb, actual, test.iterations)
+		}
+	}
+}
+
+func TestAmplitudeAmplification(t *testing.T) {
+	tests := []struct {
+		n      int
+		marked []int
+	}{
+		{3, []int{5}},
+		{6, []int{1, 17, 40}},
+		{8, []int{200}},
+		{4, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}},
+	}
+	for _, test := range tests {
+		isMarked := make(map[int]bool)
+		for _, x := range test.marked {
+			isMarked[x] = true
+		}
+		predicate := func(x int) bool { return isMarked[x] }
+		qreg := NewQReg(test.n)
+		iterations := AmplitudeAmplification(qreg,
+			NewHadamardGate(test.n), predicate)
+
+		// The probability of success is sin^2((2k + 1) theta).
+		theta := math.Asin(math.Sqrt(float64(len(test.marked)) /
+			float64(int(1)<<uint(test.n))))
+		expected := math.Pow(math.Sin(float64(2*iterations+1)*theta), 2)
+		actual := 0.0
+		for _, x := range test.marked {
+			actual += qreg.StateProb(x)
+		}
+		if !verifyProb(expected, actual) {
+			t.Errorf("%v: bad probability of success %g after %d "+
+				"iterations, expected %g.", test.marked, actual,
+				iterations, expected)
+		}
+		if actual < 0.7 {
+			t.Errorf("%v: probability of success is only %g.",
+				test.marked, actual)
+		}
+	}
+}
+
+// The predicate sees bit i as the value of qubit i, so for a big-endian
+// register the amplified label is the bit reversal of the value it accepts.
+func TestAmplitudeAmplification_BigEndian(t *testing.T) {
+	qreg := NewQReg(3)
+	qreg.SetBitOrder(BigEndian)
+	AmplitudeAmplification(qreg, NewHadamardGate(3),
+		func(x int) bool { return x == 1 })
+	if prob := qreg.BProb(0)[1]; prob < 0.9 {
+		t.Errorf("Probability of qubit 0 being 1 is only %g.", prob)
+	}
+	if prob := qreg.StateProb(4); prob < 0.9 {
+		t.Errorf("Probability of |100> is only %g.", prob)
+	}
+}
+
+// Amplitude amplification works with any state preparation, not just the
+// uniform superposition.
+func TestAmplitudeAmplification_Prepare(t *testing.T) {
+	// Prepare each qubit with probability 0.1 of being 1, so that |11>
+	// has probability 0.01 before amplification.
+	theta := 2 * math.Asin(math.Sqrt(0.1))
+	prepare := NewFuncGateNoCheck(func(row, col int) complex128 {
+		ry := RotationY(theta)
+		return ry.get(row&1, col&1) * ry.get(row>>1, col>>1)
+	},
+		2)
+	qreg := NewQReg(2)
+	iterations := AmplitudeAmplification(qreg, prepare,
+		func(x int) bool { return x == 3 })
+	if iterations != 7 {
+		t.Errorf("Bad number of iterations %d, expected 7.", iterations)
+	}
+	if prob := qreg.StateProb(3); prob < 0.95 {
+		t.Errorf("Probability of success is only %g.", prob)
+	}
+}
+
+func TestGroverSearch(t *testing.T) {
+	found := 0
+	source := rand.NewSource(1)
+	for i := 0; i < 20; i++ {
+		if x := GroverSearchWithRand(source, 6, func(x int) bool { return x%21 == 11 }); x%21 == 11 {
+			found++
+		}
+	}
+	// The probability of success is over 0.99.
+	if found < 18 {
+		t.Errorf("Found a marked value in only %d of 20 searches.", found)
+	}
+}
+
+func TestTryAmplitudeAmplification_Errors(t *testing.T) {
+	_, err := TryAmplitudeAmplification(NewQReg(3), NewHadamardGate(2),
+		func(x int) bool { return x == 1 })
+	if !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+}
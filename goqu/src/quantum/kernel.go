@@ -0,0 +1,304 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// The kernels in this file apply a gate's matrix to a state vector in place.
+// The targets are bit positions within the basis state labels, and bit i of
+// the matrix's row and column indices corresponds to targets[i]. The
+// amplitudes are split into groups of 2^len(targets) which differ only in
+// their target bits, and each group is numbered by the values of its
+// non-target bits. Groups whose labels do not match value on the bits in mask
+// are left untouched.
+
+// The largest matrix (by number of elements) whose elements are cached by the
+// dense kernel rather than fetched with get for every group.
+const maxCachedMatrixSize = 1 << 16
+
+// A kernel applies a gate to every group of a state vector, using at most the
+// given number of worker goroutines.
+type kernel func(amplitudes []complex128, targets []int, mask, value, workers int)
+
+// Process the groups numbered from 0 to numGroups by calling body on ranges of
+// group numbers. Distinct groups never share amplitudes, so the ranges are
+// processed in parallel by at most the given number of workers.
+func forEachGroup(numGroups, workers int, body func(start, end int)) {
+	parallelFor(numGroups, workers, body)
+}
+
+// Insert a zero bit into x at each of the given positions, which must be in
+// increasing order, shifting the higher bits of x up to make room.
+func insertZeroBits(x int, sortedBits []int) int {
+	for _, bit := range sortedBits {
+		low := x & (1<<uint(bit) - 1)
+		x = (x>>uint(bit))<<uint(bit+1) | low
+	}
+	return x
+}
+
+// Build the kernel which applies the gate's matrix to its targets. The
+// matrix is read once here, rather than once per group.
+func (gate *Gate) newKernel() kernel {
+	switch {
+	case gate.kernel != nil:
+		return gate.kernel
+	case gate.permutation != nil:
+		return newPermutationKernel(gate.permutation)
+	case gate.width == 1:
+		return newOneQubitKernel(gate)
+	case gate.width == 2:
+		return newTwoQubitKernel(gate)
+	}
+	return newDenseKernel(gate)
+}
+
+// A kernel for a single-qubit gate, which pairs each amplitude with the one
+// a fixed stride away.
+func newOneQubitKernel(gate *Gate) kernel {
+	m00, m01 := gate.get(0, 0), gate.get(0, 1)
+	m10, m11 := gate.get(1, 0), gate.get(1, 1)
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		target := uint(targets[0])
+		stride := 1 << target
+		low := stride - 1
+		forEachGroup(len(amplitudes)>>1, workers, func(start, end int) {
+			for group := start; group < end; group++ {
+				i := (group>>target)<<(target+1) | group&low
+				if i&mask != value {
+					continue
+				}
+				j := i | stride
+				a0, a1 := amplitudes[i], amplitudes[j]
+				amplitudes[i] = m00*a0 + m01*a1
+				amplitudes[j] = m10*a0 + m11*a1
+			}
+		})
+	}
+}
+
+// A kernel for a two-qubit gate, which acts on quadruples of amplitudes.
+func newTwoQubitKernel(gate *Gate) kernel {
+	var m [4][4]complex128
+	for row := 0; row < 4; row++ {
+		for col := 0; col < 4; col++ {
+			m[row][col] = gate.get(row, col)
+		}
+	}
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		stride0 := 1 << uint(targets[0])
+		stride1 := 1 << uint(targets[1])
+		lo, hi := uint(targets[0]), uint(targets[1])
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		lowMask := 1<<lo - 1
+		highMask := 1<<hi - 1
+		forEachGroup(len(amplitudes)>>2, workers, func(start, end int) {
+			for group := start; group < end; group++ {
+				i := (group>>lo)<<(lo+1) | group&lowMask
+				i = (i>>hi)<<(hi+1) | i&highMask
+				if i&mask != value {
+					continue
+				}
+				i1 := i | stride0
+				i2 := i | stride1
+				i3 := i1 | stride1
+				a0, a1, a2, a3 := amplitudes[i], amplitudes[i1], amplitudes[i2], amplitudes[i3]
+				amplitudes[i] = m[0][0]*a0 + m[0][1]*a1 + m[0][2]*a2 + m[0][3]*a3
+				amplitudes[i1] = m[1][0]*a0 + m[1][1]*a1 + m[1][2]*a2 + m[1][3]*a3
+				amplitudes[i2] = m[2][0]*a0 + m[2][1]*a1 + m[2][2]*a2 + m[2][3]*a3
+				amplitudes[i3] = m[3][0]*a0 + m[3][1]*a1 + m[3][2]*a2 + m[3][3]*a3
+			}
+		})
+	}
+}
+
+// Compute the offset from the first label of a group to the label of each of
+// its members, and the targets sorted in increasing order.
+func groupOffsets(targets []int) ([]int, []int) {
+	offsets := make([]int, 1<<uint(len(targets)))
+	for j := range offsets {
+		for i, target := range targets {
+			offsets[j] |= ((j >> uint(i)) & 1) << uint(target)
+		}
+	}
+	sortedTargets := append([]int(nil), targets...)
+	sort.Ints(sortedTargets)
+	return offsets, sortedTargets
+}
+
+// A kernel for a gate of any width, which gathers each group of amplitudes
+// into a buffer, multiplies it by the matrix and scatters the result back.
+func newDenseKernel(gate *Gate) kernel {
+	dim := gate.dim()
+	get := gate.get
+	if dim*dim <= maxCachedMatrixSize {
+		matrix := gateMatrix(gate)
+		get = func(row, col int) complex128 {
+			return matrix[row*dim+col]
+		}
+	}
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		offsets, sortedTargets := groupOffsets(targets)
+		forEachGroup(len(amplitudes)/dim, workers, func(start, end int) {
+			in := make([]complex128, dim)
+			for group := start; group < end; group++ {
+				base := insertZeroBits(group, sortedTargets)
+				if base&mask != value {
+					continue
+				}
+				for col, offset := range offsets {
+					in[col] = amplitudes[base+offset]
+				}
+				for row, offset := range offsets {
+					sum := complex128(0)
+					for col, a := range in {
+						if a != 0 {
+							sum += get(row, col) * a
+						}
+					}
+					amplitudes[base+offset] = sum
+				}
+			}
+		})
+	}
+}
+
+// A kernel for a classical gate, which permutes each group of amplitudes by
+// sending the amplitude of member x to member perm[x].
+func newPermutationKernel(perm []int) kernel {
+	dim := len(perm)
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		offsets, sortedTargets := groupOffsets(targets)
+		forEachGroup(len(amplitudes)/dim, workers, func(start, end int) {
+			in := make([]complex128, dim)
+			for group := start; group < end; group++ {
+				base := insertZeroBits(group, sortedTargets)
+				if base&mask != value {
+					continue
+				}
+				for x, offset := range offsets {
+					in[x] = amplitudes[base+offset]
+				}
+				for x, a := range in {
+					amplitudes[base+offsets[perm[x]]] = a
+				}
+			}
+		})
+	}
+}
+
+// A kernel for a diagonal gate, which multiplies member x of each group by
+// diagonal[x] and leaves the members whose element is 1 alone.
+func newDiagonalKernel(diagonal []complex128) kernel {
+	dim := len(diagonal)
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		offsets, sortedTargets := groupOffsets(targets)
+		forEachGroup(len(amplitudes)/dim, workers, func(start, end int) {
+			for group := start; group < end; group++ {
+				base := insertZeroBits(group, sortedTargets)
+				if base&mask != value {
+					continue
+				}
+				for x, offset := range offsets {
+					if diagonal[x] != 1 {
+						amplitudes[base+offset] *= diagonal[x]
+					}
+				}
+			}
+		})
+	}
+}
+
+// A kernel for the tensor product of copies of a single-qubit gate, which
+// applies the single-qubit kernel to each target in turn.
+func newTensorPowerKernel(factor *Gate) kernel {
+	one := newOneQubitKernel(factor)
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		for i := range targets {
+			one(amplitudes, targets[i:i+1], mask, value, workers)
+		}
+	}
+}
+
+// A kernel for the quantum Fourier transform on width qubits, or its inverse
+// for a negative sign, which transforms each group of amplitudes with a radix-2
+// FFT in O(dim log dim) steps rather than multiplying by the matrix.
+func newFourierKernel(width int, sign float64) kernel {
+	dim := 1 << uint(width)
+	// The powers of the root of unity e^(sign 2 pi i / dim).
+	twiddles := make([]complex128, dim/2)
+	for k := range twiddles {
+		twiddles[k] = cmplx.Rect(1, sign*2*math.Pi*float64(k)/float64(dim))
+	}
+	norm := complex(1/math.Sqrt(float64(dim)), 0)
+	return func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		offsets, sortedTargets := groupOffsets(targets)
+		forEachGroup(len(amplitudes)/dim, workers, func(start, end int) {
+			in := make([]complex128, dim)
+			for group := start; group < end; group++ {
+				base := insertZeroBits(group, sortedTargets)
+				if base&mask != value {
+					continue
+				}
+				// Gather the group in bit-reversed order, which
+				// the iterative FFT expects.
+				for x, offset := range offsets {
+					in[reverseBits(x, width)] = amplitudes[base+offset]
+				}
+				fft(in, twiddles)
+				for y, offset := range offsets {
+					amplitudes[base+offset] = in[y] * norm
+				}
+			}
+		})
+	}
+}
+
+// Reverse the lowest width bits of x.
+func reverseBits(x int, width int) int {
+	reversed := 0
+	for i := 0; i < width; i++ {
+		reversed = reversed<<1 | (x>>uint(i))&1
+	}
+	return reversed
+}
+
+// Transform a buffer in bit-reversed order in place, by combining the
+// transforms of pairs of halves of increasing size. twiddles[k] is w^k, where
+// w is the root of unity of order len(buffer) used by the transform.
+func fft(buffer []complex128, twiddles []complex128) {
+	dim := len(buffer)
+	for size := 2; size <= dim; size <<= 1 {
+		half := size / 2
+		step := dim / size
+		for start := 0; start < dim; start += size {
+			for k := 0; k < half; k++ {
+				even := buffer[start+k]
+				odd := buffer[start+k+half] * twiddles[k*step]
+				buffer[start+k] = even + odd
+				buffer[start+k+half] = even - odd
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Apply a gate to a copy of the amplitudes by multiplying by the matrix of
+// the gate extended to the whole register, for comparison with the kernels.
+// The targets are bit positions.
+func referenceApply(gate *Gate, amplitudes []complex128, targets []int) []complex128 {
+	targetMask := 0
+	for _, target := range targets {
+		targetMask |= 1 << uint(target)
+	}
+	// Extract the bits of a label at the target positions.
+	extract := func(label int) int {
+		index := 0
+		for i, target := range targets {
+			index |= ((label >> uint(target)) & 1) << uint(i)
+		}
+		return index
+	}
+	result := make([]complex128, len(amplitudes))
+	for row := range amplitudes {
+		for col := range amplitudes {
+			if row&^targetMask == col&^targetMask {
+				result[row] += gate.get(extract(row), extract(col)) * amplitudes[col]
+			}
+		}
+	}
+	return result
+}
+
+// Each kernel must agree with the reference matrix multiplication.
+func TestKernels_MatchReference(t *testing.T) {
+	width := 5
+	shift := NewClassicalGate(func(x int) int { return (x + 3) % 8 }, 3)
+	tests := []struct {
+		name    string
+		gate    *Gate
+		targets []int
+	}{
+		{"one qubit", RotationY(0.7), []int{3}},
+		{"one qubit, low bit", U3(0.3, 0.2, 0.1), []int{0}},
+		{"two qubit", FSim(0.4, 0.9), []int{1, 4}},
+		{"two qubit, reversed", IsingYY(0.4), []int{4, 1}},
+		{"two qubit, asymmetric", CX(), []int{3, 0}},
+		{"dense", NewFuncGateNoCheck(NewHadamardGate(3).get, 3), []int{4, 0, 2}},
+		{"dense, asymmetric", NewFuncGateNoCheck(CCX().get, 3), []int{2, 0, 3}},
+		{"permutation", shift, []int{1, 4, 2}},
+		{"tensor power", NewHadamardGate(3), []int{3, 1, 0}},
+		{"controlled one qubit", ControlledOn(SqrtX(), 0), []int{2, 1}},
+		{"controlled two qubit", Controlled(ISwap(), 2), []int{0, 4, 3, 1}},
+		{"controlled permutation", Controlled(shift, 1), []int{3, 0, 1, 4}},
+		{"controlled tensor power", Controlled(NewHadamardGate(2), 1), []int{2, 4, 0}},
+		{"fourier", NewQFTGate(3), []int{4, 0, 2}},
+		{"inverse fourier", NewInverseQFTGate(2), []int{3, 1}},
+		{"controlled fourier", Controlled(NewQFTGate(2), 1), []int{1, 4, 0}},
+	}
+	for _, test := range tests {
+		qreg := newDistinctQReg(width)
+		expected := referenceApply(test.gate, qreg.amplitudes, test.targets)
+		test.gate.Apply(qreg, test.targets)
+		for label := range expected {
+			if !verifyAmplitudeExact(expected[label], qreg.amplitudes[label]) {
+				t.Errorf("%s: bad amplitude for state %d = %+f, "+
+					"expected %+f.", test.name, label,
+					qreg.amplitudes[label], expected[label])
+			}
+		}
+	}
+}
+
+// Helper function to compare two complex amplitudes including their phase.
+func verifyAmplitudeExact(expected, actual complex128) bool {
+	d := expected - actual
+	return real(d)*real(d)+imag(d)*imag(d) < threshold*threshold
+}
+
+func TestReverseBits(t *testing.T) {
+	if x := reverseBits(6, 4); x != 6 {
+		t.Errorf("Bad result %b, expected 0110.", x)
+	}
+	if x := reverseBits(1, 5); x != 16 {
+		t.Errorf("Bad result %b, expected 10000.", x)
+	}
+}
+
+func TestInsertZeroBits(t *testing.T) {
+	// 0b111 with zeros inserted at bits 1 and 3 is 0b10101.
+	if x := insertZeroBits(7, []int{1, 3}); x != 21 {
+		t.Errorf("Bad result %b, expected 10101.", x)
+	}
+	if x := insertZeroBits(5, nil); x != 5 {
+		t.Errorf("Bad result %b, expected 101.", x)
+	}
+}
+
+// Benchmark the application of gates to registers of increasing width,
+// reporting throughput in gates per second. The widest register holds 2^26
+// amplitudes, which requires 1GiB of memory.
+func benchmarkGate(b *testing.B, gate *Gate) {
+	for width := 10; width <= 26; width += 4 {
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			qreg := NewQReg(width)
+			targets := make([]int, gate.Width())
+			for i := range targets {
+				// Spread the targets across the register.
+				targets[i] = (i*width/len(targets) + width/2) % width
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				gate.Apply(qreg, targets)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "gates/sec")
+		})
+	}
+}
+
+func BenchmarkApply_OneQubit(b *testing.B) {
+	benchmarkGate(b, RotationX(0.1))
+}
+
+func BenchmarkApply_TwoQubit(b *testing.B) {
+	benchmarkGate(b, FSim(0.1, 0.2))
+}
+
+func BenchmarkApply_Controlled(b *testing.B) {
+	benchmarkGate(b, CCX())
+}
+
+func BenchmarkApply_Dense(b *testing.B) {
+	benchmarkGate(b, NewFuncGateNoCheck(NewHadamardGate(3).get, 3))
+}
+
+func BenchmarkApply_Fourier(b *testing.B) {
+	benchmarkGate(b, NewQFTGate(8))
+}
@@ -0,0 +1,179 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// The linear algebra in this file works on small dense complex matrices, such
+// as the reduced density matrices of a few qubits, stored in row-major order.
+
+// Multiply two dim by dim matrices.
+func matMul(a, b []complex128, dim int) []complex128 {
+	product := make([]complex128, dim*dim)
+	for row := 0; row < dim; row++ {
+		for i := 0; i < dim; i++ {
+			if a[row*dim+i] == 0 {
+				continue
+			}
+			for col := 0; col < dim; col++ {
+				product[row*dim+col] += a[row*dim+i] * b[i*dim+col]
+			}
+		}
+	}
+	return product
+}
+
+// Compute the eigenvalues, in decreasing order, and the corresponding
+// orthonormal eigenvectors of a dim by dim Hermitian matrix. Eigenvector k is
+// column k of the returned matrix.
+func hermitianEigen(matrix []complex128, dim int) ([]float64, []complex128) {
+	// The Hermitian matrix A + iB has the same eigenvalues as the real
+	// symmetric matrix [[A, -B], [B, A]], each repeated twice, and its
+	// eigenvectors x + iy correspond to the eigenvectors (x, y) and
+	// (-y, x) of the real matrix.
+	n := 2 * dim
+	real2 := make([][]float64, n)
+	for i := range real2 {
+		real2[i] = make([]float64, n)
+	}
+	for row := 0; row < dim; row++ {
+		for col := 0; col < dim; col++ {
+			element := matrix[row*dim+col]
+			real2[row][col] = real(element)
+			real2[row+dim][col+dim] = real(element)
+			real2[row][col+dim] = -imag(element)
+			real2[row+dim][col] = imag(element)
+		}
+	}
+	values, vectors := symmetricEigen(real2)
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] > values[order[j]]
+	})
+
+	// Take one complex eigenvector from each pair, orthogonalising it
+	// against those already taken, since a degenerate eigenvalue may give
+	// both x + iy and a multiple of it.
+	eigenvalues := make([]float64, 0, dim)
+	eigenvectors := make([][]complex128, 0, dim)
+	for _, k := range order {
+		if len(eigenvalues) == dim {
+			break
+		}
+		v := make([]complex128, dim)
+		for i := range v {
+			v[i] = complex(vectors[i][k], vectors[i+dim][k])
+		}
+		for _, u := range eigenvectors {
+			overlap := complex(0, 0)
+			for i := range u {
+				overlap += cmplx.Conj(u[i]) * v[i]
+			}
+			for i := range v {
+				v[i] -= overlap * u[i]
+			}
+		}
+		norm := float64(0.0)
+		for _, a := range v {
+			norm += amplitudeProb(a)
+		}
+		if norm < 0.25 {
+			continue
+		}
+		scale := complex(1/math.Sqrt(norm), 0)
+		for i := range v {
+			v[i] *= scale
+		}
+		eigenvalues = append(eigenvalues, values[k])
+		eigenvectors = append(eigenvectors, v)
+	}
+
+	columns := make([]complex128, dim*dim)
+	for k, v := range eigenvectors {
+		for i, a := range v {
+			columns[i*dim+k] = a
+		}
+	}
+	return eigenvalues, columns
+}
+
+// Compute the eigenvalues and eigenvectors of a real symmetric matrix, which
+// is destroyed, by the cyclic Jacobi method. Eigenvector k is column k of the
+// returned matrix.
+func symmetricEigen(a [][]float64) ([]float64, [][]float64) {
+	n := len(a)
+	v := make([][]float64, n)
+	for i := range v {
+		v[i] = make([]float64, n)
+		v[i][i] = 1
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		off := float64(0.0)
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				// Rotate the (p, q) plane to zero a[p][q].
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = a[i][i]
+	}
+	return values, v
+}
@@ -0,0 +1,76 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math/cmplx"
+	"testing"
+)
+
+func TestHermitianEigen(t *testing.T) {
+	matrices := [][]complex128{
+		// A diagonal matrix with a repeated eigenvalue.
+		{0.5, 0, 0, 0.5},
+		// The Pauli Y matrix.
+		{0, complex(0, -1), complex(0, 1), 0},
+		{
+			2, complex(1, -1), 0, complex(0, 0.5),
+			complex(1, 1), -1, complex(0.3, 0.2), 0,
+			0, complex(0.3, -0.2), 0.5, 1,
+			complex(0, -0.5), 0, 1, 0,
+		},
+	}
+	for i, matrix := range matrices {
+		dim := 2
+		if len(matrix) == 16 {
+			dim = 4
+		}
+		values, vectors := hermitianEigen(matrix, dim)
+		for k := 1; k < dim; k++ {
+			if values[k] > values[k-1] {
+				t.Errorf("Matrix %d: eigenvalues %v not in "+
+					"decreasing order.", i, values)
+			}
+		}
+		// Check that V diag(values) V^dag is the matrix, and that V is
+		// unitary.
+		diag := make([]complex128, dim*dim)
+		dagger := make([]complex128, dim*dim)
+		for row := 0; row < dim; row++ {
+			diag[row*dim+row] = complex(values[row], 0)
+			for col := 0; col < dim; col++ {
+				dagger[row*dim+col] = cmplx.Conj(vectors[col*dim+row])
+			}
+		}
+		product := matMul(matMul(vectors, diag, dim), dagger, dim)
+		identity := matMul(dagger, vectors, dim)
+		for j := range matrix {
+			expected := complex(0, 0)
+			if j%(dim+1) == 0 {
+				expected = 1
+			}
+			if cmplx.Abs(product[j]-matrix[j]) > threshold ||
+				cmplx.Abs(identity[j]-expected) > threshold {
+				t.Errorf("Matrix %d: bad eigendecomposition with "+
+					"values %v and vectors %v.", i, values,
+					vectors)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// A NoiseModel describes the errors of a noisy quantum computer. Once it is
+// attached to a QReg with SetNoiseModel, each gate applied to the register is
+// followed by the channels of its quantum errors, chosen stochastically, and
+// each measured bit may be misread according to its readout error.
+type NoiseModel struct {
+	// The quantum errors, in the order in which they were added.
+	quantumErrors []quantumError
+
+	// The readout error of every qubit without one of its own, or nil.
+	allQubitReadout *[2][2]float64
+
+	// The readout errors of particular qubits.
+	readout map[int][2][2]float64
+}
+
+// A channel which follows the gates with the given names, or every gate if
+// names is nil, when they are applied to the given qubits, or to any qubits if
+// qubits is nil.
+type quantumError struct {
+	channel *Channel
+	names   map[string]bool
+	qubits  []int
+}
+
+// Constructor for a NoiseModel without any errors.
+func NewNoiseModel() *NoiseModel {
+	return &NoiseModel{readout: make(map[int][2][2]float64)}
+}
+
+// Add an error which follows each gate with one of the given names (as
+// returned by Gate.Name), or every gate if no names are given. A channel on
+// a single qubit is applied to each of the gate's targets in turn, while a
+// wider channel only follows gates of the same width and is applied to their
+// targets in order. The model is returned so that calls can be chained.
+func (model *NoiseModel) AddAllQubitQuantumError(channel *Channel, names ...string) *NoiseModel {
+	model.quantumErrors = append(model.quantumErrors, quantumError{
+		channel: channel,
+		names:   nameSet(names)})
+	return model
+}
+
+// Like AddAllQubitQuantumError, but the error only follows gates applied to
+// exactly the given qubits, in the same order. The channel must act on a
+// single qubit or on as many qubits as are given.
+func (model *NoiseModel) AddQuantumError(channel *Channel, qubits []int, names ...string) *NoiseModel {
+	if channel.width != 1 && channel.width != len(qubits) {
+		panic(fmt.Errorf("%w: channel of width %d given %d qubits",
+			ErrWidthMismatch, channel.width, len(qubits)))
+	}
+	model.quantumErrors = append(model.quantumErrors, quantumError{
+		channel: channel,
+		names:   nameSet(names),
+		qubits:  append([]int(nil), qubits...)})
+	return model
+}
+
+func nameSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// Add a readout error for every qubit which does not have one of its own.
+// Element [i][j] of probs is the probability of reading j when measuring a
+// qubit whose value is i, so each row must sum to 1. The state collapses
+// according to the true value; only the value reported is in error.
+func (model *NoiseModel) AddAllQubitReadoutError(probs [2][2]float64) *NoiseModel {
+	checkReadoutError(probs)
+	model.allQubitReadout = &probs
+	return model
+}
+
+// Like AddAllQubitReadoutError, but for a single qubit, overriding any
+// readout error for all qubits.
+func (model *NoiseModel) AddReadoutError(qubit int, probs [2][2]float64) *NoiseModel {
+	checkReadoutError(probs)
+	model.readout[qubit] = probs
+	return model
+}
+
+func checkReadoutError(probs [2][2]float64) {
+	for _, row := range probs {
+		checkProbability("readout probability", row[0])
+		checkProbability("readout probability", row[1])
+		if math.Abs(row[0]+row[1]-1) > threshold {
+			panic(fmt.Errorf("%w: readout probabilities %v should "+
+				"sum to 1", ErrInvalidProbability, row))
+		}
+	}
+}
+
+// Test whether a quantum error follows a gate applied to the given targets.
+func (qerr *quantumError) matches(gate *Gate, targets []int) bool {
+	if qerr.names != nil && !qerr.names[gate.name] {
+		return false
+	}
+	if qerr.channel.width != 1 && qerr.channel.width != gate.width {
+		return false
+	}
+	if qerr.qubits == nil {
+		return true
+	}
+	if len(qerr.qubits) != len(targets) {
+		return false
+	}
+	for i, qubit := range qerr.qubits {
+		if targets[i] != qubit {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply the quantum errors which follow a gate which has just been applied to
+// the given targets of a register.
+func (model *NoiseModel) applyGateErrors(qreg *QReg, gate *Gate, targets []int) {
+	for i := range model.quantumErrors {
+		qerr := &model.quantumErrors[i]
+		if !qerr.matches(gate, targets) {
+			continue
+		}
+		if qerr.channel.width == 1 && gate.width != 1 {
+			for _, target := range targets {
+				qerr.channel.Apply(qreg, []int{target})
+			}
+		} else {
+			qerr.channel.Apply(qreg, targets)
+		}
+	}
+}
+
+// Return the value read for a qubit whose measured value is b.
+func (model *NoiseModel) readBit(qubit, b int, rng *rand.Rand) int {
+	probs, ok := model.readout[qubit]
+	if !ok {
+		if model.allQubitReadout == nil {
+			return b
+		}
+		probs = *model.allQubitReadout
+	}
+	if randFloat64(rng) < probs[b][1-b] {
+		return 1 - b
+	}
+	return b
+}
+
+// Return the value read for a series of qubits whose measured values are the
+// bits of outcome, where bit i is the value of qubits[i].
+func (model *NoiseModel) readBits(qubits []int, outcome int, rng *rand.Rand) int {
+	read := 0
+	for i, qubit := range qubits {
+		b := model.readBit(qubit, (outcome>>uint(i))&1, rng)
+		read |= b << uint(i)
+	}
+	return read
+}
+
+// Apply the readout errors to a histogram of outcomes, as returned by
+// QReg.SampleQubits, by misreading each shot independently.
+func (model *NoiseModel) readCounts(qubits []int, counts map[int]int, rng *rand.Rand) map[int]int {
+	read := make(map[int]int)
+	for outcome, count := range counts {
+		for shot := 0; shot < count; shot++ {
+			read[model.readBits(qubits, outcome, rng)]++
+		}
+	}
+	return read
+}
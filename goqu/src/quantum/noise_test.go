@@ -0,0 +1,155 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// A readout error which always misreads a qubit.
+var alwaysMisread = [2][2]float64{{0, 1}, {1, 0}}
+
+func TestNoiseModel_QuantumErrors(t *testing.T) {
+	// A channel which always flips both of its qubits.
+	flipBoth := NewKrausChannel([]complex128{
+		0, 0, 0, 1,
+		0, 0, 1, 0,
+		0, 1, 0, 0,
+		1, 0, 0, 0})
+	model := NewNoiseModel().
+		AddAllQubitQuantumError(BitFlip(1), "x").
+		AddQuantumError(BitFlip(1), []int{1, 0}, "cx").
+		AddAllQubitQuantumError(flipBoth, "swap", "z")
+	tests := []struct {
+		gate     *Gate
+		targets  []int
+		expected int
+	}{
+		// X followed by a bit flip does nothing.
+		{PauliX(), []int{0}, 0},
+		{PauliY(), []int{0}, 1},
+		// The error on a single qubit follows each target of CX.
+		{CX(), []int{1, 0}, 3},
+		{CX(), []int{0, 1}, 0},
+		{Swap(), []int{0, 1}, 3},
+		// The error on two qubits does not follow Z on one.
+		{PauliZ(), []int{1}, 0},
+	}
+	for i, test := range tests {
+		qreg := NewQReg(2)
+		qreg.SetNoiseModel(model)
+		test.gate.Apply(qreg, test.targets)
+		if !verifyProb(1, qreg.StateProb(test.expected)) {
+			t.Errorf("Test %d: expected |%d>.", i, test.expected)
+		}
+	}
+
+	// An error without names follows every gate.
+	qreg := NewQRegWithRand(rand.NewSource(1), 3)
+	qreg.SetNoiseModel(NewNoiseModel().AddAllQubitQuantumError(BitFlip(1)))
+	HadamardRange(qreg, 0, 2)
+	HadamardRange(qreg, 0, 2)
+	NewClassicalGate(func(x int) int { return x ^ 1 }, 1).Apply(qreg, []int{2})
+	// Each of the first two qubits goes through X H X H, which sends
+	// |0> to |1>, while the last is flipped twice.
+	if !verifyProb(1, qreg.StateProb(3)) {
+		t.Errorf("Expected |011>.")
+	}
+}
+
+func TestNoiseModel_AddQuantumError_WidthMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic for a mismatched channel.")
+		}
+	}()
+	NewNoiseModel().AddQuantumError(NewDepolarizingChannel(2, 0.1), []int{0, 1, 2})
+}
+
+func TestNoiseModel_ReadoutErrors(t *testing.T) {
+	model := NewNoiseModel().
+		AddAllQubitReadoutError(alwaysMisread).
+		AddReadoutError(2, [2][2]float64{{1, 0}, {0, 1}})
+	qreg := NewQReg(3, 1)
+	qreg.SetNoiseModel(model)
+
+	// The state collapses according to the true value.
+	if b := qreg.BMeasure(0); b != 0 || !isBasisState(qreg, 1) {
+		t.Errorf("Read %d, expected 0 without changing the state.", b)
+	}
+	if b := qreg.BMeasurePreserve(2); b != 0 {
+		t.Errorf("Read %d from qubit without errors, expected 0.", b)
+	}
+	if label := qreg.Measure(); label != 2 || !isBasisState(qreg, 1) {
+		t.Errorf("Read |%d>, expected |010>.", label)
+	}
+
+	qreg.SetBitOrder(BigEndian)
+	qreg.Set(4)
+	// Qubit 0 is now the leftmost digit, and qubit 2 is still read
+	// without errors.
+	if label := qreg.MeasurePreserve(); label != 2 {
+		t.Errorf("Read |%d> with big-endian order, expected |010>.",
+			label)
+	}
+	counts := qreg.Sample(10, nil)
+	if counts[2] != 10 {
+		t.Errorf("Bad samples %v, expected |010> every time.", counts)
+	}
+	counts = qreg.SampleQubits(10, nil, []int{1, 2})
+	if counts[1] != 10 {
+		t.Errorf("Bad samples %v, expected 1 every time.", counts)
+	}
+}
+
+func TestNoiseModel_InvalidReadoutError(t *testing.T) {
+	for _, probs := range [][2][2]float64{
+		{{0.5, 0.4}, {0, 1}},
+		{{1.5, -0.5}, {0, 1}},
+	} {
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || !errors.Is(err, ErrInvalidProbability) {
+					t.Errorf("Expected panic with "+
+						"ErrInvalidProbability for %v, got %v.",
+						probs, err)
+				}
+			}()
+			NewNoiseModel().AddAllQubitReadoutError(probs)
+		}()
+	}
+}
+
+// A reset is not affected by readout errors, while a measurement in a
+// circuit reports the misread value.
+func TestNoiseModel_Circuit(t *testing.T) {
+	circuit := NewCircuit(2).
+		Append(PauliX(), 0).
+		Reset(0).
+		Append(PauliX(), 1).
+		Measure(0, 0).
+		Measure(1, 1)
+	qreg := NewQReg(2)
+	qreg.SetNoiseModel(NewNoiseModel().AddAllQubitReadoutError(alwaysMisread))
+	clbits := circuit.Run(qreg)
+	if clbits[0] != 1 || clbits[1] != 0 || !isBasisState(qreg, 2) {
+		t.Errorf("Bad classical bits %v, expected [1 0].", clbits)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Work on the amplitudes of large registers is split into chunks, which are
+// processed by a bounded number of worker goroutines. Below minChunkSize
+// items, the overhead of starting a worker outweighs its benefit.
+const minChunkSize = 1 << 12
+
+// The maximum number of worker goroutines, or 0 to use GOMAXPROCS.
+var maxWorkers int32
+
+// Set the maximum number of worker goroutines used to process a register. If
+// n is not positive, the maximum is reset to GOMAXPROCS.
+func SetMaxWorkers(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&maxWorkers, int32(n))
+}
+
+// Get the maximum number of worker goroutines used to process a register.
+func MaxWorkers() int {
+	if n := atomic.LoadInt32(&maxWorkers); n > 0 {
+		return int(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Split the items numbered from 0 to n into contiguous chunks, one for each
+// of at most the given number of workers, and call body on each chunk. Small
+// amounts of work are done on the calling goroutine.
+func parallelFor(n, workers int, body func(start, end int)) {
+	parallelForGrain(n, minChunkSize, workers, body)
+}
+
+// Like parallelFor, but no chunk is smaller than grain items, which should be
+// smaller when the work per item is greater.
+func parallelForGrain(n, grain, workers int, body func(start, end int)) {
+	forEachChunk(chunkBounds(n, grain, workers), func(chunk, start, end int) {
+		body(start, end)
+	})
+}
+
+// Split the items numbered from 0 to n into contiguous chunks of at least
+// grain items, one for each of at most the given number of workers. Chunk i
+// holds the items from bounds[i] to bounds[i+1].
+func chunkBounds(n, grain, workers int) []int {
+	if grain < 1 {
+		grain = 1
+	}
+	if chunks := n / grain; chunks < workers {
+		workers = chunks
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	bounds := make([]int, workers+1)
+	for worker := range bounds {
+		bounds[worker] = n * worker / workers
+	}
+	return bounds
+}
+
+// Call body on each chunk given by chunkBounds, with the number of the chunk.
+// A single chunk is done on the calling goroutine.
+func forEachChunk(bounds []int, body func(chunk, start, end int)) {
+	chunks := len(bounds) - 1
+	if chunks == 1 {
+		body(0, bounds[0], bounds[1])
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(chunks)
+	for chunk := 0; chunk < chunks; chunk++ {
+		chunk := chunk
+		go func() {
+			defer wg.Done()
+			body(chunk, bounds[chunk], bounds[chunk+1])
+		}()
+	}
+	wg.Wait()
+}
+
+// Compute the sum of term over the chunks of the items numbered from 0 to n.
+// The partial sums are added in order of their chunks, so that the result
+// does not depend on the order in which the workers finish.
+func parallelSum(n, workers int, term func(start, end int) float64) float64 {
+	bounds := chunkBounds(n, minChunkSize, workers)
+	partials := make([]float64, len(bounds)-1)
+	forEachChunk(bounds, func(chunk, start, end int) {
+		partials[chunk] = term(start, end)
+	})
+	sum := float64(0.0)
+	for _, partial := range partials {
+		sum += partial
+	}
+	return sum
+}
@@ -0,0 +1,144 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMaxWorkers(t *testing.T) {
+	defer SetMaxWorkers(0)
+	if MaxWorkers() != runtime.GOMAXPROCS(0) {
+		t.Errorf("Bad default number of workers = %d, expected %d.",
+			MaxWorkers(), runtime.GOMAXPROCS(0))
+	}
+	SetMaxWorkers(3)
+	if MaxWorkers() != 3 {
+		t.Errorf("Bad number of workers = %d, expected 3.", MaxWorkers())
+	}
+	SetMaxWorkers(-1)
+	if MaxWorkers() != runtime.GOMAXPROCS(0) {
+		t.Error("Expected negative number of workers to reset the default.")
+	}
+}
+
+// Every item must be processed exactly once, whatever the number of workers.
+func TestParallelFor_CoversEachItemOnce(t *testing.T) {
+	defer SetMaxWorkers(0)
+	for _, workers := range []int{1, 2, 3, 8} {
+		SetMaxWorkers(workers)
+		for _, n := range []int{0, 1, minChunkSize - 1, 5*minChunkSize + 7} {
+			counts := make([]int32, n)
+			var calls int32
+			parallelFor(n, workers, func(start, end int) {
+				atomic.AddInt32(&calls, 1)
+				for i := start; i < end; i++ {
+					atomic.AddInt32(&counts[i], 1)
+				}
+			})
+			for i, count := range counts {
+				if count != 1 {
+					t.Fatalf("Workers %d, n %d: item %d processed "+
+						"%d times.", workers, n, i, count)
+				}
+			}
+			if int(calls) > workers {
+				t.Errorf("Workers %d, n %d: %d chunks processed.",
+					workers, n, calls)
+			}
+		}
+	}
+}
+
+// Applying gates, composing registers and computing probabilities must give
+// the same results in parallel as serially.
+func TestParallel_MatchesSerial(t *testing.T) {
+	defer SetMaxWorkers(0)
+	run := func(workers int) (*QReg, [2]float64) {
+		SetMaxWorkers(workers)
+		qreg := Compose(newDistinctQReg(2), newDistinctQReg(13))
+		RotationY(0.3).Apply(qreg, []int{7})
+		FSim(0.2, 0.5).Apply(qreg, []int{14, 2})
+		Controlled(NewFuncGateNoCheck(NewHadamardGate(3).get, 3), 1).Apply(qreg, []int{0, 5, 9, 11})
+		HadamardRange(qreg, 3, 12)
+		return qreg, qreg.BProb(9)
+	}
+	serial, serialProb := run(1)
+	parallel, parallelProb := run(4)
+	for label := range serial.amplitudes {
+		if !verifyAmplitudeExact(serial.amplitudes[label], parallel.amplitudes[label]) {
+			t.Fatalf("Bad amplitude for state %d = %+f, expected %+f.",
+				label, parallel.amplitudes[label],
+				serial.amplitudes[label])
+		}
+	}
+	if !verifyProb(serialProb[1], parallelProb[1]) {
+		t.Errorf("Bad probability %f, expected %f.", parallelProb[1],
+			serialProb[1])
+	}
+}
+
+func TestIsUnitary_Parallel(t *testing.T) {
+	defer SetMaxWorkers(0)
+	SetMaxWorkers(4)
+	diffusion := NewDiffusionGate(6)
+	if !diffusion.IsUnitary() {
+		t.Error("Expected diffusion gate to be unitary.")
+	}
+	notUnitary := NewFuncGateNoCheck(func(row, col int) complex128 {
+		if row == 63 && col == 63 {
+			return 2
+		}
+		return diffusion.get(row, col)
+	}, 6)
+	if notUnitary.IsUnitary() {
+		t.Error("Expected gate not to be unitary.")
+	}
+}
+
+// Parallel sums must be reproducible bit for bit, whatever order the workers
+// finish in.
+func TestParallelSum_Reproducible(t *testing.T) {
+	defer SetMaxWorkers(0)
+	SetMaxWorkers(8)
+	n := 8 * minChunkSize
+	term := func(start, end int) float64 {
+		partial := float64(0.0)
+		for i := start; i < end; i++ {
+			partial += 1.0 / float64(i+1)
+		}
+		return partial
+	}
+	expected := parallelSum(n, MaxWorkers(), term)
+	qreg := Compose(newDistinctQReg(2), newDistinctQReg(13))
+	expectedProbs := qreg.marginalProbs([]int{3, 9})
+	for run := 0; run < 20; run++ {
+		if sum := parallelSum(n, MaxWorkers(), term); sum != expected {
+			t.Fatalf("Sum %v differs from earlier sum %v.", sum, expected)
+		}
+		for outcome, prob := range qreg.marginalProbs([]int{3, 9}) {
+			if prob != expectedProbs[outcome] {
+				t.Fatalf("Marginal probability %v of %d differs from "+
+					"earlier probability %v.", prob, outcome,
+					expectedProbs[outcome])
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+)
+
+// Estimate the eigenphase of a gate with quantum phase estimation. If
+// u|psi> = e^(2 pi i phi)|psi> for the state |psi> of eigenstate, the
+// returned distribution gives the probability of each estimate
+// y / 2^precisionBits of phi, indexed by y. It peaks at the nearest estimates
+// to phi, and is exactly at phi if it has precisionBits binary digits. If
+// eigenstate is a superposition of eigenstates, the result is the mixture of
+// their distributions. The gate acts upon the qubits of eigenstate, which is
+// left unchanged.
+func PhaseEstimation(u *Gate, eigenstate *QReg, precisionBits int) []float64 {
+	probs, err := TryPhaseEstimation(u, eigenstate, precisionBits)
+	if err != nil {
+		panic(err)
+	}
+	return probs
+}
+
+// Like PhaseEstimation, but returns ErrWidthMismatch if the gate is not as wide
+// as the register, or ErrInvalidWidth if precisionBits is not positive,
+// instead of panicking.
+func TryPhaseEstimation(u *Gate, eigenstate *QReg, precisionBits int) ([]float64, error) {
+	if u.width != eigenstate.width {
+		return nil, fmt.Errorf("%w: gate of width %d given a register "+
+			"of width %d", ErrWidthMismatch, u.width,
+			eigenstate.width)
+	}
+	if precisionBits <= 0 {
+		return nil, fmt.Errorf("%w: %d is not a valid number of "+
+			"precision bits", ErrInvalidWidth, precisionBits)
+	}
+
+	// The counting register holds the least significant bits, so with
+	// the little-endian bit order its qubits are 0 to precisionBits-1,
+	// and each qubit of the eigenstate is offset by precisionBits.
+	counting := NewQReg(precisionBits)
+	qreg := Compose(eigenstate, counting)
+	qreg.SetBitOrder(LittleEndian)
+	targets := make([]int, 1+u.width)
+	for i := 0; i < u.width; i++ {
+		targets[1+i] = precisionBits + int(eigenstate.bitPos(i))
+	}
+
+	// Counting qubit j controls u^(2^j), which kicks back the phase
+	// e^(2 pi i phi 2^j), so the counting register ends up in the Fourier
+	// transform of the estimate.
+	HadamardRange(qreg, 0, precisionBits)
+	power := gateMatrix(u)
+	dim := u.dim()
+	for j := 0; j < precisionBits; j++ {
+		targets[0] = j
+		Controlled(newMatrixGate(power, u.width), 1).Apply(qreg, targets)
+		if j+1 < precisionBits {
+			power = matMul(power, power, dim)
+		}
+	}
+	InverseQFTRange(qreg, 0, precisionBits)
+
+	countingQubits := make([]int, precisionBits)
+	for i := range countingQubits {
+		countingQubits[i] = i
+	}
+	return qreg.marginalProbs(countingQubits), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// Check a distribution over estimates against the expected probabilities of
+// some of them, with the rest having probability 0.
+func verifyDistribution(t *testing.T, name string, expected map[int]float64, actual []float64) {
+	for y, prob := range actual {
+		if !verifyProb(expected[y], prob) {
+			t.Errorf("%s: bad probability %g of estimate %d, "+
+				"expected %g.", name, prob, y, expected[y])
+		}
+	}
+}
+
+func TestPhaseEstimation(t *testing.T) {
+	// P(lambda)|1> = e^(i lambda)|1>, so the phase is 3/8.
+	eigenstate := NewQReg(1, 1)
+	probs := PhaseEstimation(Phase(2*math.Pi*3/8), eigenstate, 3)
+	verifyDistribution(t, "phase", map[int]float64{3: 1}, probs)
+	if !isBasisState(eigenstate, 1) {
+		t.Error("Eigenstate was changed.")
+	}
+
+	// rzz(pi)|01> = e^(i pi/2)|01>, so the phase is 1/4.
+	probs = PhaseEstimation(IsingZZ(math.Pi), NewQReg(2, 1), 4)
+	verifyDistribution(t, "rzz", map[int]float64{4: 1}, probs)
+
+	// |+> is an equal superposition of the eigenstates of Z, which have
+	// phases 0 and 1/2.
+	probs = PhaseEstimation(PauliZ(), KetPlus(), 2)
+	verifyDistribution(t, "superposition", map[int]float64{0: 0.5, 2: 0.5},
+		probs)
+}
+
+// The gate must act upon the qubits of the eigenstate in its own bit order.
+func TestPhaseEstimation_BitOrder(t *testing.T) {
+	// With the control set and the target in |->, CX has eigenvalue -1.
+	for _, order := range []BitOrder{LittleEndian, BigEndian} {
+		eigenstate := NewQReg(2)
+		eigenstate.SetBitOrder(order)
+		PauliX().Apply(eigenstate, []int{0})
+		PauliX().Apply(eigenstate, []int{1})
+		Hadamard(eigenstate, 1)
+		probs := PhaseEstimation(CX(), eigenstate, 2)
+		verifyDistribution(t, "cx", map[int]float64{2: 1}, probs)
+	}
+}
+
+// When the phase has more binary digits than the precision, the nearest
+// estimate is the most likely, with probability at least 4/pi^2.
+func TestPhaseEstimation_Inexact(t *testing.T) {
+	const bits = 5
+	phase := 1.0 / 3
+	probs := PhaseEstimation(Phase(2*math.Pi*phase), NewQReg(1, 1), bits)
+	best := 0
+	total := 0.0
+	for y, prob := range probs {
+		if prob > probs[best] {
+			best = y
+		}
+		total += prob
+	}
+	if !verifyProb(1, total) {
+		t.Errorf("Probabilities sum to %g.", total)
+	}
+	nearest := int(math.Round(phase * (1 << bits)))
+	if best != nearest {
+		t.Errorf("Most likely estimate is %d, expected %d.", best,
+			nearest)
+	}
+	if probs[best] < 4/(math.Pi*math.Pi) {
+		t.Errorf("Most likely estimate has probability %g.", probs[best])
+	}
+}
+
+func TestTryPhaseEstimation_Errors(t *testing.T) {
+	if _, err := TryPhaseEstimation(CX(), NewQReg(1), 3); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch for a narrow register, "+
+			"got %v.", err)
+	}
+	if _, err := TryPhaseEstimation(PauliZ(), NewQReg(1), 0); !errors.Is(err, ErrInvalidWidth) {
+		t.Errorf("Expected ErrInvalidWidth for no precision bits, "+
+			"got %v.", err)
+	}
+}
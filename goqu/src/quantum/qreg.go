@@ -22,6 +22,7 @@ import (
 	"math"
 	"math/cmplx"
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -29,6 +30,25 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
+// The bit order of a quantum register determines which bit of a basis state
+// label holds the value of each qubit. Every operation which takes a qubit
+// index (Gate.Apply, BProb, BSet, BMeasure, SampleQubits, ...) uses the bit
+// order of the register it acts upon. Basis state labels themselves, and
+// binary digits given to NewQReg or Set, are always written with the most
+// significant bit first, as in the ket |b_1 b_2 ... b_k>.
+type BitOrder int
+
+const (
+	// Qubit i is bit i of the label, counting from the least significant
+	// bit, i.e. qubit 0 is the rightmost digit of the ket. This is the
+	// default.
+	LittleEndian BitOrder = iota
+
+	// Qubit i is bit i of the label, counting from the most significant
+	// bit, i.e. qubit 0 is the leftmost digit of the ket.
+	BigEndian
+)
+
 // A QReg represents a quantum register.
 type QReg struct {
 	// The width (number of qubits) of this quantum register.
@@ -37,34 +57,312 @@ type QReg struct {
 	// The complex amplitudes for each of the standard basis states.
 	// There are math.Pow(2,width) of these.
 	amplitudes []complex128
+
+	// The source of randomness for measurements. If this is nil, the
+	// global source of randomness is used.
+	rng *rand.Rand
+
+	// The correspondence between qubit indices and bits of basis state
+	// labels.
+	order BitOrder
+
+	// The circuit into which the operations on this register are being
+	// recorded, or nil if it is not being recorded.
+	recording *Circuit
+
+	// The errors of the operations on this register, or nil if they are
+	// noiseless.
+	noise *NoiseModel
+
+	// Whether the operations on this register are done on the calling
+	// goroutine, as when it is one of many registers being processed in
+	// parallel.
+	serial bool
 }
 
 // Constructor for a QReg of the given width. Optionally set its initial
 // value to a basis state, specified either as an integer or a series of
 // binary digits.
 func NewQReg(width int, values ...int) *QReg {
-	qreg := &QReg{width, nil}
-	qreg.Set(values...)
+	qreg, err := TryNewQReg(width, values...)
+	if err != nil {
+		panic(err)
+	}
+	return qreg
+}
+
+// The widest register which can be simulated. Its 2^30 amplitudes take 16 GiB,
+// and each qubit more would double that, so wider registers are rejected
+// rather than left to fail when their amplitudes are allocated. This is also
+// the limit on the qubits of an OpenQASM program.
+const maxWidth = 30
+
+// Like NewQReg, but returns ErrInvalidWidth if width is negative or greater
+// than 30, or ErrInvalidLabel if the values are not a basis state of the
+// register, instead of panicking.
+func TryNewQReg(width int, values ...int) (*QReg, error) {
+	if width < 0 || width > maxWidth {
+		return nil, fmt.Errorf("%w: register of width %d",
+			ErrInvalidWidth, width)
+	}
+	qreg := &QReg{width: width}
+	if err := qreg.TrySet(values...); err != nil {
+		return nil, err
+	}
+	return qreg, nil
+}
+
+// Constructor for a QReg like NewQReg, but whose measurements draw from the
+// given source of randomness, so that their outcomes can be reproduced.
+func NewQRegWithRand(source rand.Source, width int, values ...int) *QReg {
+	qreg := NewQReg(width, values...)
+	qreg.SetRandSource(source)
 	return qreg
 }
 
-// Compose multiple quantum registers into a single register.
+// Set the source of randomness used for measurements of the QReg. If source
+// is nil, the global source of randomness is used.
+func (qreg *QReg) SetRandSource(source rand.Source) {
+	if source == nil {
+		qreg.rng = nil
+		return
+	}
+	qreg.rng = rand.New(source)
+}
+
+// Accessor for the bit order of a QReg.
+func (qreg *QReg) BitOrder() BitOrder {
+	return qreg.order
+}
+
+// Set the bit order of the QReg. This only changes how qubits are indexed,
+// not the state of the register.
+func (qreg *QReg) SetBitOrder(order BitOrder) {
+	qreg.order = order
+}
+
+// Accessor for the noise model of a QReg, or nil if it is noiseless.
+func (qreg *QReg) NoiseModel() *NoiseModel {
+	return qreg.noise
+}
+
+// Set the noise model of the QReg, whose errors then follow each gate
+// applied to it and each measurement of it. If model is nil, the register
+// becomes noiseless.
+func (qreg *QReg) SetNoiseModel(model *NoiseModel) {
+	qreg.noise = model
+}
+
+// The position within a basis state label of the bit holding the value of
+// the given qubit.
+func (qreg *QReg) bitPos(qubit int) uint {
+	if qreg.order == BigEndian {
+		return uint(qreg.width - 1 - qubit)
+	}
+	return uint(qubit)
+}
+
+// Convert a list of qubit indices into the positions of the corresponding
+// bits in the basis state labels of the register.
+func (qreg *QReg) targetBits(targets []int) []int {
+	if qreg.order == LittleEndian {
+		return targets
+	}
+	bits := make([]int, len(targets))
+	for i, target := range targets {
+		bits[i] = int(qreg.bitPos(target))
+	}
+	return bits
+}
+
+// Compose multiple quantum registers into a single register. The first
+// register holds the most significant bits of the combined basis state labels,
+// and the combined register has the bit order of the first register.
 func Compose(qregs ...*QReg) *QReg {
+	composedQReg, err := TryCompose(qregs...)
+	if err != nil {
+		panic(err)
+	}
+	return composedQReg
+}
+
+// Like Compose, but returns ErrTooFewRegisters if there are fewer than two
+// registers, ErrNilRegister if any of them is nil, or ErrInvalidWidth if the
+// combined register would be wider than 30 qubits, instead of panicking.
+func TryCompose(qregs ...*QReg) (*QReg, error) {
 	// There have to be at least two registers.
 	if len(qregs) < 2 {
-		panic("Compose called with fewer than two quantum registers.")
+		return nil, fmt.Errorf("%w: Compose called with %d",
+			ErrTooFewRegisters, len(qregs))
 	}
 
 	// Compute the width of the combined register.
-	newWidth := qregs[0].width
-	for regIndex := 1; regIndex < len(qregs); regIndex++ {
-		newWidth += qregs[regIndex].width
+	newWidth := 0
+	for regIndex, qreg := range qregs {
+		if qreg == nil {
+			return nil, fmt.Errorf("%w: register %d given to Compose",
+				ErrNilRegister, regIndex)
+		}
+		newWidth += qreg.width
+		if newWidth > maxWidth {
+			return nil, fmt.Errorf("%w: composed register is wider "+
+				"than %d qubits", ErrInvalidWidth, maxWidth)
+		}
+	}
+	composedQReg := &QReg{width: newWidth, amplitudes: make([]complex128, 1<<uint(newWidth))}
+	composedQReg.order = qregs[0].order
+
+	// Measurements draw from the first source of randomness given.
+	for _, qreg := range qregs {
+		if qreg.rng != nil {
+			composedQReg.rng = qreg.rng
+			break
+		}
 	}
-	composedQReg := &QReg{newWidth, make([]complex128, 1<<uint(newWidth))}
 
-	// Compute the amplitudes in a way that reuses intermediate values.
-	computeTensorProductAmplitudes(newWidth, 1.0, composedQReg.amplitudes, qregs...)
-	return composedQReg
+	// Compute the amplitudes in a way that reuses intermediate values. Each
+	// amplitude of the first register scales a disjoint slice of the
+	// output, so these slices are computed in parallel.
+	first, rest := qregs[0], qregs[1:]
+	restWidth := newWidth - first.width
+	sliceLen := 1 << uint(restWidth)
+	parallelForGrain(len(first.amplitudes), minChunkSize/sliceLen, MaxWorkers(), func(start, end int) {
+		for ampIndex := start; ampIndex < end; ampIndex++ {
+			amplitude := first.amplitudes[ampIndex]
+			if amplitude != 0 {
+				computeTensorProductAmplitudes(
+					restWidth,
+					amplitude,
+					composedQReg.amplitudes[ampIndex*sliceLen:(ampIndex+1)*sliceLen],
+					rest...)
+			}
+		}
+	})
+	return composedQReg, nil
+}
+
+// Decompose a register in a product state into registers of the given widths,
+// undoing Compose: the first register returned holds the most significant
+// bits of the basis state labels. If no widths are given, the register is
+// split into as many registers as possible. Every register returned has the
+// bit order and source of randomness of the original. Only the product of the
+// registers' global phases is determined, so the phase is given to the first.
+func Decompose(qreg *QReg, widths ...int) []*QReg {
+	qregs, err := TryDecompose(qreg, widths...)
+	if err != nil {
+		panic(err)
+	}
+	return qregs
+}
+
+// Like Decompose, but returns ErrWidthMismatch if the widths are not positive
+// or do not sum to the width of the register, or ErrEntangled if the state is
+// not a product of states of the given widths, instead of panicking.
+func TryDecompose(qreg *QReg, widths ...int) ([]*QReg, error) {
+	if len(widths) == 0 {
+		return decomposeFully(qreg), nil
+	}
+	sum := 0
+	for _, width := range widths {
+		if width <= 0 {
+			return nil, fmt.Errorf("%w: %d is not a valid width",
+				ErrWidthMismatch, width)
+		}
+		sum += width
+	}
+	if sum != qreg.width {
+		return nil, fmt.Errorf("%w: widths %v do not sum to %d",
+			ErrWidthMismatch, widths, qreg.width)
+	}
+	var qregs []*QReg
+	rest := qreg.amplitudes
+	restWidth := qreg.width
+	for _, width := range widths[:len(widths)-1] {
+		restWidth -= width
+		first, second := splitProduct(rest, restWidth)
+		if first == nil {
+			return nil, fmt.Errorf("%w: the first %d qubits of %d "+
+				"cannot be split from the rest", ErrEntangled,
+				qreg.width-restWidth, qreg.width)
+		}
+		qregs = append(qregs, qreg.withAmplitudes(first))
+		rest = second
+	}
+	return append(qregs, qreg.withAmplitudes(rest)), nil
+}
+
+// Split a register into as many registers as possible, by splitting off the
+// narrowest register of the most significant bits each time.
+func decomposeFully(qreg *QReg) []*QReg {
+	var qregs []*QReg
+	rest := qreg.amplitudes
+	restWidth := qreg.width
+	for width := 1; width < restWidth; width++ {
+		first, second := splitProduct(rest, restWidth-width)
+		if first != nil {
+			qregs = append(qregs, qreg.withAmplitudes(first))
+			rest = second
+			restWidth -= width
+			width = 0
+		}
+	}
+	return append(qregs, qreg.withAmplitudes(rest))
+}
+
+// Build a register with a copy of the given amplitudes, which may belong to
+// another register, and the bit order and source of randomness of another.
+func (qreg *QReg) withAmplitudes(amplitudes []complex128) *QReg {
+	width := 0
+	for 1<<uint(width) < len(amplitudes) {
+		width++
+	}
+	return &QReg{
+		width:      width,
+		amplitudes: append([]complex128(nil), amplitudes...),
+		rng:        qreg.rng,
+		order:      qreg.order}
+}
+
+// If a state is the tensor product of a state of its high bits and a normalised
+// state of its low lowWidth bits, return the amplitudes of the two states.
+// Otherwise return nil for both.
+func splitProduct(amplitudes []complex128, lowWidth int) ([]complex128, []complex128) {
+	// Viewing the amplitudes as a matrix whose rows are indexed by the high
+	// bits and columns by the low bits, the state is a product exactly when
+	// the matrix has rank 1. Its largest row is then a multiple of the low
+	// state, and projecting each row onto it gives the high state.
+	lowDim := 1 << uint(lowWidth)
+	highDim := len(amplitudes) / lowDim
+	largest, largestNorm := 0, float64(0.0)
+	for row := 0; row < highDim; row++ {
+		norm := float64(0.0)
+		for _, a := range amplitudes[row*lowDim : (row+1)*lowDim] {
+			norm += amplitudeProb(a)
+		}
+		if norm > largestNorm {
+			largest, largestNorm = row, norm
+		}
+	}
+	low := make([]complex128, lowDim)
+	scale := complex(1/math.Sqrt(largestNorm), 0)
+	for col := range low {
+		low[col] = amplitudes[largest*lowDim+col] * scale
+	}
+	high := make([]complex128, highDim)
+	residual := float64(0.0)
+	for row := range high {
+		for col, b := range low {
+			high[row] += amplitudes[row*lowDim+col] * cmplx.Conj(b)
+		}
+		for col, b := range low {
+			residual += amplitudeProb(amplitudes[row*lowDim+col] -
+				high[row]*b)
+		}
+	}
+	if residual > threshold {
+		return nil, nil
+	}
+	return high, low
 }
 
 // A helper function to compute the amplitudes of the tensor product of multiple
@@ -103,9 +401,22 @@ func computeTensorProductAmplitudes(width int, product complex128, outputSlice [
 // representation of a basis state. If a series of binary values are given,
 // interpret them as the binary representation of a basis state.
 func (qreg *QReg) Set(values ...int) {
+	if err := qreg.TrySet(values...); err != nil {
+		panic(err)
+	}
+}
+
+// Like Set, but returns ErrInvalidLabel instead of panicking. The register is
+// left unchanged if an error is returned.
+func (qreg *QReg) TrySet(values ...int) error {
+	label, err := qreg.tryBasisStateLabel(values...)
+	if err != nil {
+		return err
+	}
 	// The Hilbert space has dimension math.Pow(2,width).
 	qreg.amplitudes = make([]complex128, 1<<uint(qreg.width))
-	qreg.amplitudes[qreg.basisStateLabel(values...)] = 1
+	qreg.amplitudes[label] = 1
+	return nil
 }
 
 // Given a series of values, convert them into the label of a standard basis
@@ -115,18 +426,26 @@ func (qreg *QReg) Set(values ...int) {
 // values are given equal in number to width, interpret them as the binary
 // representation of such a label.
 func (qreg *QReg) basisStateLabel(values ...int) int {
+	label, err := qreg.tryBasisStateLabel(values...)
+	if err != nil {
+		panic(err)
+	}
+	return label
+}
+
+// Like basisStateLabel, but returns ErrInvalidLabel instead of panicking.
+func (qreg *QReg) tryBasisStateLabel(values ...int) (int, error) {
 	if len(values) == 0 {
 		// The basis state is |0>.
-		return 0
+		return 0, nil
 	} else if len(values) == 1 {
 		// Given integer d, the basis state is |d>.
-		if values[0] < 0 || values[0] >= len(qreg.amplitudes) {
-			errStr := fmt.Sprintf("The state |%d> is not "+
-				"possible for a register of width %d.",
-				values[0], qreg.width)
-			panic(errStr)
+		if values[0] < 0 || values[0] >= 1<<uint(qreg.width) {
+			return 0, fmt.Errorf("%w: the state |%d> is not "+
+				"possible for a register of width %d",
+				ErrInvalidLabel, values[0], qreg.width)
 		}
-		return values[0]
+		return values[0], nil
 	} else if len(values) == qreg.width {
 		// Given binary {b_1, b_2, ..., b_k}, the basis state is
 		// |b_1 b_2 ... b_k>.
@@ -134,14 +453,16 @@ func (qreg *QReg) basisStateLabel(values ...int) int {
 		for _, value := range values {
 			label <<= 1
 			if value < 0 || value > 1 {
-				panic("Unexpected non-binary value in label " +
-					"of quantum register.")
+				return 0, fmt.Errorf("%w: unexpected non-binary "+
+					"value %d in label of quantum register",
+					ErrInvalidLabel, value)
 			}
 			label += value
 		}
-		return label
+		return label, nil
 	}
-	panic("Bad label for quantum register.")
+	return 0, fmt.Errorf("%w: %d values given for a register of width %d",
+		ErrInvalidLabel, len(values), qreg.width)
 }
 
 // The eigenvectors of the Pauli matrices are defined here for convenience,
@@ -150,21 +471,21 @@ func (qreg *QReg) basisStateLabel(values ...int) int {
 // states for one qubit).
 func KetZero() *QReg {
 	// |0> = [1; 0]
-	return &QReg{1, []complex128{1, 0}}
+	return &QReg{width: 1, amplitudes: []complex128{1, 0}}
 }
 func KetOne() *QReg {
 	// |1> = [0; 1]
-	return &QReg{1, []complex128{0, 1}}
+	return &QReg{width: 1, amplitudes: []complex128{0, 1}}
 }
 
 // These are the eigenvectors of the Pauli X matrix.
 func KetPlus() *QReg {
 	// |+> = 1/sqrt{2}[1; 1]
-	return &QReg{1, []complex128{1 / math.Sqrt2, 1 / math.Sqrt2}}
+	return &QReg{width: 1, amplitudes: []complex128{1 / math.Sqrt2, 1 / math.Sqrt2}}
 }
 func KetMinus() *QReg {
 	// |-> = 1/sqrt{2}[1; -1]
-	return &QReg{1, []complex128{1 / math.Sqrt2, -1 / math.Sqrt2}}
+	return &QReg{width: 1, amplitudes: []complex128{1 / math.Sqrt2, -1 / math.Sqrt2}}
 }
 
 // These are the eigenvectors of the Pauli Y matrix.
@@ -173,11 +494,11 @@ func KetMinus() *QReg {
 // See http://code.google.com/p/go/issues/detail?id=4159
 func KetPlusI() *QReg {
 	// |+i> = 1/sqrt{2}[1; i]
-	return &QReg{1, []complex128{1 / math.Sqrt2, complex(0, 1/math.Sqrt2)}}
+	return &QReg{width: 1, amplitudes: []complex128{1 / math.Sqrt2, complex(0, 1/math.Sqrt2)}}
 }
 func KetMinusI() *QReg {
 	// |-i> = 1/sqrt{2}[1; -i]
-	return &QReg{1, []complex128{1 / math.Sqrt2, complex(0, -1/math.Sqrt2)}}
+	return &QReg{width: 1, amplitudes: []complex128{1 / math.Sqrt2, complex(0, -1/math.Sqrt2)}}
 }
 
 // Convenience constructor for a qubit, specified by its spherical coordinates
@@ -186,11 +507,43 @@ func NewQubitWithBlochCoords(theta, phi float64) *QReg {
 	// |psi> = cos(theta/2) + e^{i phi}sin(theta/2)
 	t := complex(theta/2, 0)
 	p := complex(phi, 0)
-	qreg := &QReg{1, []complex128{cmplx.Cos(t),
+	qreg := &QReg{width: 1, amplitudes: []complex128{cmplx.Cos(t),
 		cmplx.Exp(complex(0, 1)*p) * cmplx.Sin(t)}}
 	return qreg
 }
 
+// Start recording the gates applied to the register, and the measurements
+// made with BMeasure and Measure, into a new circuit. Each measurement is
+// recorded into a classical bit of its own, numbered in the order in which
+// the measurements were made.
+func (qreg *QReg) StartRecording() {
+	qreg.recording = NewCircuit(qreg.width)
+}
+
+// Stop recording the operations on the register, and return the circuit into
+// which they were recorded, or nil if the register was not being recorded.
+func (qreg *QReg) StopRecording() *Circuit {
+	circuit := qreg.recording
+	qreg.recording = nil
+	return circuit
+}
+
+// Append an operation to the recording of the register, if there is one.
+func (qreg *QReg) record(op Operation) {
+	if qreg.recording != nil {
+		qreg.recording.AppendOperation(op)
+	}
+}
+
+func (qreg *QReg) recordMeasurement(qubit int) {
+	if qreg.recording != nil {
+		qreg.record(Operation{
+			Kind:    MeasureOp,
+			Targets: []int{qubit},
+			Clbit:   qreg.recording.NumClbits()})
+	}
+}
+
 // Accessor for the width of a QReg.
 func (qreg *QReg) Width() int {
 	return qreg.width
@@ -199,45 +552,101 @@ func (qreg *QReg) Width() int {
 // Copy a QReg, for testing purposes only. The no-cloning theorem of course
 // prevents copying an actual quantum register in an unknown arbitrary state.
 func (qreg *QReg) Copy() *QReg {
-	newQreg := &QReg{qreg.width, make([]complex128, len(qreg.amplitudes))}
+	newQreg := &QReg{width: qreg.width, amplitudes: make([]complex128, len(qreg.amplitudes))}
 	copy(newQreg.amplitudes, qreg.amplitudes)
+	newQreg.rng = qreg.rng
+	newQreg.order = qreg.order
+	newQreg.noise = qreg.noise
 	return newQreg
 }
 
+// The maximum number of worker goroutines used to process this register.
+func (qreg *QReg) workers() int {
+	if qreg.serial {
+		return 1
+	}
+	return MaxWorkers()
+}
+
 // Compute the probability of observing a basis state.
 func (qreg *QReg) StateProb(values ...int) float64 {
-	label := qreg.basisStateLabel(values...)
+	prob, err := qreg.TryStateProb(values...)
+	if err != nil {
+		panic(err)
+	}
+	return prob
+}
+
+// Like StateProb, but returns ErrInvalidLabel instead of panicking.
+func (qreg *QReg) TryStateProb(values ...int) (float64, error) {
+	label, err := qreg.tryBasisStateLabel(values...)
+	if err != nil {
+		return 0, err
+	}
 	// The probability of observing a state is the square of the magnitude
 	// of the complex amplitude.
-	magnitude := cmplx.Abs(qreg.amplitudes[label])
-	return magnitude * magnitude
+	return amplitudeProb(qreg.amplitudes[label]), nil
+}
+
+// The probability of observing a basis state with the given amplitude.
+func amplitudeProb(amplitude complex128) float64 {
+	// This is the square of the magnitude of the complex amplitude.
+	return real(amplitude)*real(amplitude) + imag(amplitude)*imag(amplitude)
 }
 
 // Compute the probability of observing a specific bit for a basis state. The
-// individual bits of the register are indexed starting with 0 on the left
-// (i.e., most significant bit). A pair is returned corresponding to the
-// probabilities of observing 0 and 1, respectively.
+// individual bits of the register are indexed as qubits, according to the
+// register's bit order. A pair is returned corresponding to the probabilities
+// of observing 0 and 1, respectively.
 func (qreg *QReg) BProb(bitIndex int) [2]float64 {
-	prob := float64(0.0)
-	bitMask := 1 << uint(qreg.width-1-bitIndex)
+	probs, err := qreg.TryBProb(bitIndex)
+	if err != nil {
+		panic(err)
+	}
+	return probs
+}
+
+// Like BProb, but returns ErrInvalidTarget instead of panicking.
+func (qreg *QReg) TryBProb(bitIndex int) ([2]float64, error) {
+	if err := checkQubits([]int{bitIndex}, qreg.width); err != nil {
+		return [2]float64{}, err
+	}
+	pos := qreg.bitPos(bitIndex)
+	bitMask := 1 << pos
+	low := bitMask - 1
 	// Iterate through all the basis states where the indexed bit is 1, to
 	// sum the probability of observing 1 for that bit.
-	for label := 0 | bitMask; label < len(qreg.amplitudes); label = (label + 1) | bitMask {
-		prob += qreg.StateProb(label)
-	}
-	return [2]float64{1.0 - prob, prob}
+	prob := parallelSum(len(qreg.amplitudes)>>1, qreg.workers(), func(start, end int) float64 {
+		sum := float64(0.0)
+		for i := start; i < end; i++ {
+			label := (i>>pos)<<(pos+1) | i&low | bitMask
+			sum += amplitudeProb(qreg.amplitudes[label])
+		}
+		return sum
+	})
+	return [2]float64{1.0 - prob, prob}, nil
 }
 
 // Set a particular bit in a QReg. This should normally not be called directly,
 // except for testing purposes, since it is not a physically realistic operation.
 func (qreg *QReg) BSet(bitIndex int, value int) {
+	if err := qreg.TryBSet(bitIndex, value); err != nil {
+		panic(err)
+	}
+}
+
+// Like BSet, but returns ErrInvalidTarget or ErrInvalidLabel instead of
+// panicking.
+func (qreg *QReg) TryBSet(bitIndex int, value int) error {
+	if bitIndex < 0 || bitIndex >= qreg.width {
+		return fmt.Errorf("%w: bit index %d", ErrInvalidTarget, bitIndex)
+	}
 	if value < 0 || value > 1 {
-		errStr := fmt.Sprintf("Value %d should be either 0 or 1.",
-			value)
-		panic(errStr)
+		return fmt.Errorf("%w: value %d should be either 0 or 1",
+			ErrInvalidLabel, value)
 	}
-	bitMask := 1 << uint(qreg.width-1-bitIndex)
-	valueMask := value << uint(qreg.width-1-bitIndex)
+	bitMask := 1 << qreg.bitPos(bitIndex)
+	valueMask := value << qreg.bitPos(bitIndex)
 	bprob := qreg.BProb(bitIndex)[value]
 	if bprob > 0 {
 		// Go through the amplitudes associated with each basis state
@@ -258,7 +667,7 @@ func (qreg *QReg) BSet(bitIndex int, value int) {
 		// TODO(davinci): Investigate why it is here, and if possible remove it.
 
 		// Iterate through all the basis states where this bit is 1
-		notValueMask := (1 - value) << uint(qreg.width-1-bitIndex)
+		notValueMask := (1 - value) << qreg.bitPos(bitIndex)
 		for label := 0 | bitMask; label < len(qreg.amplitudes); label = (label + 1) | bitMask {
 			// Add the amplitude of the old label to the new label
 			oldLabel := label - valueMask
@@ -267,31 +676,111 @@ func (qreg *QReg) BSet(bitIndex int, value int) {
 			qreg.amplitudes[oldLabel] = complex(0, 0)
 		}
 	}
+	return nil
+}
+
+// Draw the true value of a bit from its probability distribution.
+func (qreg *QReg) sampleBit(bitIndex int) int {
+	if randFloat64(qreg.rng) < qreg.BProb(bitIndex)[0] {
+		return 0
+	}
+	return 1
+}
+
+// Return the value read for a qubit whose true value is b, which may be in
+// error according to the register's noise model.
+func (qreg *QReg) readBit(bitIndex, b int) int {
+	if qreg.noise == nil {
+		return b
+	}
+	return qreg.noise.readBit(bitIndex, b, qreg.rng)
 }
 
 // Simulate a measurement on a bit, i.e., get the result of the measurement
 // but without collapsing its quantum state.
 func (qreg *QReg) BMeasurePreserve(bitIndex int) int {
-	if rand.Float64() < qreg.BProb(bitIndex)[0] {
-		return 0
+	b, err := qreg.TryBMeasurePreserve(bitIndex)
+	if err != nil {
+		panic(err)
 	}
-	return 1
+	return b
+}
+
+// Like BMeasurePreserve, but returns ErrInvalidTarget instead of panicking.
+func (qreg *QReg) TryBMeasurePreserve(bitIndex int) (int, error) {
+	if err := checkQubits([]int{bitIndex}, qreg.width); err != nil {
+		return 0, err
+	}
+	return qreg.readBit(bitIndex, qreg.sampleBit(bitIndex)), nil
 }
 
-// Measure a bit (the quantum state of this qubit will collapse).
+// Measure a bit (the quantum state of this qubit will collapse). The state
+// collapses according to the true value of the bit, even if the value
+// returned is misread according to the register's noise model.
 func (qreg *QReg) BMeasure(bitIndex int) int {
-	b := qreg.BMeasurePreserve(bitIndex)
-	qreg.BSet(bitIndex, b)
+	b, err := qreg.TryBMeasure(bitIndex)
+	if err != nil {
+		panic(err)
+	}
 	return b
 }
 
+// Like BMeasure, but returns ErrInvalidTarget instead of panicking.
+func (qreg *QReg) TryBMeasure(bitIndex int) (int, error) {
+	if err := checkQubits([]int{bitIndex}, qreg.width); err != nil {
+		return 0, err
+	}
+	b := qreg.sampleBit(bitIndex)
+	qreg.BSet(bitIndex, b)
+	qreg.recordMeasurement(bitIndex)
+	return qreg.readBit(bitIndex, b), nil
+}
+
+// Reset a qubit to |0>, by measuring it and flipping it if it is 1. Unlike
+// measurement, the reset is not affected by readout errors.
+func (qreg *QReg) reset(qubit int) {
+	b := qreg.sampleBit(qubit)
+	qreg.BSet(qubit, b)
+	if b == 1 {
+		PauliX().applyMasked(qreg.amplitudes,
+			qreg.targetBits([]int{qubit}), 0, 0, qreg.workers())
+	}
+	qreg.record(Operation{Kind: ResetOp, Targets: []int{qubit}})
+}
+
+// The qubits corresponding to the bits of a basis state label, from the
+// least significant bit.
+func (qreg *QReg) labelQubits() []int {
+	qubits := make([]int, qreg.width)
+	for qubit := range qubits {
+		qubits[qreg.bitPos(qubit)] = qubit
+	}
+	return qubits
+}
+
+// Return the basis state label read for a register whose true state is the
+// given label, which may be in error according to the register's noise
+// model.
+func (qreg *QReg) readLabel(label int) int {
+	if qreg.noise == nil {
+		return label
+	}
+	return qreg.noise.readBits(qreg.labelQubits(), label, qreg.rng)
+}
+
 // Simulate a measurement on a register, i.e., get the result of the measurement
 // bit without collapsing its quantum state.
 func (qreg *QReg) MeasurePreserve() int {
-	r := rand.Float64()
+	return qreg.readLabel(qreg.sampleLabel())
+}
+
+// Draw the true basis state of the register from its probability
+// distribution.
+func (qreg *QReg) sampleLabel() int {
+	r := randFloat64(qreg.rng)
 	sum := float64(0.0)
 	for label := range qreg.amplitudes {
-		sum += qreg.StateProb(label)
+		sum += amplitudeProb(qreg.amplitudes[label])
 		if r < sum {
 			return label
 		}
@@ -301,7 +790,7 @@ func (qreg *QReg) MeasurePreserve() int {
 
 // Measure a register.
 func (qreg *QReg) Measure() int {
-	outputLabel := qreg.MeasurePreserve()
+	outputLabel := qreg.sampleLabel()
 
 	// We rescale the amplitude corresponding to the output basis state so
 	// that its probability is 1. However, we preserve the (global) phase,
@@ -312,7 +801,128 @@ func (qreg *QReg) Measure() int {
 		qreg.amplitudes[label] = complex(0, 0)
 	}
 	qreg.amplitudes[outputLabel] = amplitude
-	return outputLabel
+	for qubit := 0; qubit < qreg.width; qubit++ {
+		qreg.recordMeasurement(qubit)
+	}
+	return qreg.readLabel(outputLabel)
+}
+
+// Get a random number in [0, 1) from rng, or from the global source of
+// randomness if rng is nil.
+func randFloat64(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+// Get a random non-negative int64 from rng, or from the global source of
+// randomness if rng is nil.
+func randInt63(rng *rand.Rand) int64 {
+	if rng == nil {
+		return rand.Int63()
+	}
+	return rng.Int63()
+}
+
+// Draw shots samples from a discrete probability distribution, returning a
+// histogram of how often each outcome was drawn.
+func sampleDistribution(probs []float64, shots int, rng *rand.Rand) map[int]int {
+	// Build the cumulative distribution once, so that each shot is a
+	// binary search rather than a walk over all the outcomes.
+	cdf := make([]float64, len(probs))
+	sum := float64(0.0)
+	for i, prob := range probs {
+		sum += prob
+		cdf[i] = sum
+	}
+	counts := make(map[int]int)
+	for shot := 0; shot < shots; shot++ {
+		// Scale by the total so that rounding errors in the
+		// probabilities cannot push r past the last outcome.
+		r := randFloat64(rng) * sum
+		outcome := sort.Search(len(cdf), func(i int) bool {
+			return r < cdf[i]
+		})
+		if outcome == len(cdf) {
+			outcome--
+		}
+		counts[outcome]++
+	}
+	return counts
+}
+
+// Simulate measuring the register shots times, without collapsing its
+// quantum state. A histogram is returned mapping each observed basis state
+// label to the number of times it was observed. If rng is nil, the
+// register's own source of randomness is used.
+func (qreg *QReg) Sample(shots int, rng *rand.Rand) map[int]int {
+	if rng == nil {
+		rng = qreg.rng
+	}
+	probs := make([]float64, len(qreg.amplitudes))
+	parallelFor(len(probs), qreg.workers(), func(start, end int) {
+		for label := start; label < end; label++ {
+			probs[label] = amplitudeProb(qreg.amplitudes[label])
+		}
+	})
+	counts := sampleDistribution(probs, shots, rng)
+	if qreg.noise != nil {
+		counts = qreg.noise.readCounts(qreg.labelQubits(), counts, rng)
+	}
+	return counts
+}
+
+// Simulate measuring a subset of the qubits of the register shots times,
+// without collapsing its quantum state. The qubits are indexed according to the
+// register's bit order, and bit i of each outcome in the returned histogram is the
+// value observed for qubits[i]. If rng is nil, the register's own source of
+// randomness is used. It panics with ErrInvalidTarget or ErrDuplicateTarget if
+// the qubits are not distinct qubits of the register.
+func (qreg *QReg) SampleQubits(shots int, rng *rand.Rand, qubits []int) map[int]int {
+	if rng == nil {
+		rng = qreg.rng
+	}
+	if err := checkQubits(qubits, qreg.width); err != nil {
+		panic(err)
+	}
+	counts := sampleDistribution(qreg.marginalProbs(qubits), shots, rng)
+	if qreg.noise != nil {
+		counts = qreg.noise.readCounts(qubits, counts, rng)
+	}
+	return counts
+}
+
+// Compute the marginal distribution over the given qubits, where bit i of
+// each outcome is the value of qubits[i]. Each worker sums its chunk of the
+// amplitudes separately.
+func (qreg *QReg) marginalProbs(qubits []int) []float64 {
+	positions := make([]uint, len(qubits))
+	for i, qubit := range qubits {
+		positions[i] = qreg.bitPos(qubit)
+	}
+	probs := make([]float64, 1<<uint(len(qubits)))
+	bounds := chunkBounds(len(qreg.amplitudes), minChunkSize, qreg.workers())
+	partials := make([][]float64, len(bounds)-1)
+	forEachChunk(bounds, func(chunk, start, end int) {
+		partial := make([]float64, len(probs))
+		for label := start; label < end; label++ {
+			outcome := 0
+			for i, pos := range positions {
+				outcome |= ((label >> pos) & 1) << uint(i)
+			}
+			partial[outcome] += amplitudeProb(qreg.amplitudes[label])
+		}
+		partials[chunk] = partial
+	})
+	// The partial sums are added in a fixed order, so that the result is
+	// reproducible.
+	for _, partial := range partials {
+		for outcome, prob := range partial {
+			probs[outcome] += prob
+		}
+	}
+	return probs
 }
 
 func (qreg *QReg) PrintState(label int) {
@@ -18,15 +18,13 @@
 package quantum
 
 import (
+	"errors"
 	"math"
 	"math/cmplx"
+	"math/rand"
 	"testing"
 )
 
-// Threshold for how close two probabilities or complex amplitudes have to be
-// before they're considered equal.
-const threshold = 0.0000000001
-
 // Helper function for testing. Returns true if the amplitude for the given
 // basis state is set to 1, and all other amplitudes are set to 0.
 func isBasisState(qreg *QReg, basis int) bool {
@@ -45,7 +43,7 @@ func verifyProb(expected, actual float64) bool {
 
 // Helper function to test that two complex amplitudes are "close enough".
 func verifyAmplitude(expected, actual complex128) bool {
-	return math.Abs(cmplx.Abs(actual)-cmplx.Abs(expected)) < threshold
+	return cmplx.Abs(actual-expected) < threshold
 }
 
 // Test the various forms of the constructor.
@@ -88,6 +86,91 @@ func TestCompose(t *testing.T) {
 	}
 }
 
+// Helper function to test that two registers have the same amplitudes,
+// including their phases.
+func verifySameState(expected, actual *QReg) bool {
+	if len(expected.amplitudes) != len(actual.amplitudes) {
+		return false
+	}
+	for i, amplitude := range expected.amplitudes {
+		if cmplx.Abs(actual.amplitudes[i]-amplitude) > threshold {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecompose(t *testing.T) {
+	ghz := NewQReg(3)
+	Hadamard(ghz, 0)
+	CX().Apply(ghz, []int{0, 1})
+	CX().Apply(ghz, []int{1, 2})
+	parts := []*QReg{
+		NewQubitWithBlochCoords(0.3, 1.2),
+		ghz,
+		KetMinusI(),
+		Compose(KetPlus(), NewQubitWithBlochCoords(2, -0.5)),
+	}
+	qreg := Compose(parts...)
+	qreg.SetBitOrder(BigEndian)
+
+	decomposed := Decompose(qreg, 1, 3, 1, 2)
+	if len(decomposed) != len(parts) {
+		t.Fatalf("Got %d registers, expected %d.", len(decomposed),
+			len(parts))
+	}
+	for i, part := range decomposed {
+		if part.BitOrder() != BigEndian {
+			t.Errorf("Register %d does not have the original bit "+
+				"order.", i)
+		}
+	}
+	if !verifySameState(qreg, Compose(decomposed...)) {
+		t.Errorf("Decomposition does not compose to the original.")
+	}
+
+	// The finest decomposition splits the two qubits of the last part,
+	// but not the GHZ state.
+	decomposed = Decompose(qreg)
+	widths := []int{}
+	for _, part := range decomposed {
+		widths = append(widths, part.Width())
+	}
+	if len(widths) != 5 || widths[1] != 3 ||
+		!verifySameState(qreg, Compose(decomposed...)) {
+		t.Errorf("Bad decomposition into widths %v, expected "+
+			"[1 3 1 1 1].", widths)
+	}
+}
+
+func TestTryDecompose_Errors(t *testing.T) {
+	bell := NewQReg(2)
+	Hadamard(bell, 0)
+	CX().Apply(bell, []int{0, 1})
+	if _, err := TryDecompose(bell, 1, 1); !errors.Is(err, ErrEntangled) {
+		t.Errorf("Expected ErrEntangled, got %v.", err)
+	}
+	if parts := Decompose(bell); len(parts) != 1 {
+		t.Errorf("Bell state decomposed into %d registers.", len(parts))
+	}
+	// A register which cannot be split must still be copied, since gates
+	// are applied in place.
+	original := bell.Copy()
+	for _, parts := range [][]*QReg{Decompose(bell), Decompose(bell, 2)} {
+		PauliX().Apply(parts[0], []int{0})
+		if !verifySameState(original, bell) {
+			t.Error("Applying a gate to a decomposed register " +
+				"changed the original.")
+		}
+	}
+	if _, err := TryDecompose(bell, 1, 2); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+	if _, err := TryDecompose(bell, 2, 0); !errors.Is(err, ErrWidthMismatch) {
+		t.Errorf("Expected ErrWidthMismatch, got %v.", err)
+	}
+}
+
 // Test that the correct values are computed for the probability of observing
 // a basis state.
 func TestQRegStateProb(t *testing.T) {
@@ -106,9 +189,10 @@ func TestQRegStateProb(t *testing.T) {
 }
 
 // Test that the correct values are computed for the probability of observing
-// a given bit.
+// a given bit. With BigEndian bit order, bit 0 is the leftmost.
 func TestQRegBProb(t *testing.T) {
 	qreg := NewQReg(2)
+	qreg.SetBitOrder(BigEndian)
 	qreg.amplitudes = []complex128{
 		cmplx.Sqrt(0.1), // |00>
 		cmplx.Sqrt(0.2), // |01>
@@ -137,6 +221,106 @@ func TestQRegBProb(t *testing.T) {
 	}
 }
 
+// With the default LittleEndian bit order, bit 0 is the rightmost.
+func TestQRegBProb_LittleEndian(t *testing.T) {
+	qreg := NewQReg(2)
+	qreg.amplitudes = []complex128{
+		cmplx.Sqrt(0.1), // |00>
+		cmplx.Sqrt(0.2), // |01>
+		cmplx.Sqrt(0.3), // |10>
+		cmplx.Sqrt(0.4), // |11>
+	}
+
+	// |00> and |10>
+	if !verifyProb(qreg.BProb(0)[0], 0.4) {
+		t.Errorf("Bad probability for |?0>, expected 0.4.")
+	}
+
+	// |01> and |11>
+	if !verifyProb(qreg.BProb(0)[1], 0.6) {
+		t.Errorf("Bad probability for |?1>, expected 0.6.")
+	}
+
+	// |00> and |01>
+	if !verifyProb(qreg.BProb(1)[0], 0.3) {
+		t.Errorf("Bad probability for |0?>, expected 0.3.")
+	}
+
+	// |10> and |11>
+	if !verifyProb(qreg.BProb(1)[1], 0.7) {
+		t.Errorf("Bad probability for |1?>, expected 0.7.")
+	}
+}
+
+// Gates, measurements and sampling must all agree on which physical qubit a
+// given index refers to, whatever the bit order.
+func TestQRegBitOrder_QubitIdentity(t *testing.T) {
+	width := 3
+	for _, order := range []BitOrder{LittleEndian, BigEndian} {
+		for qubit := 0; qubit < width; qubit++ {
+			qreg := NewQReg(width)
+			qreg.SetBitOrder(order)
+			PauliX().Apply(qreg, []int{qubit})
+			for other := 0; other < width; other++ {
+				expected := 0
+				if other == qubit {
+					expected = 1
+				}
+				if !verifyProb(1, qreg.BProb(other)[expected]) {
+					t.Errorf("Order %d: X on qubit %d, expected "+
+						"qubit %d to be %d.", order, qubit,
+						other, expected)
+				}
+				if qreg.BMeasurePreserve(other) != expected {
+					t.Errorf("Order %d: X on qubit %d, expected "+
+						"to measure %d for qubit %d.", order,
+						qubit, expected, other)
+				}
+			}
+			counts := qreg.SampleQubits(10, nil, []int{qubit})
+			if counts[1] != 10 {
+				t.Errorf("Order %d: X on qubit %d, sampled %v.",
+					order, qubit, counts)
+			}
+
+			// A Hadamard on the qubit puts it in superposition, and
+			// setting the same qubit back to 0 restores |000>.
+			qreg = NewQReg(width)
+			qreg.SetBitOrder(order)
+			Hadamard(qreg, qubit)
+			if !verifyProb(0.5, qreg.BProb(qubit)[1]) {
+				t.Errorf("Order %d: H on qubit %d, expected it "+
+					"in superposition.", order, qubit)
+			}
+			qreg.BSet(qubit, 0)
+			if !verifyProb(1, qreg.StateProb(0)) {
+				t.Errorf("Order %d: H on qubit %d, BSet(%d, 0) "+
+					"did not restore |000>.", order, qubit,
+					qubit)
+			}
+		}
+	}
+
+	// The bit order determines which bit of the label each qubit is.
+	qreg := NewQReg(width)
+	PauliX().Apply(qreg, []int{0})
+	if !isBasisState(qreg, 1) {
+		t.Error("LittleEndian: expected X on qubit 0 to give |001>.")
+	}
+	qreg = NewQReg(width)
+	qreg.SetBitOrder(BigEndian)
+	PauliX().Apply(qreg, []int{0})
+	if !isBasisState(qreg, 4) {
+		t.Error("BigEndian: expected X on qubit 0 to give |100>.")
+	}
+	// Multi-qubit gates map targets[i] to bit i of the matrix index in
+	// both orders.
+	CX().Apply(qreg, []int{0, 2})
+	if !isBasisState(qreg, 5) {
+		t.Error("BigEndian: expected CX from qubit 0 to 2 to give |101>.")
+	}
+}
+
 func TestQRegBSet_1BitCollapsed(t *testing.T) {
 	qreg := NewQReg(1, 0)
 	qreg.BSet(0, 1)
@@ -152,6 +336,7 @@ func TestQRegBSet_1BitCollapsed(t *testing.T) {
 
 func TestQRegBSet_1BitEntangled(t *testing.T) {
 	qreg := NewQReg(2, 0)
+	qreg.SetBitOrder(BigEndian)
 	qreg.amplitudes[0] = complex(1/math.Sqrt2, 0)
 	qreg.amplitudes[1] = complex(-1/math.Sqrt2, 0)
 	qreg.BSet(1, 1)
@@ -166,5 +351,162 @@ func TestQRegBSet_1BitEntangled(t *testing.T) {
 }
 
 func TestQRegMeasure(t *testing.T) {
-	// TODO(davinci): Add tests here.
+	// Measuring a register collapses it to the observed basis state.
+	qreg := NewQRegWithRand(rand.NewSource(1), 4)
+	HadamardReg(qreg)
+	label := qreg.Measure()
+	if !verifyProb(1, qreg.StateProb(label)) {
+		t.Errorf("Expected register to collapse to |%d>.", label)
+	}
+	for i := 0; i < 10; i++ {
+		if qreg.MeasurePreserve() != label {
+			t.Fatalf("Expected |%d> to be observed again.", label)
+		}
+	}
+	// TODO(davinci): Add more tests here.
+}
+
+// Registers with identically seeded sources of randomness must produce
+// identical measurement outcomes.
+func TestQRegMeasure_Reproducible(t *testing.T) {
+	measureAll := func(seed int64) []int {
+		var results []int
+		for i := 0; i < 20; i++ {
+			qreg := NewQRegWithRand(rand.NewSource(seed), 3)
+			HadamardReg(qreg)
+			results = append(results, qreg.MeasurePreserve(),
+				qreg.BMeasure(0), qreg.BMeasure(2), qreg.Measure())
+		}
+		qreg := NewQReg(3)
+		qreg.SetRandSource(rand.NewSource(seed))
+		HadamardReg(qreg)
+		counts := qreg.Sample(100, nil)
+		for label := 0; label < 8; label++ {
+			results = append(results, counts[label])
+		}
+		return results
+	}
+	first := measureAll(42)
+	second := measureAll(42)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Outcome %d differs between runs: %d != %d.",
+				i, first[i], second[i])
+		}
+	}
+
+	// Composed registers and copies keep the source of randomness.
+	qreg := Compose(KetZero(), NewQRegWithRand(rand.NewSource(7), 1))
+	if qreg.rng == nil || qreg.Copy().rng != qreg.rng {
+		t.Error("Expected source of randomness to be kept.")
+	}
+}
+
+func TestQRegSample(t *testing.T) {
+	qreg := NewQReg(2)
+	qreg.amplitudes = []complex128{
+		cmplx.Sqrt(0.1), // |00>
+		0,               // |01>
+		cmplx.Sqrt(0.3), // |10>
+		cmplx.Sqrt(0.6), // |11>
+	}
+	original := qreg.Copy()
+	shots := 10000
+	counts := qreg.Sample(shots, rand.New(rand.NewSource(1)))
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total != shots {
+		t.Errorf("Bad number of shots = %d, expected %d.", total, shots)
+	}
+	if counts[1] != 0 {
+		t.Errorf("Observed |01> %d times, expected never.", counts[1])
+	}
+	for label, expected := range []float64{0.1, 0, 0.3, 0.6} {
+		actual := float64(counts[label]) / float64(shots)
+		if math.Abs(actual-expected) > 0.02 {
+			t.Errorf("Bad frequency for |%d> = %f, expected %f.",
+				label, actual, expected)
+		}
+	}
+	// Sampling does not collapse the state.
+	for label := range qreg.amplitudes {
+		if qreg.amplitudes[label] != original.amplitudes[label] {
+			t.Errorf("Amplitude for state %d changed by sampling.",
+				label)
+		}
+	}
+}
+
+func TestQRegSampleQubits(t *testing.T) {
+	// Prepare a Bell state on qubits 0 and 2, with qubit 1 set.
+	qreg := NewQReg(3, 2)
+	Hadamard(qreg, 0)
+	CX().Apply(qreg, []int{0, 2})
+
+	shots := 1000
+	counts := qreg.SampleQubits(shots, rand.New(rand.NewSource(1)), []int{2, 1, 0})
+	if len(counts) != 2 || counts[2]+counts[7] != shots {
+		t.Errorf("Bad histogram %v, expected only 2 and 7.", counts)
+	}
+	counts = qreg.SampleQubits(shots, rand.New(rand.NewSource(1)), []int{0})
+	if counts[0] < 400 || counts[1] < 400 {
+		t.Errorf("Bad histogram %v, expected roughly even.", counts)
+	}
+}
+
+func TestQRegRecording(t *testing.T) {
+	qreg := NewQReg(3)
+	Hadamard(qreg, 0)
+	qreg.StartRecording()
+	HadamardRange(qreg, 1, 3)
+	CX().Apply(qreg, []int{0, 2})
+	DiffusionReg(qreg)
+	qreg.BMeasure(1)
+	qreg.BMeasurePreserve(0)
+	qreg.Measure()
+	circuit := qreg.StopRecording()
+	PauliX().Apply(qreg, []int{0})
+
+	if circuit.Width() != 3 || circuit.NumClbits() != 4 {
+		t.Fatalf("Bad recording of width %d with %d classical bits, "+
+			"expected 3 and 4.", circuit.Width(), circuit.NumClbits())
+	}
+	expected := []struct {
+		kind    OpKind
+		name    string
+		targets []int
+		clbit   int
+	}{
+		{GateOp, "h", []int{1, 2}, 0},
+		{GateOp, "cx", []int{0, 2}, 0},
+		{GateOp, "", []int{0, 1, 2}, 0},
+		{MeasureOp, "", []int{1}, 0},
+		{MeasureOp, "", []int{0}, 1},
+		{MeasureOp, "", []int{1}, 2},
+		{MeasureOp, "", []int{2}, 3},
+	}
+	ops := circuit.Operations()
+	if len(ops) != len(expected) {
+		t.Fatalf("Recorded %d operations, expected %d.", len(ops),
+			len(expected))
+	}
+	for i, op := range ops {
+		want := expected[i]
+		bad := op.Kind != want.kind || op.Clbit != want.clbit ||
+			len(op.Targets) != len(want.targets) ||
+			op.Kind == GateOp && op.Gate.Name() != want.name
+		for j := 0; !bad && j < len(op.Targets); j++ {
+			bad = op.Targets[j] != want.targets[j]
+		}
+		if bad {
+			t.Errorf("Bad operation %d = %+v, expected %+v.", i, op,
+				want)
+		}
+	}
+	if qreg.StopRecording() != nil {
+		t.Error("Expected no recording after it was stopped.")
+	}
 }
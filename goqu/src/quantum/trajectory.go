@@ -0,0 +1,85 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Run the circuit on many copies of a quantum register, which is left
+// unchanged, and return a histogram of the outcomes. Each run follows one
+// trajectory of the register's noise model, in which the Kraus operators of
+// its error channels are chosen at random, so that the histogram approaches
+// the distribution of outcomes of the noisy circuit as the number of
+// trajectories grows. This needs far less memory than evolving a density
+// matrix, so it is feasible for registers of 20 or more qubits.
+//
+// The outcome of a run is the value of the circuit's classical bits, with
+// bit i as the least significant bit, or the basis state label measured at
+// the end if the circuit has no classical bits. The trajectories are run in
+// parallel, each with a source of randomness seeded from the register's, so
+// the histogram does not depend on the number of workers.
+func (circuit *Circuit) RunTrajectories(qreg *QReg, trajectories int) map[int]int {
+	if qreg.width < circuit.width {
+		panic(fmt.Sprintf("Circuit of width %d cannot run on a "+
+			"register of width %d.", circuit.width, qreg.width))
+	}
+	seeds := make([]int64, trajectories)
+	for i := range seeds {
+		seeds[i] = randInt63(qreg.rng)
+	}
+
+	counts := make(map[int]int)
+	var mu sync.Mutex
+	// Each worker reuses one register for all its trajectories, since
+	// large registers are expensive to allocate. If there is more than one
+	// worker, they are all busy, so each trajectory is run serially.
+	bounds := chunkBounds(trajectories, 1, MaxWorkers())
+	forEachChunk(bounds, func(chunk, start, end int) {
+		partial := make(map[int]int)
+		trajectory := qreg.Copy()
+		trajectory.serial = len(bounds) > 2
+		for i := start; i < end; i++ {
+			copy(trajectory.amplitudes, qreg.amplitudes)
+			trajectory.SetRandSource(rand.NewSource(seeds[i]))
+			partial[circuit.runOutcome(trajectory)]++
+		}
+		mu.Lock()
+		for outcome, count := range partial {
+			counts[outcome] += count
+		}
+		mu.Unlock()
+	})
+	return counts
+}
+
+// Run the circuit on a register and return the outcome as for
+// RunTrajectories.
+func (circuit *Circuit) runOutcome(qreg *QReg) int {
+	clbits := circuit.Run(qreg)
+	if len(clbits) == 0 {
+		return qreg.Measure()
+	}
+	outcome := 0
+	for i, b := range clbits {
+		outcome |= b << uint(i)
+	}
+	return outcome
+}
@@ -0,0 +1,170 @@
+// Copyright 2011 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//      Unless required by applicable law or agreed to in writing, software
+//      distributed under the License is distributed on an "AS IS" BASIS,
+//      WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//      See the License for the specific language governing permissions and
+//      limitations under the License.
+//
+// Authors: conleyo@google.com (Conley Owens),
+//          davinci@google.com (David Yonge-Mallo)
+
+package quantum
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func newBellCircuit() *Circuit {
+	return NewCircuit(2).
+		Append(NewHadamardGate(1), 0).
+		Append(CX(), 0, 1).
+		Measure(0, 0).
+		Measure(1, 1)
+}
+
+func TestRunTrajectories_Noiseless(t *testing.T) {
+	qreg := NewQRegWithRand(rand.NewSource(1), 2)
+	counts := newBellCircuit().RunTrajectories(qreg, 1000)
+	if counts[0]+counts[3] != 1000 {
+		t.Errorf("Bad histogram %v, expected only |00> and |11>.", counts)
+	}
+	if math.Abs(float64(counts[0])/1000-0.5) > 0.1 {
+		t.Errorf("Bad histogram %v, expected |00> about half the time.",
+			counts)
+	}
+	if !isBasisState(qreg, 0) {
+		t.Errorf("The register was changed.")
+	}
+}
+
+func TestRunTrajectories_Reproducible(t *testing.T) {
+	defer SetMaxWorkers(0)
+	model := NewNoiseModel().
+		AddAllQubitQuantumError(NewDepolarizingChannel(1, 0.2)).
+		AddAllQubitReadoutError([2][2]float64{{0.9, 0.1}, {0.2, 0.8}})
+	var histograms []map[int]int
+	for _, workers := range []int{1, 3} {
+		SetMaxWorkers(workers)
+		qreg := NewQRegWithRand(rand.NewSource(2), 2)
+		qreg.SetNoiseModel(model)
+		histograms = append(histograms,
+			newBellCircuit().RunTrajectories(qreg, 200))
+	}
+	for outcome := 0; outcome < 4; outcome++ {
+		if histograms[0][outcome] != histograms[1][outcome] {
+			t.Errorf("Histograms %v and %v differ.", histograms[0],
+				histograms[1])
+			break
+		}
+	}
+}
+
+// The histogram of many trajectories should approach the distribution given
+// by evolving the density matrix.
+func TestRunTrajectories_MatchesDensityMatrix(t *testing.T) {
+	const trajectories = 4000
+	depolarizing := NewDepolarizingChannel(1, 0.3)
+	damping := AmplitudeDamping(0.4)
+	model := NewNoiseModel().
+		AddAllQubitQuantumError(depolarizing, "h").
+		AddAllQubitQuantumError(damping, "cx")
+	circuit := NewCircuit(3).
+		Append(NewHadamardGate(1), 0).
+		Append(CX(), 0, 1).
+		Append(CX(), 1, 2)
+
+	dm := NewDensityMatrix(NewQReg(3))
+	for _, op := range circuit.Operations() {
+		dm.Apply(op.Gate, op.Targets)
+		for _, target := range op.Targets {
+			if op.Gate.Name() == "h" {
+				dm.ApplyChannel(depolarizing, []int{target})
+			} else {
+				dm.ApplyChannel(damping, []int{target})
+			}
+		}
+	}
+
+	qreg := NewQRegWithRand(rand.NewSource(3), 3)
+	qreg.SetNoiseModel(model)
+	counts := circuit.RunTrajectories(qreg, trajectories)
+	for label := 0; label < 8; label++ {
+		expected := dm.StateProb(label)
+		actual := float64(counts[label]) / trajectories
+		if math.Abs(expected-actual) > 0.03 {
+			t.Errorf("|%d> observed with frequency %g, expected %g.",
+				label, actual, expected)
+		}
+	}
+}
+
+func BenchmarkRunTrajectories(b *testing.B) {
+	model := NewNoiseModel().
+		AddAllQubitQuantumError(NewDepolarizingChannel(1, 0.01))
+	circuit := NewCircuit(20)
+	for i := 0; i < 20; i++ {
+		circuit.Append(NewHadamardGate(1), i)
+	}
+	for i := 0; i+1 < 20; i++ {
+		circuit.Append(CX(), i, i+1)
+	}
+	qreg := NewQReg(20)
+	qreg.SetNoiseModel(model)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		circuit.RunTrajectories(qreg, 4)
+	}
+}
+
+// Trajectories run in parallel must not each start their own workers, which
+// would exceed the maximum number of workers.
+func TestRunTrajectories_SerialKernels(t *testing.T) {
+	defer SetMaxWorkers(0)
+	SetMaxWorkers(4)
+	var mu sync.Mutex
+	var kernelWorkers []int
+	gate := PauliX()
+	x := newOneQubitKernel(gate)
+	gate.kernel = func(amplitudes []complex128, targets []int, mask, value, workers int) {
+		mu.Lock()
+		kernelWorkers = append(kernelWorkers, workers)
+		mu.Unlock()
+		x(amplitudes, targets, mask, value, workers)
+	}
+	circuit := NewCircuit(1).Append(gate, 0)
+	for _, test := range []struct {
+		trajectories, workers int
+	}{
+		{8, 1},
+		{1, 4},
+	} {
+		kernelWorkers = nil
+		qreg := NewQRegWithRand(rand.NewSource(1), 1)
+		counts := circuit.RunTrajectories(qreg, test.trajectories)
+		if counts[1] != test.trajectories {
+			t.Errorf("Bad histogram %v, expected only |1>.", counts)
+		}
+		if len(kernelWorkers) != test.trajectories {
+			t.Fatalf("Kernel called %d times, expected %d.",
+				len(kernelWorkers), test.trajectories)
+		}
+		for _, workers := range kernelWorkers {
+			if workers != test.workers {
+				t.Errorf("%d trajectories: kernel run with %d "+
+					"workers, expected %d.", test.trajectories,
+					workers, test.workers)
+				break
+			}
+		}
+	}
+}